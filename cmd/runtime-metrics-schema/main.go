@@ -0,0 +1,123 @@
+// Command runtime-metrics-schema writes the schema of the fields reported by the collector
+// package for provisioning dashboards and alerts:
+//
+//	runtime-metrics-schema -format json > schema.json
+//	runtime-metrics-schema -format prometheus-rules -namespace go > rules.yml
+//	runtime-metrics-schema -format grafana -namespace go > dashboard.json
+//
+// By default every field is described. With -groups only those of the given statistics
+// are, to match the configuration of a Collector:
+//
+//	runtime-metrics-schema -format grafana -flavor influx -groups cpu,mem,gc,process,cgroup
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/schema"
+)
+
+func main() {
+	format := flag.String("format", "json", "Output format: json, prometheus-rules or grafana.")
+	groups := flag.String("groups", "", "Comma separated statistics enabled on the Collector: cpu, goroutines, mem, gc, "+
+		"pauses, heapgrowth, finalizers, deltas, sched, contention, gomaxprocs, process, proccpu, fdtypes, numa, thp, cgroup, children and "+
+		"startup. Defaults to all.")
+	namespace := flag.String("namespace", "", "Namespace the Prometheus sink was configured with.")
+	flavor := flag.String("flavor", "prometheus", "Backend the Grafana dashboard queries: prometheus or influx.")
+	measurement := flag.String("measurement", "", "Measurement the InfluxDB sink writes to.")
+	title := flag.String("title", "Go runtime", "Title of the Grafana dashboard.")
+	datasource := flag.String("datasource", "", "UID of the Grafana data source, defaults to a template variable.")
+	flag.Parse()
+
+	fields := collector.Describe()
+	if *groups != "" {
+		c, err := configure(strings.Split(*groups, ","))
+		if err != nil {
+			fail(err)
+		}
+		fields = c.Describe()
+	}
+
+	d := schema.Dashboard{Title: *title, Namespace: *namespace, Measurement: *measurement, Datasource: *datasource}
+	switch *flavor {
+	case "prometheus":
+	case "influx":
+		d.Flavor = schema.Influx
+	default:
+		fail(fmt.Errorf("unknown flavor %q", *flavor))
+	}
+
+	var err error
+	switch *format {
+	case "json":
+		err = schema.WriteJSON(os.Stdout, fields)
+	case "prometheus-rules":
+		err = schema.WritePrometheusRules(os.Stdout, *namespace, fields)
+	case "grafana":
+		err = d.Write(os.Stdout, fields)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// configure returns a Collector with exactly the given statistics enabled.
+func configure(groups []string) (*collector.Collector, error) {
+	c := collector.New(nil, collector.WithCPU(false), collector.WithMem(false), collector.WithGC(false))
+	for _, g := range groups {
+		switch strings.TrimSpace(g) {
+		case "cpu":
+			c.EnableCPU = true
+		case "goroutines":
+			c.EnableGoroutineStates = true
+		case "mem":
+			c.EnableMem = true
+		case "gc":
+			c.EnableGC = true
+		case "finalizers":
+			c.EnableFinalizers = true
+		case "deltas":
+			c.EnableDeltas = true
+		case "pauses":
+			c.EnablePauses = true
+		case "heapgrowth":
+			c.EnableHeapGrowth = true
+		case "sched":
+			c.EnableSched = true
+		case "contention":
+			c.EnableContention = true
+		case "gomaxprocs":
+			c.AdjustMaxProcs = true
+		case "process":
+			c.EnableProcess = true
+		case "proccpu":
+			c.EnableProcCPU = true
+		case "fdtypes":
+			c.EnableFDTypes = true
+		case "numa":
+			c.EnableNUMA = true
+		case "thp":
+			c.EnableTHP = true
+		case "cgroup":
+			c.EnableCgroup = true
+		case "children":
+			c.EnableChildren = true
+		case "startup":
+			c.EnableStartupBaseline = true
+		default:
+			return nil, fmt.Errorf("unknown group %q", g)
+		}
+	}
+	return c, nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "runtime-metrics-schema:", err)
+	os.Exit(1)
+}
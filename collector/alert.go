@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Alert is an in-process guard rail: a Rule evaluated on every collection, with Func
+// invoked when the rule starts being violated and again when it recovers. Every time Func
+// is invoked the Collector also emits an "alert" Event.
+type Alert struct {
+	// Name identifies the alert in its events. Defaults to the description of Rule.
+	Name string
+
+	// Rule is evaluated against every sample, with the history an Asserter keeps, so that
+	// Rate, Trend and aggregated rules may be used. The fields it applies to are collected
+	// even if AllowFields or DenyFields exclude them from the emitted samples.
+	//
+	// Rate-of-change rules catch the slow leaks that absolute thresholds miss on machines
+	// with plenty of memory, such as the heap growing faster than 50MB/min for 10 minutes:
+	//
+	//	Rule{Field: "mem.heap.alloc", Kind: Trend, Limit: 50 << 20, Per: time.Minute, Window: time.Minute, For: 10 * time.Minute}
+	Rule Rule
+
+	// Consecutive is the number of consecutive collections Rule must be violated on
+	// before the alert fires, such as 3 for "goroutines above 10000 for 3 intervals".
+	// Defaults to 1.
+	Consecutive int
+
+	// Func is invoked once when the alert fires, and again once it has recovered. It is
+	// called while the Collector is busy and must not call back into it.
+	Func func(AlertState)
+
+	// Capture, when set, writes profiles every time the alert fires. Profiles are not
+	// captured in builds with the runtimemetrics_minimal tag.
+	Capture *ProfileCapture
+
+	asserter Asserter
+	breaches int
+	firing   bool
+	field    string // the Field of the state that fired
+}
+
+// AlertState is passed to the Func of an Alert when it fires or recovers.
+type AlertState struct {
+	Name string
+
+	// Firing is set when the alert fired and unset when it recovered.
+	Firing bool
+
+	// Violations are those of the collection that fired the alert, nil on recovery.
+	Violations []Violation
+
+	// Field is the field of the first violation of a single field when the alert fired,
+	// kept on its recovery so that both refer to the same measurement.
+	Field string
+
+	Time time.Time
+}
+
+// AddAlert registers a to be evaluated on every collection. If HistorySize is set, a new
+// alert starts from the samples kept in the history, so that its rate-of-change rules do
+// not wait for a window of fresh samples. Fields filtered out of those samples are absent.
+func (c *Collector) AddAlert(a *Alert) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(a.asserter.history) == 0 {
+		a.asserter.prime(c.history.all())
+	}
+	c.Alerts = append(c.Alerts, a)
+}
+
+// WithAlert registers an alert at construction.
+func WithAlert(a *Alert) Option {
+	return func(c *Collector) { c.Alerts = append(c.Alerts, a) }
+}
+
+func (a *Alert) name() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Rule.String()
+}
+
+// observe evaluates the rule against f and reports the state if the alert fired or
+// recovered.
+func (a *Alert) observe(f Fields, at time.Time) (AlertState, bool, error) {
+	a.asserter.Rules = []Rule{a.Rule}
+	violations, err := a.asserter.Assert(f, at)
+	if err != nil {
+		return AlertState{}, false, err
+	}
+
+	need := a.Consecutive
+	if need < 1 {
+		need = 1
+	}
+	if len(violations) == 0 {
+		a.breaches = 0
+	} else if a.breaches < need {
+		a.breaches++
+	}
+
+	firing := a.breaches >= need
+	if firing == a.firing {
+		return AlertState{}, false, nil
+	}
+	a.firing = firing
+	st := AlertState{Name: a.name(), Firing: firing, Time: at}
+	if firing {
+		st.Violations = violations
+		a.field = ""
+		for _, v := range violations {
+			if v.Field != "" {
+				a.field = v.Field
+				break
+			}
+		}
+	}
+	st.Field = a.field
+	return st, true, nil
+}
+
+// checkAlerts evaluates the Alerts against the sample being collected.
+func (c *Collector) checkAlerts(at time.Time) {
+	for _, a := range c.Alerts {
+		st, changed, err := a.observe(c.fields, at)
+		if err != nil {
+			c.reportError(fmt.Errorf("collector: alert %q: %w", a.name(), err))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if a.Func != nil {
+			a.Func(st)
+		}
+		if a.Capture != nil && st.Firing {
+			a.Capture.capture(st.Name, at, c.reportError)
+		}
+		c.emitEvent(st.event())
+	}
+}
+
+// event describes st as an "alert" Event, named after the alert. The measurement is that
+// of the first violation of a single field, whose Field the resolved event carries too.
+func (st AlertState) event() Event {
+	e := Event{Kind: "alert", Name: st.Name, Field: st.Field, Time: st.Time, Resolved: !st.Firing}
+	if !st.Firing {
+		e.Message = "alert " + st.Name + " recovered"
+		return e
+	}
+	descs := make([]string, len(st.Violations))
+	for i, v := range st.Violations {
+		descs[i] = v.String()
+	}
+	e.Message = "alert " + st.Name + " fired: " + strings.Join(descs, "; ")
+	for _, v := range st.Violations {
+		if v.Field != "" {
+			e.Value, e.Threshold = v.Value, v.Rule.Limit
+			break
+		}
+	}
+	return e
+}
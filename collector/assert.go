@@ -0,0 +1,536 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrHistoryRequired is returned by Assert for rules that compare a sample with earlier
+// ones. Such rules are evaluated by an Asserter instead.
+var ErrHistoryRequired = errors.New("collector: rule requires an Asserter")
+
+// RuleKind is the comparison a Rule makes.
+type RuleKind int
+
+const (
+	// Max is violated when the field is above Limit.
+	Max RuleKind = iota
+
+	// Min is violated when the field is below Limit.
+	Min
+
+	// Rate is violated when the field changed by more than Limit per second since the
+	// previous sample.
+	Rate
+
+	// Trend is violated when the field grew by more than Limit per second over Window, as
+	// fitted by linear regression. It tolerates the noise that makes Rate unsuitable for
+	// slow leaks.
+	Trend
+)
+
+func (k RuleKind) String() string {
+	switch k {
+	case Max:
+		return "max"
+	case Min:
+		return "min"
+	case Rate:
+		return "rate"
+	case Trend:
+		return "trend"
+	}
+	return "RuleKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Rule is an assertion on the fields of a sample.
+type Rule struct {
+	// Field names the field, or is a path.Match pattern of the fields, the rule applies
+	// to. A rule matching no field is an error.
+	Field string
+
+	Kind  RuleKind
+	Limit float64
+
+	// Over, when set on a Max or Min rule, compares the aggregate of the field's values
+	// over Window with Limit instead of its latest value, so that a single spike does not
+	// violate the rule. Defaults to Instant.
+	Over Aggregation
+
+	// Window is the period a Trend rule is fitted over and Over aggregates over. Defaults
+	// to five minutes.
+	Window time.Duration
+
+	// Per is the period the Limit of a Rate or Trend rule, and the Value of its violations,
+	// are a change over, such as time.Minute for "heap growing faster than 50MB/min" with a
+	// Limit of 50<<20. Defaults to a second.
+	Per time.Duration
+
+	// For is how long the rule must be breached on every sample before it is reported as
+	// violated. Defaults to zero, reporting the first breach.
+	For time.Duration
+
+	// All and Any make the rule a composite, breached when every rule of All and at least
+	// one rule of Any are breached, so that a rule can describe a failure signature such
+	// as goroutines rising while scheduling latency is high. Field, Kind, Limit, Over and
+	// Window are ignored on composite rules.
+	All []Rule
+	Any []Rule
+
+	// Not negates the rule: it is breached when the rule without Not is not.
+	Not bool
+}
+
+func (r Rule) composite() bool {
+	return len(r.All) > 0 || len(r.Any) > 0
+}
+
+// String describes the rule, such as "mem.heap.alloc max 5.36870912e+08".
+func (r Rule) String() string {
+	var desc string
+	if r.composite() {
+		var parts []string
+		for _, group := range []struct {
+			name  string
+			rules []Rule
+		}{{"all", r.All}, {"any", r.Any}} {
+			if len(group.rules) == 0 {
+				continue
+			}
+			subs := make([]string, len(group.rules))
+			for i, sub := range group.rules {
+				subs[i] = sub.String()
+			}
+			parts = append(parts, group.name+"("+strings.Join(subs, ", ")+")")
+		}
+		desc = strings.Join(parts, " and ")
+	} else {
+		desc = fmt.Sprintf("%s %s %v", r.Field, r.Kind, r.Limit)
+		if r.Per > 0 && (r.Kind == Rate || r.Kind == Trend) {
+			desc += " per " + perString(r.Per)
+		}
+		if r.Over != Instant && (r.Kind == Max || r.Kind == Min) {
+			desc += " (" + r.Over.String() + " over " + r.window().String() + ")"
+		}
+	}
+	if r.For > 0 {
+		desc += " for " + r.For.String()
+	}
+	if r.Not {
+		desc = "not " + desc
+	}
+	return desc
+}
+
+// Aggregation is how a rule combines the values of a field over its Window.
+type Aggregation int
+
+const (
+	// Instant uses the latest value.
+	Instant Aggregation = iota
+
+	Mean
+	Minimum
+	Maximum
+	P50
+	P95
+	P99
+)
+
+func (a Aggregation) String() string {
+	switch a {
+	case Instant:
+		return "instant"
+	case Mean:
+		return "mean"
+	case Minimum:
+		return "min"
+	case Maximum:
+		return "max"
+	case P50:
+		return "p50"
+	case P95:
+		return "p95"
+	case P99:
+		return "p99"
+	}
+	return "Aggregation(" + strconv.Itoa(int(a)) + ")"
+}
+
+// aggregate combines values, which must not be empty, according to a.
+func (a Aggregation) aggregate(values []float64) float64 {
+	sort.Float64s(values)
+	switch a {
+	case Mean:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	case Minimum:
+		return values[0]
+	case Maximum:
+		return values[len(values)-1]
+	case P50, P95, P99:
+		q := map[Aggregation]float64{P50: 0.5, P95: 0.95, P99: 0.99}[a]
+		// Nearest rank.
+		i := int(math.Ceil(q*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+	return values[len(values)-1]
+}
+
+// Violation describes a field found in breach of a Rule. Value is the field's value for
+// Max and Min rules, and its change per Per for Rate and Trend rules.
+//
+// The violation of a composite rule has no Field; its Causes are the violations of the
+// rules it is composed of. The violation of a negated rule has neither Value nor Causes.
+type Violation struct {
+	Rule   Rule
+	Field  string
+	Value  float64
+	Causes []Violation
+}
+
+func (v Violation) String() string {
+	if v.Rule.composite() || v.Rule.Not {
+		desc := "violated " + v.Rule.String()
+		if len(v.Causes) > 0 {
+			causes := make([]string, len(v.Causes))
+			for i, c := range v.Causes {
+				causes[i] = c.String()
+			}
+			desc += ": " + strings.Join(causes, "; ")
+		}
+		return desc
+	}
+
+	var desc string
+	switch v.Rule.Kind {
+	case Max:
+		desc = "above max"
+	case Min:
+		desc = "below min"
+	case Rate:
+		desc = "per " + perString(v.Rule.per()) + ", above max rate"
+	case Trend:
+		desc = "per " + perString(v.Rule.per()) + " over " + v.Rule.window().String() + ", above max trend"
+	}
+	name := v.Field
+	if v.Rule.Over != Instant && (v.Rule.Kind == Max || v.Rule.Kind == Min) {
+		name += " " + v.Rule.Over.String() + " over " + v.Rule.window().String()
+	}
+	return fmt.Sprintf("%s is %v %s %v", name, v.Value, desc, v.Rule.Limit)
+}
+
+func (r Rule) per() time.Duration {
+	if r.Per <= 0 {
+		return time.Second
+	}
+	return r.Per
+}
+
+// perString names the common periods, so that rules read "per minute" rather than "per 1m0s".
+func perString(d time.Duration) string {
+	switch d {
+	case time.Second:
+		return "second"
+	case time.Minute:
+		return "minute"
+	case time.Hour:
+		return "hour"
+	}
+	return d.String()
+}
+
+func (r Rule) window() time.Duration {
+	if r.Window <= 0 {
+		return 5 * time.Minute
+	}
+	return r.Window
+}
+
+// Assert evaluates the Max and Min rules against f, returning the violations in the order
+// of rules. It fails with ErrHistoryRequired if rules contains a Rate or Trend rule, or a
+// rule with an aggregation or a For duration.
+func Assert(f Fields, rules []Rule) ([]Violation, error) {
+	if needsHistory(rules) {
+		return nil, ErrHistoryRequired
+	}
+	return (&Asserter{Rules: rules}).Assert(f, time.Now())
+}
+
+func needsHistory(rules []Rule) bool {
+	for _, r := range rules {
+		if r.composite() {
+			if needsHistory(r.All) || needsHistory(r.Any) || r.For > 0 {
+				return true
+			}
+		} else if r.Kind == Rate || r.Kind == Trend || r.Over != Instant || r.For > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Asserter evaluates rules of every kind against a sequence of samples.
+type Asserter struct {
+	Rules []Rule
+
+	history []assertSample
+
+	// breached holds when each field started breaching each rule, for rules with a For
+	// duration.
+	breached map[breachKey]time.Time
+}
+
+// breachKey identifies a rule by its path through Rules and composite rules, such as
+// "2.0", and the field breaching it.
+type breachKey struct {
+	rule  string
+	field string
+}
+
+type assertSample struct {
+	at     time.Time
+	fields Fields
+}
+
+// Assert evaluates the rules against f, sampled at the given time. Rate and Trend rules
+// are not violated until enough samples have been observed.
+func (a *Asserter) Assert(f Fields, at time.Time) ([]Violation, error) {
+	a.history = append(a.history, assertSample{at: at, fields: f})
+	longest := longestWindow(a.Rules)
+	keep := 2
+	for keep < len(a.history) && at.Sub(a.history[len(a.history)-keep-1].at) <= longest {
+		keep++
+	}
+	if keep < len(a.history) {
+		a.history = append(a.history[:0], a.history[len(a.history)-keep:]...)
+	}
+
+	var violations []Violation
+	for i, r := range a.Rules {
+		vs, err := a.eval(r, strconv.Itoa(i), &f, at)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, vs...)
+	}
+	return violations, nil
+}
+
+// prime records samples observed before the Asserter was first used, such as the history
+// of a Collector, so that Rate and Trend rules need not wait for samples of their own.
+func (a *Asserter) prime(samples []TimestampedFields) {
+	for _, s := range samples {
+		a.history = append(a.history, assertSample{at: s.Time, fields: s.Fields})
+	}
+}
+
+// longestWindow returns the longest period over which any of rules looks back.
+func longestWindow(rules []Rule) time.Duration {
+	var longest time.Duration
+	for _, r := range rules {
+		w := r.window()
+		if r.composite() {
+			w = longestWindow(append(r.All[:len(r.All):len(r.All)], r.Any...))
+		} else if r.Kind != Trend && r.Over == Instant {
+			w = 0
+		}
+		if w > longest {
+			longest = w
+		}
+	}
+	return longest
+}
+
+// eval returns the violations of r, whose path is key, by the latest sample f. The rules
+// a composite is made of are always evaluated so that their state stays current.
+func (a *Asserter) eval(r Rule, key string, f *Fields, at time.Time) ([]Violation, error) {
+	var breached []Violation
+	if r.composite() {
+		var causes []Violation
+		all, any := true, len(r.Any) == 0
+		for j, sub := range append(r.All[:len(r.All):len(r.All)], r.Any...) {
+			vs, err := a.eval(sub, key+"."+strconv.Itoa(j), f, at)
+			if err != nil {
+				return nil, err
+			}
+			if j < len(r.All) {
+				all = all && len(vs) > 0
+			} else {
+				any = any || len(vs) > 0
+			}
+			causes = append(causes, vs...)
+		}
+		if all && any {
+			breached = []Violation{{Rule: r, Causes: causes}}
+		}
+	} else {
+		var err error
+		if breached, err = a.leaf(r, f, at); err != nil {
+			return nil, err
+		}
+	}
+	if r.Not {
+		if len(breached) > 0 {
+			breached = nil
+		} else {
+			breached = []Violation{{Rule: r, Field: r.Field}}
+			if r.composite() {
+				breached[0].Field = ""
+			}
+		}
+	}
+
+	// Apply For, forgetting the breaches that ended.
+	current := make(map[string]bool, len(breached))
+	violations := breached[:0]
+	for _, v := range breached {
+		current[v.Field] = true
+		if r.For > 0 && !a.breachedFor(breachKey{key, v.Field}, r.For, at) {
+			continue
+		}
+		violations = append(violations, v)
+	}
+	for k := range a.breached {
+		if k.rule == key && !current[k.field] {
+			delete(a.breached, k)
+		}
+	}
+	return violations, nil
+}
+
+// leaf returns the fields in breach of r, which is not a composite, ignoring Not and For.
+func (a *Asserter) leaf(r Rule, f *Fields, at time.Time) ([]Violation, error) {
+	values, err := ruleValues(f, r)
+	if err != nil {
+		return nil, err
+	}
+	var breached []Violation
+	for _, name := range sortedValueNames(values) {
+		v, ok := values[name], true
+		switch {
+		case r.Kind == Rate:
+			v, ok = a.rate(name)
+			v *= r.per().Seconds()
+		case r.Kind == Trend:
+			v, ok = a.trend(name, at.Add(-r.window()))
+			v *= r.per().Seconds()
+		case r.Over != Instant:
+			v = r.Over.aggregate(a.values(name, at.Add(-r.window())))
+		}
+		if ok && breaches(r, v) {
+			breached = append(breached, Violation{Rule: r, Field: name, Value: v})
+		}
+	}
+	return breached, nil
+}
+
+// breachedFor records that the rule and field of key are breached at the given time and
+// reports whether they have been on every sample for at least d.
+func (a *Asserter) breachedFor(key breachKey, d time.Duration, at time.Time) bool {
+	if a.breached == nil {
+		a.breached = make(map[breachKey]time.Time)
+	}
+	since, ok := a.breached[key]
+	if !ok {
+		a.breached[key], since = at, at
+	}
+	return at.Sub(since) >= d
+}
+
+// values returns the values of the named field in the samples taken since the given time.
+func (a *Asserter) values(name string, since time.Time) []float64 {
+	var values []float64
+	for _, s := range a.history {
+		if s.at.Before(since) {
+			continue
+		}
+		if v, ok := s.fields.Value(name); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// rate returns the change per second of the named field between the last two samples.
+func (a *Asserter) rate(name string) (float64, bool) {
+	if len(a.history) < 2 {
+		return 0, false
+	}
+	prev, cur := a.history[len(a.history)-2], a.history[len(a.history)-1]
+	dt := cur.at.Sub(prev.at).Seconds()
+	pv, ok1 := prev.fields.Value(name)
+	cv, ok2 := cur.fields.Value(name)
+	if dt <= 0 || !ok1 || !ok2 {
+		return 0, false
+	}
+	return (cv - pv) / dt, true
+}
+
+// trend returns the least-squares slope per second of the named field over the samples
+// taken since the given time.
+func (a *Asserter) trend(name string, since time.Time) (float64, bool) {
+	var n, sx, sy, sxx, sxy float64
+	origin := a.history[len(a.history)-1].at
+	for _, s := range a.history {
+		if s.at.Before(since) {
+			continue
+		}
+		y, ok := s.fields.Value(name)
+		if !ok {
+			continue
+		}
+		x := s.at.Sub(origin).Seconds()
+		n++
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	d := n*sxx - sx*sx
+	if n < 2 || d == 0 {
+		return 0, false
+	}
+	return (n*sxy - sx*sy) / d, true
+}
+
+func breaches(r Rule, v float64) bool {
+	if r.Kind == Min {
+		return v < r.Limit
+	}
+	return v > r.Limit
+}
+
+// ruleValues returns the values of the fields matched by r.
+func ruleValues(f *Fields, r Rule) (map[string]float64, error) {
+	values := make(map[string]float64)
+	for name := range f.ToMap() {
+		if matchField(r.Field, name) {
+			values[name], _ = f.Value(name)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("collector: rule %s matches no field: %s", r.Kind, r.Field)
+	}
+	return values, nil
+}
+
+func sortedValueNames(values map[string]float64) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,101 @@
+package collector
+
+// DropPolicy determines which sample is discarded when the emission queue is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the sample that does not fit in the queue.
+	DropNewest DropPolicy = iota
+
+	// DropOldest discards the oldest queued sample to make room for the new one.
+	DropOldest
+)
+
+// WithAsyncEmit makes Run emit samples from a separate goroutine through a queue of the
+// given size, applying policy when it is full.
+func WithAsyncEmit(queue int, policy DropPolicy) Option {
+	return func(c *Collector) { c.EmitQueue, c.EmitDrop = queue, policy }
+}
+
+// emission is a sample queued for emission along with the sinks and tiers registered when
+// it was collected.
+type emission struct {
+	fields Fields
+	sinks  []Sink
+	tiers  []*Tier
+}
+
+// emitter emits the samples queued by Run under EmitQueue.
+type emitter struct {
+	queue chan emission
+	done  chan struct{}
+}
+
+// startEmitter starts the goroutine emitting queued samples, and returns a function that
+// stops it once the queue is drained.
+func (c *Collector) startEmitter() (stop func()) {
+	e := &emitter{queue: make(chan emission, c.EmitQueue), done: make(chan struct{})}
+	goSelf(func() {
+		defer close(e.done)
+		for em := range e.queue {
+			c.deliver(em.fields, em.sinks, em.tiers)
+		}
+	})
+
+	c.mu.Lock()
+	c.emitter = e
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		c.emitter = nil
+		c.mu.Unlock()
+		close(e.queue)
+		<-e.done
+	}
+}
+
+// emit passes f to the FieldsFunc, subscribers and sinks, or queues it if Run is emitting
+// asynchronously. It must be called with c.mu held.
+func (c *Collector) emit(f Fields) {
+	e := c.emitter
+	if e == nil {
+		c.deliver(f, c.Sinks, c.Tiers)
+		return
+	}
+
+	em := emission{fields: f, sinks: c.Sinks[:len(c.Sinks):len(c.Sinks)], tiers: c.Tiers[:len(c.Tiers):len(c.Tiers)]}
+	for {
+		select {
+		case e.queue <- em:
+			if n := len(e.queue); n > c.emitHighWater {
+				c.emitHighWater = n
+			}
+			return
+		default:
+		}
+		if c.EmitDrop != DropOldest {
+			c.droppedEmissions++
+			return
+		}
+		select {
+		case <-e.queue:
+			c.droppedEmissions++
+		default:
+			// The emitter took the oldest sample in the meantime.
+		}
+	}
+}
+
+func (c *Collector) deliver(f Fields, sinks []Sink, tiers []*Tier) {
+	c.fieldsFunc(f)
+	c.subs.notify(f)
+	c.emitSinks(f, sinks)
+	for _, t := range tiers {
+		if agg, ok := t.add(f); ok {
+			if c.IDFunc != nil {
+				agg.ID = c.IDFunc()
+			}
+			c.emitSinks(agg, t.Sinks)
+		}
+	}
+}
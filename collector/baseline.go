@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+// maxWindowSamples bounds the number of samples recorded per baseline window.
+const maxWindowSamples = 10000
+
+// baseline holds the samples recorded by StartBaseline and EndBaseline.
+type baseline struct {
+	label    string
+	ended    bool
+	baseline []Fields
+	current  []Fields
+}
+
+func (b *baseline) record(f Fields) {
+	w := &b.baseline
+	if b.ended {
+		w = &b.current
+	}
+	if len(*w) < maxWindowSamples {
+		*w = append(*w, f)
+	}
+}
+
+// StartBaseline starts recording every collected sample into a baseline window labelled
+// label, discarding any previous baseline. It is meant for before/after comparisons in
+// load tests: record the baseline, call EndBaseline, apply the change under test and call
+// CompareToBaseline.
+func (c *Collector) StartBaseline(label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.baseline = &baseline{label: label}
+}
+
+// EndBaseline ends the baseline window. Samples collected afterwards are compared against
+// it by CompareToBaseline.
+func (c *Collector) EndBaseline() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.baseline != nil {
+		c.baseline.ended = true
+	}
+}
+
+// CompareToBaseline compares the samples collected since EndBaseline against the
+// baseline. It returns nil if no baseline was started.
+func (c *Collector) CompareToBaseline() *Comparison {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.baseline == nil {
+		return nil
+	}
+	return compare(c.baseline.label, c.baseline.baseline, c.baseline.current)
+}
+
+// Summary summarizes the values of a field over a window.
+type Summary struct {
+	Mean float64
+	P50  float64
+	P95  float64
+	P99  float64
+}
+
+// FieldComparison compares a field between the baseline and the current window. The
+// deltas are the current value minus the baseline value.
+type FieldComparison struct {
+	Name      string
+	Baseline  Summary
+	Current   Summary
+	MeanDelta float64
+	P50Delta  float64
+	P95Delta  float64
+	P99Delta  float64
+
+	// MeanChange is MeanDelta relative to the baseline mean, or zero if the baseline mean
+	// is zero.
+	MeanChange float64
+}
+
+// Comparison is the result of CompareToBaseline.
+type Comparison struct {
+	Label           string
+	BaselineSamples int
+	CurrentSamples  int
+
+	// Fields holds one comparison per field, sorted by name.
+	Fields []FieldComparison
+}
+
+func compare(label string, base, current []Fields) *Comparison {
+	cmp := &Comparison{Label: label, BaselineSamples: len(base), CurrentSamples: len(current)}
+	bv, cv := windowValues(base), windowValues(current)
+	names := make([]string, 0, len(bv))
+	for name := range bv {
+		if _, ok := cv[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b, c := summarize(bv[name]), summarize(cv[name])
+		fc := FieldComparison{
+			Name:      name,
+			Baseline:  b,
+			Current:   c,
+			MeanDelta: c.Mean - b.Mean,
+			P50Delta:  c.P50 - b.P50,
+			P95Delta:  c.P95 - b.P95,
+			P99Delta:  c.P99 - b.P99,
+		}
+		if b.Mean != 0 {
+			fc.MeanChange = fc.MeanDelta / math.Abs(b.Mean)
+		}
+		cmp.Fields = append(cmp.Fields, fc)
+	}
+	return cmp
+}
+
+func windowValues(window []Fields) map[string][]float64 {
+	values := make(map[string][]float64)
+	for i := range window {
+		for name := range window[i].ToMap() {
+			if v, ok := window[i].Value(name); ok {
+				values[name] = append(values[name], v)
+			}
+		}
+	}
+	return values
+}
+
+func summarize(values []float64) Summary {
+	if len(values) == 0 {
+		return Summary{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	return Summary{
+		Mean: sum / float64(len(sorted)),
+		P50:  percentile(sorted, 0.50),
+		P95:  percentile(sorted, 0.95),
+		P99:  percentile(sorted, 0.99),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// String renders the comparison as a Markdown table.
+func (c *Comparison) String() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Baseline %q: %d samples, current: %d samples\n\n", c.Label, c.BaselineSamples, c.CurrentSamples)
+
+	tw := tabwriter.NewWriter(buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tw, "| field\t| baseline mean\t| current mean\t| change\t| p50 delta\t| p95 delta\t| p99 delta\t|")
+	fmt.Fprintln(tw, "|---\t|---\t|---\t|---\t|---\t|---\t|---\t|")
+	for _, f := range c.Fields {
+		fmt.Fprintf(tw, "| %s\t| %.6g\t| %.6g\t| %+.1f%%\t| %+.6g\t| %+.6g\t| %+.6g\t|\n",
+			f.Name, f.Baseline.Mean, f.Current.Mean, f.MeanChange*100, f.P50Delta, f.P95Delta, f.P99Delta)
+	}
+	tw.Flush()
+	return buf.String()
+}
@@ -0,0 +1,49 @@
+package collector
+
+// cgroupStats is the accounting of the cgroup the process belongs to.
+type cgroupStats struct {
+	MemoryLimit int64
+	MemoryUsage int64
+
+	// CPUQuota is the number of CPUs the cgroup may use per period, zero if unlimited.
+	CPUQuota float64
+
+	Periods     int64
+	Throttled   int64
+	ThrottledNs int64
+}
+
+// outputCgroupStats reports the limits and usage of the process's cgroup. Utilizations
+// are percentages; the throttled ratio is the percentage of CPU periods throttled since
+// the previous collection.
+func (c *Collector) outputCgroupStats() {
+	if st, ok := readCgroupStats(); ok {
+		c.setCgroupStats(st)
+	}
+}
+
+func (c *Collector) setCgroupStats(st cgroupStats) {
+	c.fields.CgroupMemoryLimit = st.MemoryLimit
+	c.fields.CgroupMemoryUsage = st.MemoryUsage
+	c.fields.CgroupCPUQuota = st.CPUQuota
+	c.fields.CgroupCPUPeriods = st.Periods
+	c.fields.CgroupCPUThrottled = st.Throttled
+	c.fields.CgroupCPUThrottledNs = st.ThrottledNs
+
+	c.fields.CgroupMemoryUtilization, c.fields.CgroupHeapUtilization = 0, 0
+	if st.MemoryLimit > 0 {
+		c.fields.CgroupMemoryUtilization = 100 * float64(st.MemoryUsage) / float64(st.MemoryLimit)
+		c.fields.CgroupHeapUtilization = 100 * float64(c.fields.HeapSys) / float64(st.MemoryLimit)
+	}
+
+	periods, throttled := st.Periods, st.Throttled
+	if prev := c.prevCgroup; prev != nil && periods >= prev.Periods {
+		periods -= prev.Periods
+		throttled -= prev.Throttled
+	}
+	c.fields.CgroupCPUThrottledRatio = 0
+	if periods > 0 {
+		c.fields.CgroupCPUThrottledRatio = 100 * float64(throttled) / float64(periods)
+	}
+	c.prevCgroup = &st
+}
@@ -0,0 +1,63 @@
+package collector
+
+import "os"
+
+// procStat is the subset of a process's status used by the collector.
+type procStat struct {
+	PID      int
+	PPID     int
+	UserNs   int64
+	SystemNs int64
+	RSS      int64
+	VSZ      int64
+	Threads  int64
+}
+
+// outputChildStats aggregates the processes forked by this process, directly or through
+// intermediate helpers.
+func (c *Collector) outputChildStats() {
+	stats, ok := readProcStats()
+	if !ok {
+		return
+	}
+
+	agg, count := aggregateChildren(stats, os.Getpid())
+	c.fields.ChildCount = count
+	c.fields.ChildRSS = agg.RSS
+	c.fields.ChildCPUUserNs = agg.UserNs
+	c.fields.ChildCPUSystemNs = agg.SystemNs
+}
+
+// aggregateChildren sums the statistics of the descendants of self among stats, and
+// returns how many there are. Other members of its process group, such as the shell or
+// supervisor that started it, are not its children and are left out.
+func aggregateChildren(stats []procStat, self int) (procStat, int64) {
+	parent := make(map[int]int, len(stats))
+	for _, st := range stats {
+		parent[st.PID] = st.PPID
+	}
+
+	var agg procStat
+	var count int64
+	for _, st := range stats {
+		if st.PID == self || !descendsFrom(parent, st.PID, self) {
+			continue
+		}
+		count++
+		agg.UserNs += st.UserNs
+		agg.SystemNs += st.SystemNs
+		agg.RSS += st.RSS
+	}
+	return agg, count
+}
+
+func descendsFrom(parent map[int]int, pid, ancestor int) bool {
+	// Bound the walk in case the snapshot of /proc contains a cycle due to PID reuse.
+	for i := 0; i < len(parent) && pid > 1; i++ {
+		pid = parent[pid]
+		if pid == ancestor {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,13 @@
 package collector
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"reflect"
 	"runtime"
+	"runtime/metrics"
 	"sync"
 	"time"
 )
@@ -16,6 +22,11 @@ type Collector struct {
 	// Defaults to 10 seconds.
 	PauseDur time.Duration
 
+	// Jitter randomizes every pause within ±Jitter of its duration, such as 0.1 for
+	// between 9 and 11 seconds, so that a fleet of identical processes does not collect
+	// and write to the metrics backend in lockstep. It is capped at 1. Defaults to 0.
+	Jitter float64
+
 	// EnableCPU determines whether CPU statistics will be output. Defaults to true.
 	EnableCPU bool
 
@@ -26,86 +37,678 @@ type Collector struct {
 	// must also be set to true for this to take affect. Defaults to true.
 	EnableGC bool
 
+	// EnableDeltas determines whether per-interval deltas and per-second rates of the
+	// cumulative memory and garbage collection counters will be output. EnableMem must also
+	// be set to true for this to take affect. Defaults to false.
+	EnableDeltas bool
+
+	// EnablePauses determines whether the number of GC pauses since the previous
+	// collection, their minimum, maximum and mean, and a histogram of their durations will
+	// be output. EnableMem and EnableGC must also be set to true for this to take affect.
+	// Defaults to false.
+	EnablePauses bool
+
+	// EnableHeapGrowth determines whether the heap growth since the previous collection,
+	// split into the bytes allocated and those reclaimed by the garbage collector, will be
+	// output. EnableMem must also be set to true for this to take affect. Defaults to false.
+	EnableHeapGrowth bool
+
+	// UseRuntimeMetrics determines whether memory and garbage collection statistics are read
+	// from the runtime/metrics package instead of runtime.ReadMemStats, which stops the world
+	// and can take a noticeable amount of time on large heaps. Defaults to false.
+	UseRuntimeMetrics bool
+
+	// EnableChildren determines whether the aggregated CPU time and memory of the processes
+	// forked by this one, directly or through intermediate helpers, will be output. Only
+	// supported on Linux. Defaults to false.
+	EnableChildren bool
+
+	// EnableSched determines whether percentiles of the time goroutines spent runnable
+	// before running will be output. They are computed over the goroutines scheduled since
+	// the previous collection. Defaults to false.
+	EnableSched bool
+
+	// EnableContention determines whether the time goroutines spent waiting for contended
+	// mutexes and blocked on synchronization, and the number of such events, will be output
+	// as sync.* fields, summarized from the mutex and block profiles. Profiling is switched on
+	// by the first collection with MutexProfileFraction and BlockProfileRate, which are
+	// process-wide settings. Defaults to false.
+	EnableContention bool
+
+	// MutexProfileFraction is passed to runtime.SetMutexProfileFraction when
+	// EnableContention is set: on average 1/n contention events are sampled, and the
+	// reported totals are scaled up accordingly. Defaults to 100, negative values leave the
+	// setting of the process untouched.
+	MutexProfileFraction int
+
+	// BlockProfileRate is passed to runtime.SetBlockProfileRate when EnableContention is
+	// set: on average one blocking event per BlockProfileRate nanoseconds spent blocked is
+	// sampled. Defaults to 10000, negative values leave the setting of the process
+	// untouched.
+	BlockProfileRate int
+
+	// AdjustMaxProcs determines whether GOMAXPROCS is set to the CPU quota of the cgroup the
+	// process runs in on every collection, unless the GOMAXPROCS environment variable is
+	// set. The quota and the resulting GOMAXPROCS are output, and a "gomaxprocs" event is
+	// raised on every change. Quotas are only detected on Linux. Defaults to false.
+	AdjustMaxProcs bool
+
+	// EnableRuntimeInfo determines whether the effective GODEBUG settings and the
+	// GOEXPERIMENT the binary was built with are carried as the "godebug" and
+	// "goexperiment" tags, how often non-default GODEBUG behaviors were used is output, and
+	// a "runtime_settings" event is raised when GODEBUG changes. Defaults to false.
+	EnableRuntimeInfo bool
+
+	// EnableIdentityTags determines whether samples and events carry the identity of the
+	// build and process as tags: "go_version", "module_path", "module_version",
+	// "vcs_revision" and "vcs_modified" from the build information, "hostname" and "pid".
+	// Tags that cannot be determined are left out, and Tags take precedence over them.
+	// Note that "pid" changes on every restart, which some backends store as a new series.
+	// Defaults to false.
+	EnableIdentityTags bool
+
+	// EnableProcess determines whether the resident set size, virtual memory size, open
+	// file descriptors and OS threads of the process will be output. Only supported on
+	// Linux; the fields are zero elsewhere. Defaults to false.
+	EnableProcess bool
+
+	// EnableProcCPU determines whether the user and system CPU time consumed by the
+	// process will be output, along with its CPU utilization since the previous collection
+	// as a percentage of one CPU, so that 250 means two and a half CPUs were kept busy. Read
+	// with getrusage on Unix systems and GetProcessTimes on Windows. Defaults to false.
+	EnableProcCPU bool
+
+	// EnableGoroutineStates determines whether the goroutines will be output by state:
+	// running, runnable, in a system call, waiting for network IO, blocked on channels or
+	// select, on locks, sleeping, or waiting for anything else. Counting them takes a dump of
+	// every goroutine's stack while the world is stopped, which gets expensive with many
+	// thousands of goroutines, so they are counted every GoroutineStatesInterval. Defaults to
+	// false.
+	EnableGoroutineStates bool
+
+	// GoroutineStatesInterval is how often the goroutines are counted by state when
+	// EnableGoroutineStates is set. Defaults to every collection.
+	GoroutineStatesInterval time.Duration
+
+	// EnableFDTypes determines whether the open file descriptors of the process will be
+	// output by type: files, sockets, pipes, eventfds, epoll and io_uring instances. They
+	// are classified every FDTypesInterval. Only supported on Linux. Defaults to false.
+	EnableFDTypes bool
+
+	// FDTypesInterval is how often the file descriptors are classified when EnableFDTypes
+	// is set. Defaults to 1 minute.
+	FDTypesInterval time.Duration
+
+	// EnableSizeClasses determines whether the cumulative number of objects allocated and
+	// freed in each size class of the allocator will be output, as
+	// mem.sizeclass.<bytes>.mallocs and mem.sizeclass.<bytes>.frees, which helps track down
+	// storms of small allocations. Only classes objects were allocated in are output, but
+	// that is still over a hundred fields. EnableMem must also be set to true for this to
+	// take affect. Defaults to false.
+	EnableSizeClasses bool
+
+	// EnableFinalizers determines whether the finalizers queued by the GC for objects
+	// passed to runtime.SetFinalizer, and those executed, will be output, along with the
+	// backlog of queued finalizers not yet executed, and the same for the cleanups of
+	// runtime.AddCleanup. All finalizers run on a single goroutine, so a slow one holds up
+	// the others and the memory of their objects; FinalizerBacklogAlert catches a backlog
+	// that keeps growing. Requires Go 1.25, the fields are zero with older releases.
+	// Defaults to false.
+	EnableFinalizers bool
+
+	// EnableStartupBaseline determines whether the growth since startup will be output as
+	// startup.* fields: the heap, memory obtained from the OS, RSS and goroutines compared
+	// with a baseline captured by New, before user code ramps up. Calling MarkStartup at the
+	// end of initialization moves the baseline there, separating the footprint of the
+	// runtime and frameworks from growth driven by the application. The memory fields
+	// require EnableMem, RSS is only supported on Linux. Defaults to false.
+	EnableStartupBaseline bool
+
+	// EnableDebugState determines whether the latest sample, the history and the firing
+	// alerts are mirrored into DebugStates after every collection, for inspecting from a
+	// debugger. Mirroring copies the sample and the history on every collection. Defaults
+	// to false.
+	EnableDebugState bool
+
+	// EnableCgroup determines whether the memory limit and usage, CPU quota and throttling
+	// of the cgroup the process runs in will be output, read from cgroup v2 or v1. Only
+	// supported on Linux. Defaults to false.
+	EnableCgroup bool
+
+	// EnableNUMA determines whether the number of CPUs the process may run on and its
+	// resident memory per NUMA node will be output, the latter as proc.numa.node<N>.memory.
+	// Only supported on Linux. Reading the NUMA placement walks every mapping of the
+	// process, so it is best left off for processes with very large address spaces.
+	// Defaults to false.
+	EnableNUMA bool
+
+	// EnableTHP determines whether the memory of the process backed by transparent huge
+	// pages will be output, and the system's THP settings added to the tags as
+	// "thp_enabled" and "thp_defrag". Only supported on Linux. Defaults to false.
+	EnableTHP bool
+
+	// MemoryLimitAlert, when set, is evaluated on every collection to warn before the
+	// process runs into GOMEMLIMIT or its cgroup memory limit. EnableMem must also be set
+	// to true for this to take affect.
+	MemoryLimitAlert *MemoryLimitAlert
+
+	// SelfCheck, when set, monitors the footprint of the Collector itself and reports
+	// ErrSelfLeak if it keeps growing. Samples then carry the meta.self.* fields and
+	// meta.collect_duration_ns. See SelfCheck for details.
+	SelfCheck *SelfCheck
+
+	// TopAllocators, when set, summarizes the heap profile into the functions holding the
+	// most memory. See TopAllocators for details.
+	TopAllocators *TopAllocators
+
+	// Alerts are evaluated on every collection, invoking their Func when they fire and
+	// recover. See Alert for details.
+	Alerts []*Alert
+
+	// Experiment, when set, overrides PauseDur by alternating between two intervals and
+	// reports the overhead of each. See Experiment for details.
+	Experiment *Experiment
+
+	// SLOs are evaluated on every collection and their burn rates added to the emitted
+	// fields. See SLO for details.
+	SLOs []*SLO
+
+	// AllowFields, when set, restricts the emitted fields to those matching one of its
+	// field names or path.Match patterns, such as "mem.heap.*". DenyFields drops the
+	// fields matching one of its patterns. Collection steps whose fields are all filtered
+	// out are skipped, unless an SLO or MemoryLimitAlert depends on them.
+	AllowFields []string
+	DenyFields  []string
+
+	// CriticalFields names the fields, or path.Match patterns of fields, that sinks emit
+	// first so they are never the ones dropped under deadline pressure. Defaults to
+	// DefaultCriticalFields.
+	CriticalFields []string
+
+	// ForceGC determines whether OneOff runs a garbage collection before gathering
+	// statistics, producing a post-GC live heap measurement for leak hunting. Such samples
+	// are marked with meta.post_gc so they can be told apart from periodic ones. Run is not
+	// affected. Defaults to false.
+	ForceGC bool
+
+	// Instance names the logical instance this Collector reports for when several share a
+	// process, such as embedded test servers. It is carried on every emitted sample.
+	Instance string
+
+	// Tags identify the process to the sinks that support them, such as "host", "service"
+	// or "region". They are carried on every emitted sample and event, along with an
+	// "instance" tag when Instance is set.
+	Tags map[string]string
+
+	// IDFunc, when set, generates the ID carried on every emitted sample and event, so that
+	// the receivers of an at-least-once pipeline can discard duplicates. NewULID and
+	// NewUUIDv7 generate IDs that sort in the order they were generated. The samples
+	// aggregated by a Tier get IDs of their own. IDFunc must be safe for concurrent use.
+	IDFunc func() string
+
+	// Source, when set, is shared with the other collectors of the process so that a single
+	// reading of the runtime serves every instance. See Source for details.
+	Source *Source
+
+	// Tracer, when set, traces the collector's own collection and emission as spans.
+	Tracer Tracer
+
+	// TraceSampleRate is the fraction of collections traced when Tracer is set. Values
+	// outside of (0, 1) trace every collection.
+	TraceSampleRate float64
+
+	// StateFile, when set, is where derived state such as SLO windows, experiment totals and
+	// the restart counter is saved when RunContext returns, and restored from when it starts,
+	// so derived values survive deploys.
+	StateFile string
+
+	// OnTick, when set, is called with every sample before it is emitted and may modify it,
+	// such as to sanitize or enrich it. Returning true skips the emission of the sample to
+	// FieldsFunc, subscribers and sinks; OneOff still returns it.
+	OnTick func(f *Fields) (skip bool)
+
+	// Sinks receive every sample after FieldsFunc. Failures are reported to ErrorFunc.
+	Sinks []Sink
+
+	// Tiers emit every Nth sample, aggregated, to sinks of their own, such as remote sinks
+	// receiving a sample a minute while Sinks receive every sample.
+	Tiers []*Tier
+
+	// EventSinks receive the events raised by alerts, such as MemoryLimitAlert firing.
+	// They are called once the collection raising the events is done, so they may call
+	// back into the Collector. Failures are reported to ErrorFunc.
+	EventSinks []EventSink
+
+	// ErrorFunc receives errors that occur in the background, such as sinks failing to emit
+	// a sample. It is called while the Collector is busy and must not call back into it.
+	// Errors are logged if ErrorFunc is nil.
+	ErrorFunc func(error)
+
+	// NoSink determines what Run does with samples while nothing receives them: no
+	// FieldsFunc, Sink, subscriber, History, Tier or EventSink. Defaults to NoSinkWarn.
+	NoSink NoSinkPolicy
+
+	// BufferSize is the number of samples kept under NoSinkBuffer. Defaults to 60.
+	BufferSize int
+
+	// HistorySize is the number of recent samples kept for History and Since. No history
+	// is kept if it is zero.
+	HistorySize int
+
+	// EmitQueue, when positive, makes Run emit samples from a separate goroutine through a
+	// queue of this size, so that a slow FieldsFunc, subscriber or sink does not delay
+	// collection. Samples that do not fit are discarded according to EmitDrop and counted
+	// in meta.dropped_emissions. Samples collected while Run is not running, such as by
+	// OneOff, are emitted synchronously.
+	EmitQueue int
+
+	// EmitDrop determines which sample is discarded when the queue is full. Defaults to
+	// DropNewest.
+	EmitDrop DropPolicy
+
 	// Done, when closed, is used to signal Collector that is should stop collecting
 	// statistics and the Run function should return.
 	Done <-chan struct{}
 
 	fieldsFunc FieldsFunc
 
+	// hasFieldsFunc records whether New was given a FieldsFunc rather than substituting a
+	// no-op one.
+	hasFieldsFunc bool
+
+	subs     subscribers
+	silences silences
+
+	// pendingEvents are the events raised during a collection, delivered to the
+	// EventSinks once c.mu is released, and pendingCalls the callbacks run before them.
+	pendingEvents []Event
+	pendingCalls  []func()
+
+	// debugState is the entry of the Collector in DebugStates, once EnableDebugState made it
+	// register one.
+	debugState *DebugState
+
+	// latest is the most recently emitted sample, valid once hasLatest is set.
+	latest    Fields
+	hasLatest bool
+
+	history history
+
+	// emitter is set while Run emits asynchronously; droppedEmissions counts the samples
+	// its queue had no room for.
+	emitter          *emitter
+	droppedEmissions int64
+	emitHighWater    int
+
+	// sinkBuffers tracks the local buffers of the sinks implementing BufferedSink.
+	sinkBuffers sinkBuffers
+
+	// goroutineStates is the latest goroutine breakdown, counted at goroutineStatesAt, and
+	// stackBuf the buffer the stacks were dumped into.
+	goroutineStates   goroutineStates
+	goroutineStatesAt time.Time
+	stackBuf          []byte
+
+	// profilingContention is set once the mutex and block profiles were switched on, and
+	// profileBuf holds their latest dump.
+	profilingContention bool
+	profileBuf          bytes.Buffer
+
+	// fdTypes is the latest file descriptor breakdown, read at fdTypesAt.
+	fdTypes   fdTypes
+	fdTypesAt time.Time
+
+	// procCPU is the CPU time of the process read at procCPUAt, from which the utilization
+	// of the next collection is derived.
+	procCPU   procCPUTimes
+	procCPUAt time.Time
+
+	// settings are the exported fields as Run found them when it started, indexed like the
+	// fields of Collector. See restoreSettings.
+	settings []reflect.Value
+
+	// warnedNoSink and buffered hold the state of the NoSink policy.
+	warnedNoSink bool
+	buffered     []Fields
+
 	fields Fields
 
+	// prevMem holds the memory counters observed by the previous collection and is used
+	// to derive per-interval values. It is nil until the first collection.
+	prevMem  *runtime.MemStats
+	prevTime time.Time
+
+	rm runtimeMetricsReader
+
+	// finalizerSamples are the runtime/metrics read for EnableFinalizers.
+	finalizerSamples []metrics.Sample
+
+	// godebugSamples are the runtime/metrics counting non-default GODEBUG behaviors, and
+	// godebug the GODEBUG settings seen by the previous collection, if godebugSeen.
+	godebugSamples []metrics.Sample
+	godebug        string
+	godebugSeen    bool
+
+	// gauges are the user gauges added with RegisterGauge, and gaugeSets those added with
+	// RegisterGauges.
+	gauges    map[string]func() float64
+	gaugeSets map[string]func() (map[string]float64, error)
+
+	// prevSched holds the scheduling latency histogram counts observed by the previous
+	// collection.
+	prevSched []uint64
+
+	// prevCgroup holds the CPU throttling counters observed by the previous collection.
+	prevCgroup *cgroupStats
+
+	// restarts counts the previous runs recorded in StateFile.
+	restarts int64
+
+	// baseline is the window started by StartBaseline, if any.
+	baseline *baseline
+
+	// startup is the baseline of the startup.* fields.
+	startup *startupBaseline
+
+	// trigger requests an immediate collection from Run.
+	trigger chan struct{}
+
 	mu sync.RWMutex
 }
 
 // New creates a new Collector that will periodically output statistics to fieldsFunc. It
-// will also set the values of the exported fields to the described defaults. The values
-// of the exported defaults can be changed at any point before Run is called.
-func New(fieldsFunc FieldsFunc) *Collector {
+// will also set the values of the exported fields to the described defaults, then apply
+// opts. The values of the exported defaults can be changed at any point before Run is
+// called.
+func New(fieldsFunc FieldsFunc, opts ...Option) *Collector {
+	hasFieldsFunc := fieldsFunc != nil
 	if fieldsFunc == nil {
 		fieldsFunc = func(Fields) {}
 	}
 
-	return &Collector{
-		PauseDur:   10 * time.Second,
-		EnableCPU:  true,
-		EnableMem:  true,
-		EnableGC:   true,
-		fieldsFunc: fieldsFunc,
+	c := &Collector{
+		PauseDur:      10 * time.Second,
+		EnableCPU:     true,
+		EnableMem:     true,
+		EnableGC:      true,
+		fieldsFunc:    fieldsFunc,
+		hasFieldsFunc: hasFieldsFunc,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.EnableStartupBaseline {
+		c.startup = captureStartup()
+	}
+	return c
 }
 
 // Run gathers statistics then outputs them to the configured PointFunc every
 // PauseDur. Unlike OneOff, this function will return until Done has been closed
 // (or never if Done is nil), therefore it should be called in its own go routine.
+//
+// Run works with the settings of the Collector as they are when it starts. Assigning an
+// exported field while it is running has no effect: the assignment is reverted and
+// reported as ErrSettingChanged. Sinks, Alerts, Tiers and EventSinks are the exception and
+// are added with AddSink, AddAlert, AddTier and AddEventSink.
 func (c *Collector) Run() {
-	c.outputStats()
+	c.RunContext(context.Background())
+}
+
+// RunContext is like Run but additionally returns once ctx is cancelled.
+func (c *Collector) RunContext(ctx context.Context) {
+	c.mu.Lock()
+	c.snapshotSettings()
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.settings = nil
+		c.mu.Unlock()
+	}()
+
+	c.loadState()
+	defer c.saveState()
+	if c.EmitQueue > 0 {
+		defer c.startEmitter()()
+	}
+
+	c.collect()
 
-	tick := time.NewTicker(c.PauseDur)
-	defer tick.Stop()
+	trigger := c.triggers()
+	timer := time.NewTimer(c.pause())
+	defer timer.Stop()
 	for {
 		select {
 		case <-c.Done:
 			return
-		case <-tick.C:
-			c.outputStats()
+		case <-ctx.Done():
+			return
+		case <-trigger:
+			c.collect()
+		case <-timer.C:
+			c.collect()
+			timer.Reset(c.pause())
 		}
 	}
 }
 
-// OneOff gathers returns a map containing all statistics. It is safe for use from
-// multiple go routines
+// pause returns how long Run should wait before the next collection.
+func (c *Collector) pause() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.restoreSettings()
+
+	d := c.PauseDur
+	if c.Experiment != nil {
+		d = c.Experiment.interval(time.Now())
+	}
+	if c.Jitter > 0 {
+		d = jitter(d, c.Jitter, rand.Float64())
+	}
+	return d
+}
+
+// jitter moves d by up to ±fraction of it, where r in [0, 1) picks the offset.
+func jitter(d time.Duration, fraction, r float64) time.Duration {
+	if fraction > 1 {
+		fraction = 1
+	}
+	return d + time.Duration(float64(d)*fraction*(2*r-1))
+}
+
+// OneOff collects and emits a sample, and returns it. It is safe for use from multiple
+// goroutines, including while Run is running: the sample is gathered from scratch and
+// returned as an independent value. It shares the state Run derives from consecutive
+// samples, such as the baseline of deltas. Use Snapshot to read the latest sample without
+// collecting one.
 func (c *Collector) OneOff() Fields {
-	c.outputStats()
+	if c.ForceGC {
+		runtime.GC()
+	}
+	return c.outputStats(c.ForceGC)
+}
 
-	c.mu.Lock()
-	defer func() {
-		c.fields = Fields{}
-		c.mu.Unlock()
-	}()
-	return c.fields
+// Snapshot returns the most recently emitted sample, whether it was collected by Run or
+// OneOff, without collecting a new one. It reports false if no sample was emitted yet.
+func (c *Collector) Snapshot() (Fields, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.latest, c.hasLatest
 }
 
-func (c *Collector) outputStats() {
+func (c *Collector) outputStats(postGC bool) Fields {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlockAndDeliverEvents()
+	c.restoreSettings()
 
-	if c.EnableCPU {
+	tr := c.startTrace()
+	defer tr.finish()
+
+	endRead := tr.span("collector.read")
+	start := time.Now()
+	c.fields = Fields{}
+	c.fields.Instance = c.Instance
+	if c.IDFunc != nil {
+		c.fields.ID = c.IDFunc()
+	}
+	c.fields.Timestamp = start.UnixNano()
+	c.fields.Tags = c.sampleTags()
+	c.fields.critical = c.CriticalFields
+	c.fields.filter = nil
+	c.fields.omit = c.omitted()
+	ff := c.filter()
+	var startAllocs uint64
+	if c.SelfCheck != nil {
+		startAllocs = c.SelfCheck.heapAllocs()
+	}
+	if c.EnableCPU && c.collects(ff, cpuGroup) {
 		cStats := cpuStats{
 			NumGoroutine: int64(runtime.NumGoroutine()),
 			NumCgoCall:   int64(runtime.NumCgoCall()),
 		}
 		c.outputCPUStats(&cStats)
+		publishGoroutines(cStats.NumGoroutine)
 	}
-	if c.EnableMem {
-		m := &runtime.MemStats{}
-		runtime.ReadMemStats(m)
+	if c.EnableGoroutineStates && c.collects(ff, goroutineStatesGroup) {
+		c.outputGoroutineStates(start)
+	}
+	var mem *runtime.MemStats
+	if c.EnableMem && (c.MemoryLimitAlert != nil || c.collects(ff, memGroup) || c.EnableSizeClasses && c.collectsSizeClasses(ff) ||
+		c.EnableStartupBaseline && c.collects(ff, startupGroup)) {
+		m := c.readMemStats(start)
+		mem = m
 		c.outputMemStats(m)
-		if c.EnableGC {
+		publishHeadroom(m)
+		if c.EnableGC && c.collects(ff, gcGroup) {
 			c.outputGCStats(m)
 		}
+		if c.EnableGC && c.EnablePauses && c.collects(ff, pausesGroup) {
+			c.outputPauses(m, c.prevMem)
+		}
+		if c.EnableHeapGrowth && c.prevMem != nil && c.collects(ff, heapGrowthGroup) {
+			c.outputHeapGrowth(m, c.prevMem)
+		}
+		if c.EnableSizeClasses && c.collectsSizeClasses(ff) {
+			c.outputSizeClasses(m, ff)
+		}
+		if c.EnableDeltas && c.prevMem != nil && c.collects(ff, deltasGroup) {
+			c.outputDeltas(m, c.prevMem, start.Sub(c.prevTime))
+		}
+		c.prevMem, c.prevTime = m, start
+		if c.MemoryLimitAlert != nil {
+			if p, ok := c.MemoryLimitAlert.check(m, time.Now()); ok {
+				e := p.event()
+				e.Resolved = !c.MemoryLimitAlert.firing
+				if fn := c.MemoryLimitAlert.Func; fn != nil && !e.Resolved {
+					c.pendingCalls = append(c.pendingCalls, func() { fn(p) })
+				}
+				c.emitEvent(e)
+			}
+		}
+	}
+	if c.EnableFinalizers && c.collects(ff, finalizerGroup) {
+		c.outputFinalizers()
+	}
+	if c.EnableSched && c.collects(ff, schedGroup) {
+		c.outputSchedStats()
+	}
+	if c.EnableContention && c.collects(ff, contentionGroup) {
+		c.outputContention()
+	}
+	if c.AdjustMaxProcs {
+		c.adjustMaxProcs()
+	}
+	if c.EnableRuntimeInfo {
+		c.outputRuntimeInfo()
+	}
+	if c.EnableProcess && c.collects(ff, processGroup) {
+		c.outputProcessStats()
+	}
+	if c.EnableProcCPU && c.collects(ff, procCPUGroup) {
+		c.outputProcCPU(start)
+	}
+	if c.EnableFDTypes && c.collects(ff, fdTypesGroup) {
+		c.outputFDTypes(start)
+	}
+	if c.EnableCgroup && c.collects(ff, cgroupGroup) {
+		c.outputCgroupStats()
+	}
+	if c.EnableNUMA && c.collects(ff, numaGroup) {
+		c.outputNUMAStats(ff)
+	}
+	if c.EnableTHP && c.collects(ff, thpGroup) {
+		c.outputTHPStats()
+	}
+	if c.EnableChildren && c.collects(ff, childrenGroup) {
+		c.outputChildStats()
+	}
+	if c.EnableStartupBaseline && c.collects(ff, startupGroup) {
+		c.outputStartupDeltas(mem)
+	}
+	c.outputGauges(ff)
+	if c.Experiment != nil {
+		now := time.Now()
+		c.Experiment.record(now.Sub(start), now, &c.fields)
+	}
+	for _, slo := range c.SLOs {
+		slo.observe(&c.fields, start)
+	}
+	c.fields.Restarts = c.restarts
+	c.fields.PostGC = 0
+	if postGC {
+		c.fields.PostGC = 1
+	}
+	if c.SelfCheck != nil {
+		c.checkSelf(startAllocs, start)
+	}
+	if c.TopAllocators != nil {
+		c.fields.TopAllocators = c.TopAllocators.summarize(start)
+	}
+	c.checkAlerts(start)
+	c.fields.filter = ff
+	c.fields.CollectDuration = int64(time.Since(start))
+	c.fields.DroppedEmissions = c.droppedEmissions
+	if c.emitter != nil {
+		c.fields.EmitQueueDepth = int64(len(c.emitter.queue))
+		c.fields.EmitQueueHighWater = int64(c.emitHighWater)
+	}
+	c.outputSinkBuffers(ff)
+	endRead()
+
+	if c.OnTick != nil && c.OnTick(&c.fields) {
+		return c.fields
+	}
+
+	if c.baseline != nil {
+		c.baseline.record(c.fields)
 	}
 
-	c.fieldsFunc(c.fields)
+	endEmit := tr.span("collector.emit")
+	c.latest, c.hasLatest = c.fields, true
+	if c.HistorySize > 0 {
+		c.history.record(c.HistorySize, TimestampedFields{Time: start, Fields: c.fields})
+	}
+	if c.EnableDebugState {
+		c.updateDebugState(start)
+	}
+	c.emit(c.fields)
+	endEmit()
+	return c.fields
+}
+
+// readMemStats reads the memory statistics from the configured backend, or from Source if
+// one is shared with other collectors.
+func (c *Collector) readMemStats(now time.Time) *runtime.MemStats {
+	if c.Source != nil {
+		return c.Source.readMem(c.UseRuntimeMetrics, now)
+	}
+	m := &runtime.MemStats{}
+	if c.UseRuntimeMetrics {
+		c.rm.read(m)
+	} else {
+		runtime.ReadMemStats(m)
+	}
+	return m
 }
 
 func (c *Collector) outputCPUStats(s *cpuStats) {
@@ -114,6 +717,8 @@ func (c *Collector) outputCPUStats(s *cpuStats) {
 }
 
 func (c *Collector) outputMemStats(m *runtime.MemStats) {
+	c.fields.memStats = m
+
 	// General
 	c.fields.Alloc = int64(m.Alloc)
 	c.fields.TotalAlloc = int64(m.TotalAlloc)
@@ -142,6 +747,7 @@ func (c *Collector) outputMemStats(m *runtime.MemStats) {
 }
 
 func (c *Collector) outputGCStats(m *runtime.MemStats) {
+	c.fields.gcStats = m
 	c.fields.GCSys = int64(m.GCSys)
 	c.fields.NextGC = int64(m.NextGC)
 	c.fields.LastGC = int64(m.LastGC)
@@ -151,17 +757,45 @@ func (c *Collector) outputGCStats(m *runtime.MemStats) {
 	c.fields.GCCPUFraction = float64(m.GCCPUFraction)
 }
 
+// outputHeapGrowth splits the heap growth since the previous collection into the bytes
+// that were allocated and the bytes that were reclaimed by the garbage collector. A
+// steadily positive survival ratio points at a leak, while a high allocation volume with
+// a ratio close to zero points at churn.
+func (c *Collector) outputHeapGrowth(m, prev *runtime.MemStats) {
+	growth := int64(m.HeapAlloc) - int64(prev.HeapAlloc)
+	allocated := int64(m.TotalAlloc - prev.TotalAlloc)
+
+	c.fields.HeapGrowth = growth
+	c.fields.HeapGrowthAllocated = allocated
+	c.fields.HeapGrowthReclaimed = allocated - growth
+	c.fields.HeapGrowthObjects = int64(m.Mallocs-prev.Mallocs) - int64(m.Frees-prev.Frees)
+	if allocated > 0 {
+		c.fields.HeapSurvivalRatio = float64(growth) / float64(allocated)
+	}
+}
+
 type cpuStats struct {
 	NumGoroutine int64
 	NumCgoCall   int64
 }
 
-// NOTE: uint64 is not supported by influxDB client due to potential overflows
+// NOTE: uint64 is not supported by influxDB client due to potential overflows. Counters
+// past math.MaxInt64 wrap; ToMapU64 reports them with full precision.
 type Fields struct {
 	// CPU
 	NumGoroutine int64 `json:"cpu.goroutines"`
 	NumCgoCall   int64 `json:"cpu.cgo_calls"`
 
+	// Goroutines by state
+	GoroutinesRunning  int64 `json:"cpu.goroutines.running"`
+	GoroutinesRunnable int64 `json:"cpu.goroutines.runnable"`
+	GoroutinesSyscall  int64 `json:"cpu.goroutines.syscall"`
+	GoroutinesIOWait   int64 `json:"cpu.goroutines.io_wait"`
+	GoroutinesChan     int64 `json:"cpu.goroutines.chan"`
+	GoroutinesLock     int64 `json:"cpu.goroutines.lock"`
+	GoroutinesSleep    int64 `json:"cpu.goroutines.sleep"`
+	GoroutinesOther    int64 `json:"cpu.goroutines.other"`
+
 	// General
 	Alloc      int64 `json:"mem.alloc"`
 	TotalAlloc int64 `json:"mem.total"`
@@ -196,13 +830,227 @@ type Fields struct {
 	PauseNs       int64   `json:"mem.gc.pause"`
 	NumGC         int64   `json:"mem.gc.count"`
 	GCCPUFraction float64 `json:"mem.gc.cpu_fraction"`
+
+	// Finalizers and cleanups
+	FinalizersQueued   int64 `json:"mem.finalizers.queued"`
+	FinalizersExecuted int64 `json:"mem.finalizers.executed"`
+	FinalizerBacklog   int64 `json:"mem.finalizers.backlog"`
+	CleanupsQueued     int64 `json:"mem.cleanups.queued"`
+	CleanupsExecuted   int64 `json:"mem.cleanups.executed"`
+	CleanupBacklog     int64 `json:"mem.cleanups.backlog"`
+
+	// GC pauses since the previous collection
+	NumPauses   int64 `json:"mem.gc.pauses"`
+	PauseMinNs  int64 `json:"mem.gc.pause_min"`
+	PauseMaxNs  int64 `json:"mem.gc.pause_max"`
+	PauseMeanNs int64 `json:"mem.gc.pause_mean"`
+
+	// Heap growth attribution over the last interval
+	HeapGrowth          int64   `json:"mem.heap.growth"`
+	HeapGrowthAllocated int64   `json:"mem.heap.growth.allocated"`
+	HeapGrowthReclaimed int64   `json:"mem.heap.growth.reclaimed"`
+	HeapGrowthObjects   int64   `json:"mem.heap.growth.objects"`
+	HeapSurvivalRatio   float64 `json:"mem.heap.growth.survival_ratio"`
+
+	// Per-interval deltas and per-second rates of cumulative counters
+	TotalAllocDelta   int64   `json:"mem.total_delta"`
+	AllocRate         float64 `json:"mem.alloc_rate"`
+	MallocsDelta      int64   `json:"mem.malloc_delta"`
+	MallocRate        float64 `json:"mem.malloc_rate"`
+	FreesDelta        int64   `json:"mem.frees_delta"`
+	FreeRate          float64 `json:"mem.frees_rate"`
+	PauseTotalNsDelta int64   `json:"mem.gc.pause_total_delta"`
+	PauseRate         float64 `json:"mem.gc.pause_rate"`
+	NumGCDelta        int64   `json:"mem.gc.count_delta"`
+	GCRate            float64 `json:"mem.gc.count_rate"`
+
+	// GOMAXPROCS adjustment
+	MaxProcs      int64   `json:"runtime.gomaxprocs"`
+	MaxProcsQuota float64 `json:"runtime.gomaxprocs.quota"`
+
+	// Scheduler latency, in seconds
+	SchedLatencyP50 float64 `json:"sched.latency.p50"`
+	SchedLatencyP95 float64 `json:"sched.latency.p95"`
+	SchedLatencyP99 float64 `json:"sched.latency.p99"`
+
+	// Contention
+	MutexWaitNs int64 `json:"sync.mutex_wait_ns"`
+	MutexEvents int64 `json:"sync.mutex_events"`
+	BlockWaitNs int64 `json:"sync.block_wait_ns"`
+	BlockEvents int64 `json:"sync.block_events"`
+
+	// Process
+	ProcRSS     int64 `json:"proc.rss"`
+	ProcVSZ     int64 `json:"proc.vsz"`
+	ProcFDs     int64 `json:"proc.fds"`
+	ProcThreads int64 `json:"proc.threads"`
+
+	// Process CPU time
+	ProcCPUUserNs      int64   `json:"proc.cpu.user"`
+	ProcCPUSystemNs    int64   `json:"proc.cpu.system"`
+	ProcCPUUtilization float64 `json:"proc.cpu.utilization"`
+
+	// Growth since startup
+	StartupHeapAlloc   int64 `json:"startup.heap.alloc"`
+	StartupHeapObjects int64 `json:"startup.heap.objects"`
+	StartupAllocated   int64 `json:"startup.allocated"`
+	StartupSys         int64 `json:"startup.sys"`
+	StartupRSS         int64 `json:"startup.rss"`
+	StartupGoroutines  int64 `json:"startup.goroutines"`
+
+	// File descriptors by type
+	FDFiles    int64 `json:"proc.fds.files"`
+	FDSockets  int64 `json:"proc.fds.sockets"`
+	FDPipes    int64 `json:"proc.fds.pipes"`
+	FDEventfds int64 `json:"proc.fds.eventfds"`
+	FDEpolls   int64 `json:"proc.fds.epoll"`
+	FDIOUrings int64 `json:"proc.fds.io_uring"`
+	FDOther    int64 `json:"proc.fds.other"`
+
+	// NUMA
+	ProcAffinityCPUs int64 `json:"proc.affinity.cpus"`
+	ProcNUMANodes    int64 `json:"proc.numa.nodes"`
+
+	// Transparent huge pages
+	ProcAnonHugePages int64 `json:"proc.thp.anon"`
+
+	// Cgroup
+	CgroupMemoryLimit       int64   `json:"proc.cgroup.memory.limit"`
+	CgroupMemoryUsage       int64   `json:"proc.cgroup.memory.usage"`
+	CgroupMemoryUtilization float64 `json:"proc.cgroup.memory.utilization"`
+	CgroupHeapUtilization   float64 `json:"proc.cgroup.memory.heap_utilization"`
+	CgroupCPUQuota          float64 `json:"proc.cgroup.cpu.quota"`
+	CgroupCPUPeriods        int64   `json:"proc.cgroup.cpu.periods"`
+	CgroupCPUThrottled      int64   `json:"proc.cgroup.cpu.throttled"`
+	CgroupCPUThrottledNs    int64   `json:"proc.cgroup.cpu.throttled_time"`
+	CgroupCPUThrottledRatio float64 `json:"proc.cgroup.cpu.throttled_ratio"`
+
+	// Child processes
+	ChildCount       int64 `json:"proc.children.count"`
+	ChildRSS         int64 `json:"proc.children.rss"`
+	ChildCPUUserNs   int64 `json:"proc.children.cpu_user"`
+	ChildCPUSystemNs int64 `json:"proc.children.cpu_system"`
+
+	// Interval experiment
+	ExperimentArm          int64   `json:"meta.experiment.arm"`
+	ExperimentOverheadA    float64 `json:"meta.experiment.overhead_a"`
+	ExperimentOverheadB    float64 `json:"meta.experiment.overhead_b"`
+	ExperimentOverheadDiff float64 `json:"meta.experiment.overhead_diff"`
+
+	// Restarts is the number of previous runs recorded in the collector's StateFile.
+	Restarts int64 `json:"meta.restarts"`
+
+	// PostGC is 1 for samples taken right after a forced garbage collection.
+	PostGC int64 `json:"meta.post_gc"`
+
+	// Timestamp is when the sample was taken, in nanoseconds since the Unix epoch. It is
+	// not a metric value, so ToMap leaves it out; sinks stamp their output with it instead.
+	Timestamp int64 `json:"-"`
+
+	// CollectDuration is how long collecting the sample took in nanoseconds.
+	CollectDuration int64 `json:"meta.collect_duration_ns"`
+
+	// DroppedEmissions is the number of samples discarded because the emission queue was
+	// full. See Collector.EmitQueue.
+	DroppedEmissions int64 `json:"meta.dropped_emissions"`
+
+	// EmitQueueDepth is the number of samples waiting in the emission queue, and
+	// EmitQueueHighWater the most that ever waited in it.
+	EmitQueueDepth     int64 `json:"meta.emit_queue.depth"`
+	EmitQueueHighWater int64 `json:"meta.emit_queue.high_water"`
+
+	// Footprint of the Collector itself, see SelfCheck.
+	SelfGoroutines int64 `json:"meta.self.goroutines"`
+	SelfRetained   int64 `json:"meta.self.retained"`
+	SelfAlloc      int64 `json:"meta.self.alloc"`
+
+	// Extra holds dynamically named values, such as SLO burn rates. ToMap and the JSON
+	// encoding merge them with the fields above.
+	Extra map[string]float64 `json:"-"`
+
+	// Instance is the Instance of the Collector that produced the sample.
+	Instance string `json:"-"`
+
+	// ID identifies the sample when the Collector sets IDFunc.
+	ID string `json:"-"`
+
+	// Tags are the Tags of the Collector that produced the sample.
+	Tags map[string]string `json:"-"`
+
+	// TopAllocators are the functions holding the most heap memory, if the Collector
+	// configures TopAllocators. They are not serialized, as they are not values.
+	TopAllocators []AllocSite `json:"-"`
+
+	critical []string
+	filter   *fieldFilter
+	omit     map[string]bool // the fields of disabled statistics, shared and never modified
+
+	// memStats and gcStats are the statistics the memory and GC fields were read from, kept
+	// for ToMapU64. They are shared with the Collector and never modified.
+	memStats *runtime.MemStats
+	gcStats  *runtime.MemStats
+}
+
+func (f *Fields) setExtra(name string, v float64) {
+	if f.Extra == nil {
+		f.Extra = make(map[string]float64)
+	}
+	f.Extra[name] = v
+}
+
+// Time returns when the sample was taken, or the current time if Timestamp is not set,
+// such as for Fields built by hand.
+func (f *Fields) Time() time.Time {
+	if f.Timestamp == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, f.Timestamp)
+}
+
+// Value returns the value of the named field as a float64. It reports false for the
+// fields ToMap leaves out.
+func (f *Fields) Value(name string) (float64, bool) {
+	if f.omit[name] || f.filter != nil && !f.filter.allowed(name) {
+		return 0, false
+	}
+	if v, ok := f.Extra[name]; ok {
+		return v, true
+	}
+	i, ok := builtinField(name)
+	if !ok {
+		return 0, false
+	}
+	switch fv := reflect.ValueOf(f).Elem().Field(i); fv.Kind() {
+	case reflect.Int64:
+		return float64(fv.Int()), true
+	case reflect.Float64:
+		return fv.Float(), true
+	}
+	return 0, false
 }
 
+// MarshalJSON encodes the fields as a flat object keyed by field name.
+func (f Fields) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.ToMap())
+}
+
+// ToMap returns the fields keyed by name, leaving out those of the statistics disabled on
+// the Collector that produced the sample and those filtered by its AllowFields and
+// DenyFields.
 func (f *Fields) ToMap() map[string]interface{} {
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"cpu.goroutines": f.NumGoroutine,
 		"cpu.cgo_calls":  f.NumCgoCall,
 
+		"cpu.goroutines.running":  f.GoroutinesRunning,
+		"cpu.goroutines.runnable": f.GoroutinesRunnable,
+		"cpu.goroutines.syscall":  f.GoroutinesSyscall,
+		"cpu.goroutines.io_wait":  f.GoroutinesIOWait,
+		"cpu.goroutines.chan":     f.GoroutinesChan,
+		"cpu.goroutines.lock":     f.GoroutinesLock,
+		"cpu.goroutines.sleep":    f.GoroutinesSleep,
+		"cpu.goroutines.other":    f.GoroutinesOther,
+
 		"mem.alloc":   f.Alloc,
 		"mem.total":   f.TotalAlloc,
 		"mem.sys":     f.Sys,
@@ -232,5 +1080,122 @@ func (f *Fields) ToMap() map[string]interface{} {
 		"mem.gc.pause":        f.PauseNs,
 		"mem.gc.count":        f.NumGC,
 		"mem.gc.cpu_fraction": float64(f.GCCPUFraction),
+
+		"mem.finalizers.queued":   f.FinalizersQueued,
+		"mem.finalizers.executed": f.FinalizersExecuted,
+		"mem.finalizers.backlog":  f.FinalizerBacklog,
+		"mem.cleanups.queued":     f.CleanupsQueued,
+		"mem.cleanups.executed":   f.CleanupsExecuted,
+		"mem.cleanups.backlog":    f.CleanupBacklog,
+
+		"mem.gc.pauses":     f.NumPauses,
+		"mem.gc.pause_min":  f.PauseMinNs,
+		"mem.gc.pause_max":  f.PauseMaxNs,
+		"mem.gc.pause_mean": f.PauseMeanNs,
+
+		"mem.heap.growth":                f.HeapGrowth,
+		"mem.heap.growth.allocated":      f.HeapGrowthAllocated,
+		"mem.heap.growth.reclaimed":      f.HeapGrowthReclaimed,
+		"mem.heap.growth.objects":        f.HeapGrowthObjects,
+		"mem.heap.growth.survival_ratio": f.HeapSurvivalRatio,
+
+		"mem.total_delta":          f.TotalAllocDelta,
+		"mem.alloc_rate":           f.AllocRate,
+		"mem.malloc_delta":         f.MallocsDelta,
+		"mem.malloc_rate":          f.MallocRate,
+		"mem.frees_delta":          f.FreesDelta,
+		"mem.frees_rate":           f.FreeRate,
+		"mem.gc.pause_total_delta": f.PauseTotalNsDelta,
+		"mem.gc.pause_rate":        f.PauseRate,
+		"mem.gc.count_delta":       f.NumGCDelta,
+		"mem.gc.count_rate":        f.GCRate,
+
+		"runtime.gomaxprocs":       f.MaxProcs,
+		"runtime.gomaxprocs.quota": f.MaxProcsQuota,
+
+		"sched.latency.p50": f.SchedLatencyP50,
+		"sched.latency.p95": f.SchedLatencyP95,
+		"sched.latency.p99": f.SchedLatencyP99,
+
+		"sync.mutex_wait_ns": f.MutexWaitNs,
+		"sync.mutex_events":  f.MutexEvents,
+		"sync.block_wait_ns": f.BlockWaitNs,
+		"sync.block_events":  f.BlockEvents,
+
+		"proc.rss":     f.ProcRSS,
+		"proc.vsz":     f.ProcVSZ,
+		"proc.fds":     f.ProcFDs,
+		"proc.threads": f.ProcThreads,
+
+		"proc.cpu.user":        f.ProcCPUUserNs,
+		"proc.cpu.system":      f.ProcCPUSystemNs,
+		"proc.cpu.utilization": f.ProcCPUUtilization,
+
+		"startup.heap.alloc":   f.StartupHeapAlloc,
+		"startup.heap.objects": f.StartupHeapObjects,
+		"startup.allocated":    f.StartupAllocated,
+		"startup.sys":          f.StartupSys,
+		"startup.rss":          f.StartupRSS,
+		"startup.goroutines":   f.StartupGoroutines,
+
+		"proc.fds.files":    f.FDFiles,
+		"proc.fds.sockets":  f.FDSockets,
+		"proc.fds.pipes":    f.FDPipes,
+		"proc.fds.eventfds": f.FDEventfds,
+		"proc.fds.epoll":    f.FDEpolls,
+		"proc.fds.io_uring": f.FDIOUrings,
+		"proc.fds.other":    f.FDOther,
+
+		"proc.affinity.cpus": f.ProcAffinityCPUs,
+		"proc.numa.nodes":    f.ProcNUMANodes,
+
+		"proc.thp.anon": f.ProcAnonHugePages,
+
+		"proc.cgroup.memory.limit":            f.CgroupMemoryLimit,
+		"proc.cgroup.memory.usage":            f.CgroupMemoryUsage,
+		"proc.cgroup.memory.utilization":      f.CgroupMemoryUtilization,
+		"proc.cgroup.memory.heap_utilization": f.CgroupHeapUtilization,
+		"proc.cgroup.cpu.quota":               f.CgroupCPUQuota,
+		"proc.cgroup.cpu.periods":             f.CgroupCPUPeriods,
+		"proc.cgroup.cpu.throttled":           f.CgroupCPUThrottled,
+		"proc.cgroup.cpu.throttled_time":      f.CgroupCPUThrottledNs,
+		"proc.cgroup.cpu.throttled_ratio":     f.CgroupCPUThrottledRatio,
+
+		"proc.children.count":      f.ChildCount,
+		"proc.children.rss":        f.ChildRSS,
+		"proc.children.cpu_user":   f.ChildCPUUserNs,
+		"proc.children.cpu_system": f.ChildCPUSystemNs,
+
+		"meta.experiment.arm":           f.ExperimentArm,
+		"meta.experiment.overhead_a":    f.ExperimentOverheadA,
+		"meta.experiment.overhead_b":    f.ExperimentOverheadB,
+		"meta.experiment.overhead_diff": f.ExperimentOverheadDiff,
+
+		"meta.restarts": f.Restarts,
+		"meta.post_gc":  f.PostGC,
+
+		"meta.collect_duration_ns": f.CollectDuration,
+		"meta.dropped_emissions":   f.DroppedEmissions,
+
+		"meta.emit_queue.depth":      f.EmitQueueDepth,
+		"meta.emit_queue.high_water": f.EmitQueueHighWater,
+
+		"meta.self.goroutines": f.SelfGoroutines,
+		"meta.self.retained":   f.SelfRetained,
+		"meta.self.alloc":      f.SelfAlloc,
+	}
+	for k, v := range f.Extra {
+		m[k] = v
+	}
+	for k := range f.omit {
+		delete(m, k)
+	}
+	if f.filter != nil {
+		for k := range m {
+			if !f.filter.allowed(k) {
+				delete(m, k)
+			}
+		}
 	}
+	return m
 }
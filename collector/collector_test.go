@@ -1,6 +1,20 @@
 package collector
 
 import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -49,3 +63,1670 @@ func TestCollector(t *testing.T) {
 	}
 
 }
+
+func TestMemoryLimitAlert(t *testing.T) {
+	var fired []MemoryPressure
+	a := &MemoryLimitAlert{
+		LeadTime: time.Minute,
+		Func:     func(p MemoryPressure) { fired = append(fired, p) },
+	}
+
+	now := time.Now()
+	observe := func(used int64) {
+		if p, changed := a.observe([]MemoryPressure{{Source: "cgroup", Limit: 1000, Used: used}}, now); changed && a.firing {
+			a.Func(p)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	observe(100)
+	observe(110) // 1 byte/s, far from the limit
+	if len(fired) != 0 {
+		t.Fatalf("expected no alert, got %d", len(fired))
+	}
+
+	observe(500) // 39 byte/s, limit reached in ~13s
+	if len(fired) != 1 {
+		t.Fatalf("expected one alert, got %d", len(fired))
+	}
+	if p := fired[0]; p.TimeToLimit <= 0 || p.TimeToLimit > time.Minute {
+		t.Errorf("unexpected time to limit: %s", p.TimeToLimit)
+	}
+
+	observe(600) // still breaching, must not fire again
+	observe(600) // growth stopped, pressure subsided
+	observe(950) // above the default threshold
+	if len(fired) != 2 {
+		t.Fatalf("expected two alerts, got %d", len(fired))
+	}
+}
+
+func TestMemoryLimitAlertCallsCollector(t *testing.T) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(int64(m.Sys) * 4))
+
+	var c *Collector
+	var fired []MemoryPressure
+	c = New(nil)
+	c.MemoryLimitAlert = &MemoryLimitAlert{Threshold: 0.01, Func: func(p MemoryPressure) {
+		c.Status()
+		fired = append(fired, p)
+	}}
+	done := make(chan struct{})
+	go func() {
+		c.OneOff()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Func to be called after the collection released the Collector")
+	}
+	if len(fired) != 1 {
+		t.Errorf("expected the memory limit to fire, got %+v", fired)
+	}
+}
+
+func TestBackpressureSignals(t *testing.T) {
+	New(nil).OneOff()
+
+	if n := GoroutineCount(); n <= 0 {
+		t.Errorf("expected a positive goroutine count, got %d", n)
+	}
+	if b := HeapHeadroomBytes(); b == 0 {
+		t.Errorf("expected heap headroom to be published")
+	}
+}
+
+func TestExperiment(t *testing.T) {
+	e := &Experiment{A: time.Second, B: 10 * time.Second, Window: time.Minute}
+	now := time.Now()
+
+	if d := e.interval(now); d != e.A {
+		t.Fatalf("expected arm A interval, got %s", d)
+	}
+	f := Fields{}
+	e.record(time.Millisecond, now.Add(time.Second), &f)
+
+	now = now.Add(time.Minute)
+	if d := e.interval(now); d != e.B {
+		t.Fatalf("expected arm B interval after the window, got %s", d)
+	}
+	e.record(time.Millisecond, now.Add(10*time.Second), &f)
+
+	if f.ExperimentArm != 1 {
+		t.Errorf("expected arm 1, got %d", f.ExperimentArm)
+	}
+	if f.ExperimentOverheadA <= 0 || f.ExperimentOverheadB <= 0 {
+		t.Errorf("expected overhead for both arms, got %v and %v", f.ExperimentOverheadA, f.ExperimentOverheadB)
+	}
+	if f.ExperimentOverheadDiff <= 0 {
+		t.Errorf("expected arm B to cost more per second of wall time, got %v", f.ExperimentOverheadDiff)
+	}
+}
+
+func TestDescendsFrom(t *testing.T) {
+	parent := map[int]int{10: 1, 11: 10, 12: 11, 20: 1}
+
+	if !descendsFrom(parent, 12, 10) {
+		t.Error("expected 12 to descend from 10")
+	}
+	if descendsFrom(parent, 20, 10) {
+		t.Error("expected 20 not to descend from 10")
+	}
+}
+
+func TestAggregateChildren(t *testing.T) {
+	// 10 was started by the shell 5 and forked 11, which forked 12. The shell and the
+	// sibling 13 it started share the process group of 10 but are not its children.
+	stats := []procStat{
+		{PID: 1, PPID: 0, RSS: 1000},
+		{PID: 5, PPID: 1, RSS: 100, UserNs: 100},
+		{PID: 10, PPID: 5, RSS: 10, UserNs: 10},
+		{PID: 11, PPID: 10, RSS: 2, UserNs: 2, SystemNs: 1},
+		{PID: 12, PPID: 11, RSS: 3, UserNs: 3, SystemNs: 1},
+		{PID: 13, PPID: 5, RSS: 50, UserNs: 50},
+	}
+	agg, count := aggregateChildren(stats, 10)
+	if count != 2 || agg.RSS != 5 || agg.UserNs != 5 || agg.SystemNs != 2 {
+		t.Errorf("expected the 2 descendants only, got %d: %+v", count, agg)
+	}
+}
+
+func TestRuntimeMetricsBackend(t *testing.T) {
+	runtime.GC()
+
+	c := New(nil)
+	c.UseRuntimeMetrics = true
+	f := c.OneOff()
+
+	m := &runtime.MemStats{}
+	runtime.ReadMemStats(m)
+
+	if f.NumGC < 1 || int64(m.NumGC) < f.NumGC {
+		t.Errorf("unexpected GC count %d, runtime reports %d", f.NumGC, m.NumGC)
+	}
+	if f.HeapSys != f.HeapInuse+f.HeapIdle {
+		t.Errorf("heap sys %d does not add up to in-use %d plus idle %d", f.HeapSys, f.HeapInuse, f.HeapIdle)
+	}
+	if f.Sys <= 0 || f.HeapAlloc <= 0 || f.TotalAlloc < f.HeapAlloc || f.NextGC <= 0 {
+		t.Errorf("unexpected memory statistics: %+v", f)
+	}
+	if f.LastGC <= 0 || f.PauseTotalNs <= 0 {
+		t.Errorf("expected GC timing to be populated, got last %d total %d", f.LastGC, f.PauseTotalNs)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	type ctxKey struct{}
+	var spans []string
+	c := New(nil)
+	c.Tracer = TracerFunc(func(ctx context.Context, name string) (context.Context, func()) {
+		if parent, ok := ctx.Value(ctxKey{}).(string); ok {
+			name = parent + "/" + name
+		}
+		return context.WithValue(ctx, ctxKey{}, name), func() { spans = append(spans, name) }
+	})
+	c.OneOff()
+
+	exp := []string{"collector.collect/collector.read", "collector.collect/collector.emit", "collector.collect"}
+	if fmt.Sprint(spans) != fmt.Sprint(exp) {
+		t.Errorf("unexpected spans:\ngot: %v\nexp: %v", spans, exp)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	c := New(nil)
+	c.CriticalFields = []string{"cpu.goroutines", "mem.heap.*"}
+	f := c.OneOff()
+
+	series := f.Series()
+	if len(series) != len(f.ToMap()) {
+		t.Fatalf("expected %d series, got %d", len(f.ToMap()), len(series))
+	}
+	if series[0].Name != "cpu.goroutines" || series[1].Name != "mem.heap.alloc" {
+		t.Errorf("expected critical series first, got %s and %s", series[0].Name, series[1].Name)
+	}
+
+	var sent []string
+	n, err := SendSeries(series, time.Now().Add(-time.Second), func(s Series) error {
+		sent = append(sent, s.Name)
+		return nil
+	})
+	if n != 0 || err != ErrDeadline || len(sent) != 0 {
+		t.Errorf("expected nothing to be sent after the deadline, sent %d: %v", n, err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := New(nil)
+	c.PauseDur = time.Millisecond
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		c.RunContext(ctx)
+	}()
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("RunContext did not return after the context was cancelled")
+	}
+}
+
+func TestForceGC(t *testing.T) {
+	c := New(nil)
+	if f := c.OneOff(); f.PostGC != 0 {
+		t.Errorf("expected a regular sample, got post_gc=%d", f.PostGC)
+	}
+
+	c.ForceGC = true
+	before := c.OneOff().NumGC
+	f := c.OneOff()
+	if f.PostGC != 1 {
+		t.Errorf("expected a post-GC sample, got post_gc=%d", f.PostGC)
+	}
+	if f.NumGC <= before {
+		t.Errorf("expected a forced GC, count went from %d to %d", before, f.NumGC)
+	}
+}
+
+func TestDeltas(t *testing.T) {
+	c := New(nil)
+	c.EnableDeltas = true
+	c.OneOff()
+
+	runtime.GC()
+	f := c.OneOff()
+	if f.NumGCDelta < 1 || f.GCRate <= 0 {
+		t.Errorf("expected the forced GC to show up in the delta, got %d (%v/s)", f.NumGCDelta, f.GCRate)
+	}
+	if f.TotalAllocDelta < 0 || f.TotalAllocDelta > f.TotalAlloc {
+		t.Errorf("unexpected allocation delta %d of total %d", f.TotalAllocDelta, f.TotalAlloc)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	c := New(nil)
+	if c.CompareToBaseline() != nil {
+		t.Fatal("expected no comparison without a baseline")
+	}
+
+	c.StartBaseline("before")
+	c.OneOff()
+	c.OneOff()
+	c.EndBaseline()
+
+	block := make(chan struct{})
+	for i := 0; i < 100; i++ {
+		go func() { <-block }()
+	}
+	c.OneOff()
+	close(block)
+
+	cmp := c.CompareToBaseline()
+	if cmp.Label != "before" || cmp.BaselineSamples != 2 || cmp.CurrentSamples != 1 {
+		t.Fatalf("unexpected comparison: %+v", cmp)
+	}
+	for _, f := range cmp.Fields {
+		if f.Name == "cpu.goroutines" && f.MeanDelta < 100 {
+			t.Errorf("expected at least 100 more goroutines, got %v", f.MeanDelta)
+		}
+	}
+	if s := cmp.String(); !strings.Contains(s, "| cpu.goroutines ") {
+		t.Errorf("expected a table row for cpu.goroutines, got:\n%s", s)
+	}
+}
+
+func TestPauses(t *testing.T) {
+	c := New(nil, WithPauses(true))
+	c.OneOff()
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+	}
+	f := c.OneOff()
+
+	if f.NumPauses < 3 {
+		t.Fatalf("expected at least 3 pauses, got %d", f.NumPauses)
+	}
+	if f.PauseMinNs > f.PauseMeanNs || f.PauseMeanNs > f.PauseMaxNs {
+		t.Errorf("expected min <= mean <= max, got %d, %d, %d", f.PauseMinNs, f.PauseMeanNs, f.PauseMaxNs)
+	}
+	var prev float64
+	for _, b := range pauseBuckets {
+		n := f.Extra["mem.gc.pause.le_"+b.name]
+		if n < prev {
+			t.Errorf("expected cumulative buckets, got %v pauses up to %s after %v", n, b.name, prev)
+		}
+		prev = n
+	}
+	if int64(prev) != f.NumPauses {
+		t.Errorf("expected the last bucket to hold all %d pauses, got %v", f.NumPauses, prev)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	done := make(chan struct{})
+	c := New(nil, WithInterval(time.Second), WithCPU(false), WithGC(false), WithDone(done))
+
+	if c.PauseDur != time.Second || c.EnableCPU || !c.EnableMem || c.EnableGC || c.Done == nil {
+		t.Errorf("options not applied: %+v", c)
+	}
+	if f := c.OneOff(); f.NumGoroutine != 0 || f.NumGC != 0 || f.HeapAlloc == 0 {
+		t.Errorf("expected memory statistics only, got %+v", f)
+	}
+}
+
+func TestSharedSource(t *testing.T) {
+	src := NewSource(time.Minute)
+	a := New(nil)
+	a.Instance, a.Source = "a", src
+	b := New(nil)
+	b.Instance, b.Source = "b", src
+
+	fa := a.OneOff()
+	runtime.GC()
+	fb := b.OneOff()
+
+	if fa.Instance != "a" || fb.Instance != "b" {
+		t.Errorf("unexpected instances %q and %q", fa.Instance, fb.Instance)
+	}
+	if fa.NumGC != fb.NumGC || fa.HeapAlloc != fb.HeapAlloc {
+		t.Errorf("expected both instances to share one reading, got %d/%d GCs", fa.NumGC, fb.NumGC)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	var emitted int
+	var errs []error
+	failure := errors.New("unreachable")
+
+	c := New(nil,
+		WithSink(SinkFunc(func(Fields) error { emitted++; return nil })),
+		WithSink(SinkFunc(func(Fields) error { return failure })),
+		WithErrorFunc(func(err error) { errs = append(errs, err) }),
+	)
+	c.OneOff()
+
+	if emitted != 1 {
+		t.Errorf("expected the first sink to receive one sample, got %d", emitted)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], failure) {
+		t.Errorf("expected the failure to be reported, got %v", errs)
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	collected := make(chan struct{}, 10)
+	c := New(func(Fields) { collected <- struct{}{} }, WithInterval(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.RunContext(ctx)
+
+	<-collected
+	c.TriggerAt(time.Now().Add(10 * time.Millisecond))
+	select {
+	case <-collected:
+	case <-time.After(time.Second):
+		t.Fatal("expected the trigger to cause a collection")
+	}
+}
+
+func TestNoSink(t *testing.T) {
+	var errs []error
+	c := New(nil, WithErrorFunc(func(err error) { errs = append(errs, err) }))
+	c.collect()
+	c.collect()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrNoSink) {
+		t.Errorf("expected ErrNoSink to be reported once, got %v", errs)
+	}
+
+	c = New(nil, WithNoSinkPolicy(NoSinkBuffer))
+	c.BufferSize = 2
+	for i := 0; i < 3; i++ {
+		c.collect()
+	}
+	var got int
+	c.AddSink(SinkFunc(func(Fields) error { got++; return nil }))
+	if got != 2 {
+		t.Errorf("expected the 2 most recent samples to be replayed, got %d", got)
+	}
+	c.collect()
+	if got != 3 || len(c.buffered) != 0 {
+		t.Errorf("expected samples to go to the sink once added")
+	}
+}
+
+func TestNoSinkOtherConsumers(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"history":    WithHistory(10),
+		"tier":       WithTier(5, Mean),
+		"event sink": WithEventSink(EventSinkFunc(func(Event) error { return nil })),
+	} {
+		var errs []error
+		c := New(nil, opt, WithErrorFunc(func(err error) { errs = append(errs, err) }))
+		c.collect()
+		if len(errs) != 0 {
+			t.Errorf("%s: expected the samples to count as received, got %v", name, errs)
+		}
+	}
+
+	c := New(nil, WithHistory(10), WithNoSinkPolicy(NoSinkBuffer))
+	c.collect()
+	if len(c.buffered) != 0 || len(c.History()) != 1 {
+		t.Errorf("expected the sample in the history only, got %d buffered", len(c.buffered))
+	}
+}
+
+func TestAssert(t *testing.T) {
+	f := Fields{HeapAlloc: 600, NumGoroutine: 10, Extra: map[string]float64{"slo.a.burn_rate": 2, "slo.b.burn_rate": 0.5}}
+	violations, err := Assert(f, []Rule{
+		{Field: "mem.heap.alloc", Kind: Max, Limit: 512},
+		{Field: "cpu.goroutines", Kind: Min, Limit: 1},
+		{Field: "slo.*.burn_rate", Kind: Max, Limit: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 2 || violations[0].Field != "mem.heap.alloc" || violations[1].Field != "slo.a.burn_rate" {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+	if _, err := Assert(f, []Rule{{Field: "mem.nope", Kind: Max}}); err == nil {
+		t.Error("expected an error for a rule matching no field")
+	}
+	if _, err := Assert(f, []Rule{{Field: "mem.heap.alloc", Kind: Rate}}); err != ErrHistoryRequired {
+		t.Errorf("expected ErrHistoryRequired, got %v", err)
+	}
+
+	a := &Asserter{Rules: []Rule{
+		{Field: "mem.heap.alloc", Kind: Rate, Limit: 50},
+		{Field: "mem.heap.inuse", Kind: Trend, Limit: 1, Window: time.Minute},
+	}}
+	now := time.Now()
+	var last []Violation
+	inuse := []int64{10, 10, 10, 70}
+	for i, alloc := range []int64{100, 200, 300, 1000} {
+		if last, err = a.Assert(Fields{HeapAlloc: alloc, HeapInuse: inuse[i]}, now.Add(time.Duration(i)*10*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if i < 3 && len(last) != 0 {
+			t.Fatalf("unexpected violations at sample %d: %v", i, last)
+		}
+	}
+	if len(last) != 2 || last[0].Value != 70 || last[1].Value <= 1 {
+		t.Errorf("expected rate and trend violations, got %v", last)
+	}
+
+	a = &Asserter{Rules: []Rule{{Field: "mem.heap.alloc", Kind: Rate, Limit: 3000, Per: time.Minute}}}
+	a.Assert(Fields{HeapAlloc: 100}, now)
+	if last, _ = a.Assert(Fields{HeapAlloc: 200}, now.Add(time.Second)); len(last) != 1 || last[0].Value != 6000 {
+		t.Errorf("expected a violation of 6000 per minute, got %v", last)
+	}
+	if desc := last[0].String(); desc != "mem.heap.alloc is 6000 per minute, above max rate 3000" {
+		t.Errorf("unexpected description %q", desc)
+	}
+}
+
+// raise emits e the way a collection does, delivering it once c.mu is released.
+func raise(c *Collector, e Event) {
+	c.mu.Lock()
+	c.emitEvent(e)
+	c.unlockAndDeliverEvents()
+}
+
+func TestEvents(t *testing.T) {
+	var got []Event
+	c := New(nil, WithEventSink(EventSinkFunc(func(e Event) error {
+		got = append(got, e)
+		return nil
+	})))
+	c.Instance = "api"
+
+	raise(c, MemoryPressure{Source: "cgroup", Limit: 100, Used: 95, Ratio: 0.95}.event())
+	if len(got) != 1 || got[0].Kind != "memory_limit" || got[0].Instance != "api" || got[0].Value != 95 {
+		t.Errorf("unexpected events: %+v", got)
+	}
+}
+
+func TestCgroupStats(t *testing.T) {
+	c := New(nil)
+	c.fields.HeapSys = 250
+	c.setCgroupStats(cgroupStats{MemoryLimit: 1000, MemoryUsage: 500, Periods: 100, Throttled: 10})
+	if c.fields.CgroupMemoryUtilization != 50 || c.fields.CgroupHeapUtilization != 25 {
+		t.Errorf("unexpected utilization %v and %v", c.fields.CgroupMemoryUtilization, c.fields.CgroupHeapUtilization)
+	}
+	if c.fields.CgroupCPUThrottledRatio != 10 {
+		t.Errorf("expected 10%% of all periods throttled, got %v", c.fields.CgroupCPUThrottledRatio)
+	}
+
+	c.setCgroupStats(cgroupStats{MemoryLimit: 1000, Periods: 200, Throttled: 60})
+	if c.fields.CgroupCPUThrottledRatio != 50 {
+		t.Errorf("expected 50%% of the periods since the previous sample throttled, got %v", c.fields.CgroupCPUThrottledRatio)
+	}
+}
+
+func TestSchedLatency(t *testing.T) {
+	buckets := []float64{0, 1, 2, 4, math.Inf(1)}
+	counts := []uint64{50, 40, 9, 1}
+	for q, exp := range map[float64]float64{0.5: 1, 0.9: 2, 0.99: 4, 0.995: 4} {
+		if got := histogramQuantile(buckets, counts, q); got != exp {
+			t.Errorf("quantile %v: got %v, exp %v", q, got, exp)
+		}
+	}
+
+	c := New(nil)
+	c.EnableSched = true
+	c.OneOff()
+	done := make(chan struct{})
+	for i := 0; i < 100; i++ {
+		go func() { done <- struct{}{} }()
+	}
+	for i := 0; i < 100; i++ {
+		<-done
+	}
+	f := c.OneOff()
+	if f.SchedLatencyP50 <= 0 || f.SchedLatencyP99 < f.SchedLatencyP50 {
+		t.Errorf("unexpected scheduling latencies p50 %v, p99 %v", f.SchedLatencyP50, f.SchedLatencyP99)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c := New(nil)
+	var a, b int
+	unsubA := c.Subscribe(func(Fields) { a++ })
+	var unsubB func()
+	unsubB = c.Subscribe(func(Fields) {
+		b++
+		unsubB()
+	})
+
+	c.OneOff()
+	c.OneOff()
+	if a != 2 || b != 1 {
+		t.Errorf("expected 2 and 1 samples, got %d and %d", a, b)
+	}
+
+	unsubA()
+	unsubA()
+	c.OneOff()
+	if a != 2 || c.subs.len() != 0 {
+		t.Errorf("expected no samples after unsubscribing")
+	}
+}
+
+func TestSilence(t *testing.T) {
+	var got []Event
+	c := New(nil, WithEventSink(EventSinkFunc(func(e Event) error {
+		got = append(got, e)
+		return nil
+	})))
+
+	remove := c.Silence(SilenceFor(time.Hour, "deploy"))
+	c.Silence(Silence{End: time.Now().Add(-time.Second)})
+	raise(c, Event{Kind: "memory_limit"})
+	raise(c, Event{Kind: "memory_limit", Resolved: true})
+	if len(got) != 1 || !got[0].Resolved {
+		t.Errorf("expected only the resolved event, got %+v", got)
+	}
+	if active := c.ActiveSilences(); len(active) != 1 || active[0].Comment != "deploy" {
+		t.Errorf("unexpected active silences %+v", active)
+	}
+	remove()
+
+	c.Silence(Silence{Kind: "slo_*"})
+	raise(c, Event{Kind: "memory_limit"})
+	if len(got) != 2 {
+		t.Errorf("expected events of other kinds to pass")
+	}
+
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	nightly := Silence{Start: start, End: start.Add(time.Hour), Repeat: 24 * time.Hour}
+	if !nightly.Active(start.Add(48*time.Hour+time.Minute)) || nightly.Active(start.Add(50*time.Hour)) {
+		t.Error("expected the window to recur daily")
+	}
+}
+
+func TestAsserterWindows(t *testing.T) {
+	a := &Asserter{Rules: []Rule{
+		{Field: "cpu.goroutines", Kind: Max, Limit: 100, Over: Mean, Window: time.Minute},
+		{Field: "mem.heap.alloc", Kind: Max, Limit: 500, For: 20 * time.Second},
+	}}
+	now := time.Now()
+	samples := []struct {
+		goroutines, alloc int64
+		violated          []string
+	}{
+		{50, 600, nil},
+		{50, 600, nil},
+		{50, 600, []string{"mem.heap.alloc"}},
+		{200, 100, nil}, // a spike only raises the mean to 87.5
+		{50, 600, nil},
+		{200, 600, nil},
+		{200, 600, []string{"cpu.goroutines", "mem.heap.alloc"}},
+	}
+	for i, s := range samples {
+		at := now.Add(time.Duration(i) * 10 * time.Second)
+		violations, err := a.Assert(Fields{NumGoroutine: s.goroutines, HeapAlloc: s.alloc}, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, v := range violations {
+			got = append(got, v.Field)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(s.violated) {
+			t.Errorf("sample %d: got violations %v, exp %v", i, got, s.violated)
+		}
+	}
+
+	if got := P95.aggregate([]float64{5, 1, 4, 2, 3}); got != 5 {
+		t.Errorf("unexpected p95 %v", got)
+	}
+}
+
+func TestCompositeRules(t *testing.T) {
+	signature := Rule{All: []Rule{
+		{Field: "cpu.goroutines", Kind: Rate, Limit: 1},
+		{Field: "sched.latency.p99", Kind: Max, Limit: 0.01},
+	}}
+	a := &Asserter{Rules: []Rule{signature}}
+	now := time.Now()
+	for i, s := range []struct {
+		goroutines int64
+		latency    float64
+		violated   bool
+	}{
+		{100, 0.05, false},
+		{200, 0.001, false}, // rising, but scheduled promptly
+		{300, 0.05, true},
+		{300, 0.05, false}, // slow, but no longer rising
+	} {
+		f := Fields{NumGoroutine: s.goroutines, SchedLatencyP99: s.latency}
+		violations, err := a.Assert(f, now.Add(time.Duration(i)*10*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.violated != (len(violations) == 1) {
+			t.Errorf("sample %d: unexpected violations %v", i, violations)
+		}
+		if len(violations) == 1 && len(violations[0].Causes) != 2 {
+			t.Errorf("expected both causes, got %v", violations[0].Causes)
+		}
+	}
+
+	violations, err := Assert(Fields{NumGoroutine: 5}, []Rule{
+		{Not: true, Field: "cpu.goroutines", Kind: Max, Limit: 1},
+		{Any: []Rule{{Field: "cpu.goroutines", Kind: Min, Limit: 10}, {Field: "mem.heap.alloc", Kind: Max, Limit: 0}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].String() != "violated any(cpu.goroutines min 10, mem.heap.alloc max 0): cpu.goroutines is 5 below min 10" {
+		t.Errorf("unexpected violations %v", violations)
+	}
+}
+
+func TestTags(t *testing.T) {
+	c := New(nil, WithTags(map[string]string{"service": "api"}))
+	c.Instance = "a"
+	f := c.OneOff()
+	if len(f.Tags) != 2 || f.Tags["service"] != "api" || f.Tags["instance"] != "a" {
+		t.Errorf("unexpected tags %v", f.Tags)
+	}
+
+	c.Tags["service"] = "changed"
+	if f.Tags["service"] != "api" {
+		t.Error("expected the sample to own its tags")
+	}
+
+	if f := New(nil).OneOff(); f.Tags != nil {
+		t.Errorf("expected no tags, got %v", f.Tags)
+	}
+}
+
+func TestFieldFilter(t *testing.T) {
+	c := New(nil, WithFields("mem.heap.*", "cpu.goroutines"), WithoutFields("mem.heap.growth*"))
+	f := c.OneOff()
+	m := f.ToMap()
+	if _, ok := m["cpu.goroutines"]; !ok || len(m) != 7 {
+		t.Errorf("unexpected fields %v", m)
+	}
+	for name := range m {
+		if strings.HasPrefix(name, "mem.heap.growth") {
+			t.Errorf("expected %s to be denied", name)
+		}
+	}
+	if f.NumGC != 0 {
+		t.Error("expected GC statistics not to be collected")
+	}
+
+	c = New(nil, WithFields("cpu.*"))
+	c.SLOs = []*SLO{{Name: "heap", Field: "mem.heap.alloc", Threshold: 1}}
+	if f := c.OneOff(); f.HeapAlloc == 0 {
+		t.Error("expected memory statistics to be collected for the SLO")
+	}
+}
+
+func TestOnTick(t *testing.T) {
+	var emitted []Fields
+	veto := true
+	c := New(func(f Fields) { emitted = append(emitted, f) }, WithOnTick(func(f *Fields) bool {
+		f.Extra = map[string]float64{"app.enriched": 1}
+		return veto
+	}))
+
+	if f := c.OneOff(); f.Extra["app.enriched"] != 1 || len(emitted) != 0 {
+		t.Errorf("expected a vetoed but enriched sample, emitted %d", len(emitted))
+	}
+	veto = false
+	c.OneOff()
+	if len(emitted) != 1 || emitted[0].Extra["app.enriched"] != 1 {
+		t.Errorf("expected the enriched sample to be emitted, got %v", emitted)
+	}
+}
+
+func TestRegisterGauge(t *testing.T) {
+	c := New(nil)
+	queue := 3.0
+	c.RegisterGauge("app.queue_depth", func() float64 { return queue })
+	f := c.OneOff()
+	if v, ok := f.Value("app.queue_depth"); !ok || v != 3 {
+		t.Errorf("expected the gauge to be sampled, got %v", v)
+	}
+
+	c.UnregisterGauge("app.queue_depth")
+	f = c.OneOff()
+	if _, ok := f.Value("app.queue_depth"); ok {
+		t.Error("expected the gauge to be removed")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a built-in field name")
+		}
+	}()
+	c.RegisterGauge("mem.sys", func() float64 { return 0 })
+}
+
+func TestRuntimeInfo(t *testing.T) {
+	t.Setenv("GODEBUG", "gctrace=0")
+	var events []Event
+	c := New(nil, WithEventSink(EventSinkFunc(func(e Event) error {
+		events = append(events, e)
+		return nil
+	})))
+	c.EnableRuntimeInfo = true
+
+	f := c.OneOff()
+	if !strings.Contains(f.Tags["godebug"], "gctrace=0") {
+		t.Errorf("expected the GODEBUG setting to be tagged, got %v", f.Tags)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected no event at startup, got %v", events)
+	}
+
+	t.Setenv("GODEBUG", "gctrace=0,madvdontneed=1")
+	c.OneOff()
+	if len(events) != 1 || events[0].Kind != "runtime_settings" {
+		t.Errorf("expected a change event, got %v", events)
+	}
+}
+
+func TestMaxProcsFor(t *testing.T) {
+	for _, tc := range []struct {
+		quota  float64
+		numCPU int
+		exp    int
+	}{
+		{0, 8, 8},
+		{2.5, 8, 2},
+		{0.5, 8, 1},
+		{16, 8, 8},
+	} {
+		if got := maxProcsFor(tc.quota, tc.numCPU); got != tc.exp {
+			t.Errorf("maxProcsFor(%v, %d) = %d, exp %d", tc.quota, tc.numCPU, got, tc.exp)
+		}
+	}
+
+	c := New(nil)
+	c.AdjustMaxProcs = true
+	t.Setenv("GOMAXPROCS", "3")
+	if f := c.OneOff(); f.MaxProcs != int64(runtime.GOMAXPROCS(0)) {
+		t.Errorf("expected the current GOMAXPROCS to be reported, got %d", f.MaxProcs)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	c := New(nil, WithHistory(3))
+	n := 0.0
+	c.RegisterGauge("app.tick", func() float64 { n++; return n })
+
+	if h := c.History(); len(h) != 0 {
+		t.Fatalf("expected no history before the first sample, got %d", len(h))
+	}
+	for i := 0; i < 5; i++ {
+		c.OneOff()
+	}
+	h := c.History()
+	if len(h) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(h))
+	}
+	for i, s := range h {
+		if want := float64(i + 3); s.Extra["app.tick"] != want {
+			t.Errorf("sample %d: expected tick %v, got %v", i, want, s.Extra["app.tick"])
+		}
+	}
+
+	if s := c.Since(h[0].Time); len(s) != 2 || s[0].Extra["app.tick"] != 4 {
+		t.Errorf("expected the last 2 samples since the oldest, got %v", s)
+	}
+	if s := c.Since(time.Now()); len(s) != 0 {
+		t.Errorf("expected no samples since now, got %d", len(s))
+	}
+}
+
+func TestNUMAParsing(t *testing.T) {
+	for list, want := range map[string]int64{"0-3": 4, "0-3,8,10-11\n": 7, "5": 1, "": 0} {
+		if got := cpuListLen(list); got != want {
+			t.Errorf("cpuListLen(%q) = %d, want %d", list, got, want)
+		}
+	}
+
+	maps := `00400000 default file=/bin/app mapped=10 N0=6 N1=4 kernelpagesize_kB=4
+7f0000000000 interleave:0-1 anon=2 dirty=2 N1=2 kernelpagesize_kB=2048
+7ffd00000000 default stack anon=3 dirty=3 N0=3 kernelpagesize_kB=4
+`
+	got := parseNUMAMaps(strings.NewReader(maps))
+	if want := map[int]int64{0: 9 * 4096, 1: 4*4096 + 2*2<<20}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNUMAMaps = %v, want %v", got, want)
+	}
+}
+
+func TestAlert(t *testing.T) {
+	var states []AlertState
+	var events []Event
+	depth := 5.0
+	c := New(nil, WithAlert(&Alert{
+		Name:        "queue",
+		Rule:        Rule{Field: "app.queue_depth", Kind: Max, Limit: 10},
+		Consecutive: 3,
+		Func:        func(st AlertState) { states = append(states, st) },
+	}), WithEventSink(EventSinkFunc(func(e Event) error {
+		events = append(events, e)
+		return nil
+	})))
+	c.RegisterGauge("app.queue_depth", func() float64 { return depth })
+
+	for _, d := range []float64{20, 20, 5, 20, 20} {
+		depth = d
+		c.OneOff()
+	}
+	if len(states) != 0 {
+		t.Fatalf("expected no alert before 3 consecutive breaches, got %v", states)
+	}
+	depth = 20
+	c.OneOff()
+	c.OneOff()
+	if len(states) != 1 || !states[0].Firing || states[0].Name != "queue" || len(states[0].Violations) != 1 {
+		t.Fatalf("expected the alert to fire once, got %v", states)
+	}
+	if st := c.Status(); len(st.FiringAlerts) != 1 || st.FiringAlerts[0] != "queue" {
+		t.Errorf("expected the alert in the status, got %+v", st)
+	}
+
+	depth = 5
+	c.OneOff()
+	if len(states) != 2 || states[1].Firing || states[1].Violations != nil {
+		t.Fatalf("expected the alert to recover, got %v", states)
+	}
+
+	if len(events) != 2 || events[0].Kind != "alert" || events[0].Field != "app.queue_depth" ||
+		events[0].Value != 20 || events[0].Threshold != 10 || !events[1].Resolved {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if events[1].Field != events[0].Field || events[1].Name != "queue" || events[0].Name != "queue" {
+		t.Errorf("expected the resolved event to name the alert and field of the firing one, got %+v", events)
+	}
+}
+
+func TestEventSinkCallsCollector(t *testing.T) {
+	var c *Collector
+	var firing []string
+	c = New(nil, WithAlert(&Alert{
+		Name: "queue",
+		Rule: Rule{Field: "app.queue_depth", Kind: Max, Limit: 10},
+	}), WithEventSink(EventSinkFunc(func(e Event) error {
+		firing = c.Status().FiringAlerts
+		return nil
+	})))
+	c.RegisterGauge("app.queue_depth", func() float64 { return 20 })
+
+	done := make(chan struct{})
+	go func() {
+		c.OneOff()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the event sink to be called after the collection released the Collector")
+	}
+	if len(firing) != 1 || firing[0] != "queue" {
+		t.Errorf("expected the sink to see the firing alert, got %v", firing)
+	}
+}
+
+func TestAlertFromHistory(t *testing.T) {
+	var states []AlertState
+	depth := 0.0
+	c := New(nil, WithHistory(10))
+	c.RegisterGauge("app.queue_depth", func() float64 { depth++; return depth })
+	for i := 0; i < 5; i++ {
+		c.OneOff()
+	}
+
+	c.AddAlert(&Alert{
+		Rule: Rule{Field: "app.queue_depth", Kind: Trend, Limit: 50, Per: time.Minute},
+		Func: func(st AlertState) { states = append(states, st) },
+	})
+	c.OneOff()
+	if len(states) != 1 || !states[0].Firing {
+		t.Errorf("expected the trend over the history to fire the alert, got %v", states)
+	}
+}
+
+func TestToMapU64(t *testing.T) {
+	total := uint64(math.MaxInt64) + 10
+	f := Fields{TotalAlloc: int64(total), Alloc: 5, memStats: &runtime.MemStats{TotalAlloc: total, Alloc: 4}}
+	m := f.ToMapU64()
+	if v := m["mem.total"]; v != total {
+		t.Errorf("expected mem.total to be %d, got %v (%T)", total, v, v)
+	}
+	if v := m["mem.alloc"]; v != int64(5) {
+		t.Errorf("expected the modified mem.alloc to be left as is, got %v (%T)", v, v)
+	}
+	if v := m["mem.gc.count"]; v != int64(0) {
+		t.Errorf("expected mem.gc.count to be left as is without GC stats, got %v (%T)", v, v)
+	}
+
+	f = New(nil).OneOff()
+	if m := f.ToMapU64(); m["mem.gc.count"] != uint64(f.NumGC) || m["cpu.goroutines"] != f.NumGoroutine {
+		t.Errorf("unexpected types in %v", m)
+	}
+}
+
+func TestTier(t *testing.T) {
+	var local, remote []Fields
+	depth := 0.0
+	c := New(nil,
+		WithSink(SinkFunc(func(f Fields) error { local = append(local, f); return nil })),
+		WithTier(3, Mean, SinkFunc(func(f Fields) error { remote = append(remote, f); return nil })),
+	)
+	c.RegisterGauge("app.queue_depth", func() float64 { depth += 2; return depth })
+
+	for i := 0; i < 7; i++ {
+		c.OneOff()
+	}
+	if len(local) != 7 || len(remote) != 2 {
+		t.Fatalf("expected 7 local and 2 remote samples, got %d and %d", len(local), len(remote))
+	}
+	if v, _ := remote[1].Value("app.queue_depth"); v != 10 {
+		t.Errorf("expected the mean queue depth of the second window to be 10, got %v", v)
+	}
+	if remote[1].NumGC != local[5].NumGC || remote[1].Timestamp != local[5].Timestamp {
+		t.Errorf("expected counters and timestamps of the latest sample, got %+v", remote[1])
+	}
+	if exp := (local[3].HeapSys + local[4].HeapSys + local[5].HeapSys) / 3; remote[1].HeapSys < exp-1 || remote[1].HeapSys > exp+1 {
+		t.Errorf("expected the mean mem.heap.sys %d, got %d", exp, remote[1].HeapSys)
+	}
+}
+
+func TestSizeClasses(t *testing.T) {
+	sizeClassSink = make([]byte, 0, 100)
+	for _, rm := range []bool{false, true} {
+		c := New(nil)
+		c.EnableSizeClasses, c.UseRuntimeMetrics = true, rm
+		runtime.GC()
+		f := c.OneOff()
+		if f.Extra["mem.sizeclass.112.mallocs"] < 1 || f.Extra["mem.sizeclass.112.frees"] > f.Extra["mem.sizeclass.112.mallocs"] {
+			t.Errorf("runtime metrics %v: unexpected size class 112: %v", rm, f.Extra)
+		}
+
+		c.DenyFields = []string{"mem.sizeclass.*.frees"}
+		if f := c.OneOff(); f.Extra["mem.sizeclass.112.mallocs"] < 1 || f.Extra["mem.sizeclass.112.frees"] != 0 {
+			t.Errorf("runtime metrics %v: expected frees to be filtered out, got %v", rm, f.Extra)
+		}
+	}
+}
+
+var sizeClassSink []byte
+
+func TestStartupBaseline(t *testing.T) {
+	c := New(nil, WithStartupBaseline())
+	done := make(chan struct{})
+	defer close(done)
+	go func() { <-done }()
+	startupSink = make([]byte, 1<<20)
+
+	f := c.OneOff()
+	if f.StartupGoroutines < 1 || f.StartupAllocated < 1<<20 {
+		t.Errorf("expected the goroutine and allocation since New, got %d and %d", f.StartupGoroutines, f.StartupAllocated)
+	}
+
+	c.MarkStartup()
+	if f := c.OneOff(); f.StartupAllocated >= 1<<20 {
+		t.Errorf("expected the allocations since MarkStartup, got %d", f.StartupAllocated)
+	}
+	if f := New(nil).OneOff(); f.StartupAllocated != 0 {
+		t.Errorf("expected no startup fields unless enabled, got %d", f.StartupAllocated)
+	}
+}
+
+var startupSink []byte
+
+func TestIdentityTags(t *testing.T) {
+	c := New(nil, WithIdentityTags(), WithTags(map[string]string{"hostname": "web-1"}))
+	f := c.OneOff()
+	if f.Tags["go_version"] != runtime.Version() || f.Tags["pid"] != fmt.Sprint(os.Getpid()) {
+		t.Errorf("expected the Go version and PID as tags, got %v", f.Tags)
+	}
+	if f.Tags["hostname"] != "web-1" {
+		t.Errorf("expected Tags to take precedence over the identity tags, got %v", f.Tags)
+	}
+	if f := New(nil).OneOff(); f.Tags != nil {
+		t.Errorf("expected no tags unless enabled, got %v", f.Tags)
+	}
+}
+
+type bufferedSink struct {
+	depth int
+	rate  float64
+}
+
+func (s *bufferedSink) Emit(Fields) error                   { return nil }
+func (s *bufferedSink) Buffered() (depth int, rate float64) { return s.depth, s.rate }
+
+func TestSinkStatus(t *testing.T) {
+	a, b := &bufferedSink{depth: 10}, &bufferedSink{}
+	c := New(nil, WithSink(a), WithSink(b), WithSink(SinkFunc(func(Fields) error { return nil })))
+	c.OneOff()
+	a.depth, a.rate = 4, 2
+	f := c.OneOff()
+
+	st := c.SinkStatus()
+	if len(st) != 2 || st[0].Name != "bufferedsink" || st[1].Name != "bufferedsink_2" {
+		t.Fatalf("expected the two buffered sinks, got %+v", st)
+	}
+	if st[0].Depth != 4 || st[0].HighWater != 10 || st[0].Drain != 2*time.Second {
+		t.Errorf("unexpected status %+v", st[0])
+	}
+	// The fields report the buffers as of the previous emission.
+	if f.Extra["meta.sink.bufferedsink.depth"] != 10 || f.Extra["meta.sink.bufferedsink.drain_seconds"] != -1 {
+		t.Errorf("unexpected fields %v", f.Extra)
+	}
+	if s := c.Status(); len(s.SinkBuffers) != 2 {
+		t.Errorf("expected the buffers in the status, got %+v", s)
+	}
+}
+
+func TestProcCPU(t *testing.T) {
+	if got := cpuUtilization(procCPUTimes{UserNs: 1e9}, procCPUTimes{UserNs: 3e9, SystemNs: 5e8}, time.Second); got != 250 {
+		t.Errorf("expected 250%% for 2.5 CPU-seconds in a second, got %v", got)
+	}
+
+	c := New(nil)
+	c.EnableProcCPU = true
+	if _, ok := readProcCPU(); !ok {
+		t.Skip("process CPU time is not supported")
+	}
+	c.OneOff()
+	for deadline := time.Now().Add(20 * time.Millisecond); time.Now().Before(deadline); {
+	}
+	f := c.OneOff()
+	if f.ProcCPUUserNs+f.ProcCPUSystemNs <= 0 || f.ProcCPUUtilization <= 0 {
+		t.Errorf("expected CPU time and utilization, got %d, %d and %v", f.ProcCPUUserNs, f.ProcCPUSystemNs, f.ProcCPUUtilization)
+	}
+}
+
+func TestGoroutineStates(t *testing.T) {
+	var s goroutineStates
+	s.parse([]byte("goroutine 1 [running]:\nmain.main()\n\ngoroutine 7 [chan receive, 5 minutes]:\n" +
+		"goroutine 8 [select]:\ngoroutine 9 [sync.Mutex.Lock]:\ngoroutine 10 [IO wait]:\ngoroutine 11 [GC worker (idle)]:\n"))
+	if want := (goroutineStates{Running: 1, Chan: 2, Lock: 1, IOWait: 1, Other: 1}); s != want {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	for i := 0; i < 10; i++ {
+		go func() { <-block }()
+		go time.Sleep(time.Minute)
+	}
+	c := New(nil)
+	c.EnableGoroutineStates = true
+	f := c.OneOff()
+	for deadline := time.Now().Add(time.Second); (f.GoroutinesChan < 10 || f.GoroutinesSleep < 10) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond) // Until the goroutines blocked.
+		f = c.OneOff()
+	}
+	if f.GoroutinesChan < 10 || f.GoroutinesSleep < 10 || f.GoroutinesRunning < 1 {
+		t.Errorf("expected the blocked and sleeping goroutines, got %d and %d", f.GoroutinesChan, f.GoroutinesSleep)
+	}
+}
+
+func TestContention(t *testing.T) {
+	s := parseContention([]byte("--- mutex:\ncycles/second=2000000000\nsampling period=10\n" +
+		"2000000 4 @ 0x4df0f9 0x4df0f8\n#\t0x4df0f8\tmain.main+0x18\n\n1000000 1 @ 0x4df0f9\n"))
+	if want := (contention{WaitNs: 15000000, Events: 50}); s != want {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+
+	c := New(nil)
+	c.EnableContention, c.MutexProfileFraction, c.BlockProfileRate = true, 1, 1
+	defer runtime.SetMutexProfileFraction(0)
+	defer runtime.SetBlockProfileRate(0)
+	c.OneOff()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				mu.Lock()
+				time.Sleep(100 * time.Microsecond)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	f := c.OneOff()
+	if f.MutexEvents == 0 || f.MutexWaitNs == 0 || f.BlockEvents == 0 || f.BlockWaitNs == 0 {
+		t.Errorf("expected contention, got %d, %d, %d and %d", f.MutexEvents, f.MutexWaitNs, f.BlockEvents, f.BlockWaitNs)
+	}
+}
+
+func TestDebugState(t *testing.T) {
+	c := New(nil, WithHistory(5))
+	c.EnableDebugState = true
+	c.OneOff()
+	f := c.OneOff()
+
+	st := c.debugState
+	if st == nil || DebugStates[len(DebugStates)-1] != st {
+		t.Fatalf("expected the state to be registered once, got %v", DebugStates)
+	}
+	if st.Latest["mem.heap.alloc"] != f.HeapAlloc || len(st.History) != 2 {
+		t.Errorf("unexpected state %+v", st)
+	}
+	buf := &bytes.Buffer{}
+	if dump := writeDebug(buf); !strings.Contains(dump, `"mem.heap.alloc"`) || buf.String() != dump+"\n" {
+		t.Errorf("expected the latest sample in the dump, got %s", dump)
+	}
+}
+
+func TestTopAllocators(t *testing.T) {
+	if objects, bytes := scaleHeapSample(1, 512<<10, 512<<10); objects != 1 || bytes != 829411 {
+		t.Errorf("unexpected scaling to %d objects of %d bytes", objects, bytes)
+	}
+
+	retained = retainAllocs()
+	defer func() { retained = nil }()
+	runtime.GC()
+	runtime.GC() // The heap profile lags behind by a cycle.
+
+	var summaries [][]AllocSite
+	c := New(nil, WithTopAllocators(&TopAllocators{N: 3, Func: func(s []AllocSite) { summaries = append(summaries, s) }}))
+	f := c.OneOff()
+	c.OneOff()
+	if len(f.TopAllocators) == 0 || len(f.TopAllocators) > 3 || !strings.HasSuffix(f.TopAllocators[0].Function, ".retainAllocs") {
+		t.Fatalf("expected retainAllocs to top the heap, got %+v", f.TopAllocators)
+	}
+	if f.TopAllocators[0].InUseBytes < 32<<20 {
+		t.Errorf("expected an estimate of about 64 MiB, got %d", f.TopAllocators[0].InUseBytes)
+	}
+	if len(summaries) != 1 {
+		t.Errorf("expected one summary within the interval, got %d", len(summaries))
+	}
+}
+
+var retained [][]byte
+
+//go:noinline
+func retainAllocs() [][]byte {
+	b := make([][]byte, 64)
+	for i := range b {
+		b[i] = make([]byte, 1<<20)
+	}
+	return b
+}
+
+func TestFinalizers(t *testing.T) {
+	c := New(nil)
+	c.EnableFinalizers = true
+	if f := c.OneOff(); f.FinalizersQueued < f.FinalizersExecuted {
+		t.Errorf("expected no more finalizers executed than queued, got %d and %d", f.FinalizersQueued, f.FinalizersExecuted)
+	}
+
+	// The finalizer goroutine is blocked by the first object, queueing the others behind.
+	release := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		runtime.SetFinalizer(new([16]byte), func(*[16]byte) { <-release })
+	}
+	runtime.GC()
+	runtime.GC()
+	f := c.OneOff()
+	close(release)
+	if f.FinalizerBacklog < 1 {
+		t.Errorf("expected a finalizer backlog, got %d queued and %d executed", f.FinalizersQueued, f.FinalizersExecuted)
+	}
+
+	a := FinalizerBacklogAlert(10, time.Minute, 5*time.Minute)
+	if a.Rule.Field != "mem.finalizers.backlog" || a.Rule.Kind != Trend {
+		t.Errorf("unexpected rule %v", a.Rule)
+	}
+}
+
+func TestIDFunc(t *testing.T) {
+	ulid := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a := NewULID()
+	time.Sleep(2 * time.Millisecond)
+	b := NewULID()
+	if !ulid.MatchString(a) || a >= b {
+		t.Errorf("expected ULIDs sorting in order, got %s and %s", a, b)
+	}
+	if id := NewUUIDv7(); !uuid.MatchString(id) {
+		t.Errorf("unexpected UUID %s", id)
+	}
+
+	t.Setenv("GODEBUG", "gctrace=0")
+	var events []Event
+	c := New(nil, WithIDFunc(NewUUIDv7), WithEventSink(EventSinkFunc(func(e Event) error {
+		events = append(events, e)
+		return nil
+	})))
+	c.EnableRuntimeInfo = true
+	first, second := c.OneOff(), c.OneOff()
+	if !uuid.MatchString(first.ID) || first.ID == second.ID {
+		t.Errorf("expected a distinct ID on every sample, got %q and %q", first.ID, second.ID)
+	}
+	t.Setenv("GODEBUG", "gctrace=0,madvdontneed=1")
+	c.OneOff()
+	if len(events) != 1 || !uuid.MatchString(events[0].ID) {
+		t.Errorf("expected the event to carry an ID, got %v", events)
+	}
+	if f := New(nil).OneOff(); f.ID != "" {
+		t.Errorf("expected no ID without IDFunc, got %q", f.ID)
+	}
+}
+
+func TestFieldsGob(t *testing.T) {
+	c := New(nil, WithFields("mem.*"))
+	c.DenyFields = []string{"mem.gc.*"}
+	c.CriticalFields = []string{"mem.heap.alloc"}
+	f := c.OneOff()
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(f); err != nil {
+		t.Fatal(err)
+	}
+	var got Fields
+	if err := gob.NewDecoder(&b).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.ToMap(), f.ToMap()) || !reflect.DeepEqual(got.ToMapU64(), f.ToMapU64()) {
+		t.Errorf("expected the decoded sample to keep its fields, got %v", got.ToMap())
+	}
+	if _, ok := got.ToMap()["cpu.goroutines"]; ok {
+		t.Error("expected the filter to be kept")
+	}
+	if s := got.Series(); len(s) == 0 || s[0].Name != "mem.heap.alloc" {
+		t.Errorf("expected the critical fields to be kept, got %v", s)
+	}
+}
+
+func TestAlertOnFilteredField(t *testing.T) {
+	var states []AlertState
+	var errs []error
+	c := New(nil, WithAlert(&Alert{
+		Rule: Rule{Field: "app.queue_depth", Kind: Max, Limit: 10},
+		Func: func(st AlertState) { states = append(states, st) },
+	}))
+	c.ErrorFunc = func(err error) { errs = append(errs, err) }
+	c.DenyFields = []string{"app.*"}
+	c.RegisterGauge("app.queue_depth", func() float64 { return 20 })
+
+	f := c.OneOff()
+	if len(errs) != 0 || len(states) != 1 || !states[0].Firing {
+		t.Fatalf("expected the alert on the denied field to fire, got %v and %v", states, errs)
+	}
+	if _, ok := f.ToMap()["app.queue_depth"]; ok {
+		t.Error("expected the denied field to be left out of the sample")
+	}
+}
+
+func TestDefaultFields(t *testing.T) {
+	want := []string{
+		"cpu.cgo_calls", "cpu.goroutines",
+		"mem.alloc", "mem.frees", "mem.gc.count", "mem.gc.cpu_fraction", "mem.gc.last", "mem.gc.next",
+		"mem.gc.pause", "mem.gc.pause_total", "mem.gc.sys",
+		"mem.heap.alloc", "mem.heap.idle", "mem.heap.inuse", "mem.heap.objects", "mem.heap.released", "mem.heap.sys",
+		"mem.lookups", "mem.malloc", "mem.othersys",
+		"mem.stack.inuse", "mem.stack.mcache_inuse", "mem.stack.mcache_sys", "mem.stack.mspan_inuse", "mem.stack.mspan_sys", "mem.stack.sys",
+		"mem.sys", "mem.total",
+	}
+	c := New(nil)
+	c.OneOff()
+	f := c.OneOff()
+	m := f.ToMap()
+	got := make([]string, 0, len(m))
+	for name := range m {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the default fields %v, got %v", want, got)
+	}
+}
+
+func TestRunIgnoresSettingChanges(t *testing.T) {
+	samples := make(chan Fields, 1)
+	errs := make(chan error, 10)
+	c := New(func(f Fields) {
+		select {
+		case samples <- f:
+		default:
+		}
+	}, WithGC(false), WithInterval(time.Millisecond), WithErrorFunc(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.RunContext(ctx)
+		close(done)
+	}()
+	<-samples
+
+	c.mu.Lock()
+	c.EnableGC = true
+	c.mu.Unlock()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrSettingChanged) || !strings.Contains(err.Error(), "EnableGC") {
+			t.Errorf("expected the change of EnableGC to be reported, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the change to be reported")
+	}
+	<-samples
+	if f := <-samples; f.NextGC != 0 {
+		t.Errorf("expected GC statistics to stay disabled, got %+v", f)
+	}
+	cancel()
+	<-done
+
+	c.EnableGC = true
+	if f := c.OneOff(); f.NextGC == 0 {
+		t.Error("expected the settings to apply again once Run returned")
+	}
+}
+
+func TestValue(t *testing.T) {
+	c := New(nil, WithoutFields("mem.heap.*"))
+	c.RegisterGauge("app.queue_depth", func() float64 { return 3 })
+	f := c.OneOff()
+
+	m := f.ToMap()
+	for name, want := range m {
+		w, _ := want.(float64)
+		if n, isInt := want.(int64); isInt {
+			w = float64(n)
+		}
+		if v, ok := f.Value(name); !ok || v != w {
+			t.Errorf("%s: expected %v, got %v", name, want, v)
+		}
+	}
+	for _, name := range []string{"mem.heap.alloc", "proc.rss", "meta.timestamp", "app.unknown"} {
+		if v, ok := f.Value(name); ok {
+			t.Errorf("expected no value for %s, got %v", name, v)
+		}
+	}
+}
+
+func TestHeapGrowth(t *testing.T) {
+	c := New(nil)
+	prev := &runtime.MemStats{HeapAlloc: 1000, TotalAlloc: 5000, Mallocs: 100, Frees: 40}
+	m := &runtime.MemStats{HeapAlloc: 1300, TotalAlloc: 6000, Mallocs: 130, Frees: 60}
+	c.outputHeapGrowth(m, prev)
+
+	f := c.fields
+	if f.HeapGrowth != 300 || f.HeapGrowthAllocated != 1000 || f.HeapGrowthReclaimed != 700 || f.HeapGrowthObjects != 10 {
+		t.Errorf("unexpected heap growth %+v", f)
+	}
+	if f.HeapSurvivalRatio != 0.3 {
+		t.Errorf("expected a survival ratio of 0.3, got %v", f.HeapSurvivalRatio)
+	}
+
+	// A shrinking heap reclaimed more than was allocated.
+	c.fields = Fields{}
+	c.outputHeapGrowth(&runtime.MemStats{HeapAlloc: 800, TotalAlloc: 6000, Mallocs: 130, Frees: 140}, m)
+	if f := c.fields; f.HeapGrowth != -500 || f.HeapGrowthAllocated != 0 || f.HeapGrowthReclaimed != 500 || f.HeapGrowthObjects != -80 || f.HeapSurvivalRatio != 0 {
+		t.Errorf("unexpected heap shrinkage %+v", f)
+	}
+
+	c = New(nil, WithHeapGrowth(true))
+	c.OneOff()
+	if f := c.OneOff(); f.ToMap()["mem.heap.growth.allocated"] == nil {
+		t.Error("expected the heap growth once enabled")
+	}
+}
+
+func TestTHPMode(t *testing.T) {
+	for setting, want := range map[string]string{
+		"always [madvise] never":               "madvise",
+		"[always] defer defer+madvise madvise": "always",
+		"never":                                "never",
+	} {
+		if got := thpMode(setting); got != want {
+			t.Errorf("thpMode(%q) = %q, want %q", setting, got, want)
+		}
+	}
+}
+
+func TestSampleTime(t *testing.T) {
+	before := time.Now()
+	c := New(nil)
+	f := c.OneOff()
+
+	if at := f.Time(); at.Before(before) || at.After(time.Now()) {
+		t.Errorf("unexpected sample time %v", at)
+	}
+	if f.CollectDuration <= 0 {
+		t.Errorf("expected a collection duration, got %d", f.CollectDuration)
+	}
+	if _, ok := f.ToMap()["meta.timestamp"]; ok {
+		t.Error("expected the sample time to be left out of the metric values")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	d := 10 * time.Second
+	for _, tc := range []struct {
+		fraction, r float64
+		want        time.Duration
+	}{
+		{0.1, 0, 9 * time.Second},
+		{0.1, 0.5, 10 * time.Second},
+		{0.1, 0.75, 10500 * time.Millisecond},
+		{2, 0, 0},
+	} {
+		if got := jitter(d, tc.fraction, tc.r); got != tc.want {
+			t.Errorf("jitter(%v, %v, %v) = %v, want %v", d, tc.fraction, tc.r, got, tc.want)
+		}
+	}
+
+	c := New(nil, WithInterval(d), WithJitter(0.1))
+	for i := 0; i < 100; i++ {
+		if p := c.pause(); p < 9*time.Second || p > 11*time.Second {
+			t.Fatalf("pause %v outside of the jitter range", p)
+		}
+	}
+}
+
+func TestFDTypes(t *testing.T) {
+	var types fdTypes
+	for _, target := range []string{
+		"/var/log/app.log", "socket:[4821]", "socket:[4822]", "pipe:[77]", "anon_inode:[eventfd]",
+		"anon_inode:[eventpoll]", "anon_inode:[io_uring]", "anon_inode:inotify",
+	} {
+		types.add(target)
+	}
+	if want := (fdTypes{Files: 1, Sockets: 2, Pipes: 1, Eventfds: 1, Epolls: 1, IOUrings: 1, Other: 1}); types != want {
+		t.Errorf("got %+v, want %+v", types, want)
+	}
+}
+
+func TestAsyncEmit(t *testing.T) {
+	entered, release := make(chan struct{}, 10), make(chan struct{})
+	var emitted []Fields
+	c := New(nil, WithAsyncEmit(1, DropOldest), WithSink(SinkFunc(func(f Fields) error {
+		entered <- struct{}{}
+		<-release
+		emitted = append(emitted, f)
+		return nil
+	})))
+	n := 0.0
+	c.RegisterGauge("app.tick", func() float64 { n++; return n })
+
+	stop := c.startEmitter()
+	c.OneOff()
+	<-entered // The first sample blocks the sink, the second waits in the queue.
+	c.OneOff()
+	c.OneOff()
+	last := c.OneOff()
+	close(release)
+	stop()
+
+	if len(emitted) != 2 || emitted[0].Extra["app.tick"] != 1 || emitted[1].Extra["app.tick"] != 4 {
+		t.Fatalf("expected the first and last samples to be emitted, got %d", len(emitted))
+	}
+	if last.DroppedEmissions != 1 || c.droppedEmissions != 2 {
+		t.Errorf("expected 2 dropped emissions, 1 before the last sample, got %d and %d", c.droppedEmissions, last.DroppedEmissions)
+	}
+	if last.EmitQueueHighWater != 1 {
+		t.Errorf("expected a high-water mark of 1, got %d", last.EmitQueueHighWater)
+	}
+}
+
+func TestStream(t *testing.T) {
+	c := New(nil)
+	n := 0.0
+	c.RegisterGauge("app.tick", func() float64 { n++; return n })
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := c.Stream(ctx)
+
+	for i := 0; i < streamBuffer+2; i++ {
+		c.OneOff()
+	}
+	if f := <-stream; f.Extra["app.tick"] != 3 {
+		t.Errorf("expected the oldest samples to be discarded, got tick %v", f.Extra["app.tick"])
+	}
+
+	cancel()
+	received := 1
+	for range stream {
+		received++
+	}
+	if received != streamBuffer {
+		t.Errorf("expected %d samples before the stream closed, got %d", streamBuffer, received)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	infos := Describe()
+	if len(infos) != len(fieldNames()) {
+		t.Fatalf("expected every field to be described, got %d of %d", len(infos), len(fieldNames()))
+	}
+	for _, info := range infos {
+		if _, ok := fieldInfo[info.Name]; !ok || info.Help == "" {
+			t.Errorf("field %s has no description", info.Name)
+		}
+	}
+	if len(fieldInfo) != len(infos) {
+		t.Errorf("%d descriptions for %d fields", len(fieldInfo), len(infos))
+	}
+	if info, _ := DescribeField("mem.gc.count"); info.Type != Counter {
+		t.Errorf("expected mem.gc.count to be a counter, got %v", info.Type)
+	}
+}
+
+func TestCollectorDescribe(t *testing.T) {
+	c := New(nil, WithGC(false), WithoutFields("mem.stack.*"))
+	c.EnableCgroup = true
+	c.RegisterGauge("app.queue_depth", func() float64 { return 0 })
+
+	names := map[string]bool{}
+	for _, info := range c.Describe() {
+		names[info.Name] = true
+	}
+	for name, want := range map[string]bool{
+		"cpu.goroutines":           true,
+		"mem.heap.alloc":           true,
+		"mem.stack.inuse":          false,
+		"mem.gc.count":             false,
+		"mem.alloc_rate":           false,
+		"proc.cgroup.memory.limit": true,
+		"proc.rss":                 false,
+		"meta.timestamp":           false,
+		"meta.restarts":            false,
+		"app.queue_depth":          true,
+	} {
+		if names[name] != want {
+			t.Errorf("%s described: %v, want %v", name, names[name], want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	c := New(nil)
+	if _, ok := c.Snapshot(); ok {
+		t.Fatal("expected no snapshot before the first sample")
+	}
+	n := 0.0
+	c.RegisterGauge("app.tick", func() float64 { n++; return n })
+
+	first := c.OneOff()
+	second := c.OneOff()
+	if first.Extra["app.tick"] != 1 || second.Extra["app.tick"] != 2 {
+		t.Errorf("expected independent samples, got ticks %v and %v", first.Extra["app.tick"], second.Extra["app.tick"])
+	}
+	if f, ok := c.Snapshot(); !ok || f.Extra["app.tick"] != 2 || f.Timestamp != second.Timestamp {
+		t.Errorf("expected the latest sample, got %v", f.Extra)
+	}
+	if n != 2 {
+		t.Errorf("expected Snapshot not to collect, gauge read %v times", n)
+	}
+}
+
+func TestConcurrentOneOff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := New(nil, WithInterval(time.Millisecond))
+	go c.RunContext(ctx)
+
+	done := make(chan Fields)
+	for i := 0; i < 4; i++ {
+		go func() {
+			var f Fields
+			for j := 0; j < 20; j++ {
+				f = c.OneOff()
+			}
+			done <- f
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if f := <-done; f.NumGoroutine == 0 || f.Timestamp == 0 {
+			t.Errorf("expected a complete sample, got %d goroutines at %d", f.NumGoroutine, f.Timestamp)
+		}
+	}
+}
+
+func TestSelfCheck(t *testing.T) {
+	var reported []error
+	check := &SelfCheck{Window: 100 * time.Millisecond, Func: func(err error) { reported = append(reported, err) }}
+	c := New(nil, WithSelfCheck(check))
+
+	for i := 0; i < 15; i++ {
+		c.OneOff()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(reported) != 0 {
+		t.Fatalf("expected no leak while the footprint is stable, got %v", reported)
+	}
+
+	// Every stream holds a subscriber and a goroutine until its context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 30 && len(reported) == 0; i++ {
+		for j := 0; j < 5; j++ {
+			c.Stream(ctx)
+		}
+		if f := c.OneOff(); f.SelfGoroutines == 0 || f.SelfRetained == 0 {
+			t.Fatalf("expected the footprint to be reported, got %d goroutines and %d entries", f.SelfGoroutines, f.SelfRetained)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], ErrSelfLeak) {
+		t.Errorf("expected the leak to be reported once, got %v", reported)
+	}
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+)
+
+// contention is a summary of the mutex or block profile.
+type contention struct {
+	WaitNs int64
+	Events int64
+}
+
+// outputContention reports the totals of the mutex and block profiles, switching them on
+// first.
+func (c *Collector) outputContention() {
+	if !c.profilingContention {
+		c.profilingContention = true
+		if fraction := c.MutexProfileFraction; fraction >= 0 {
+			if fraction == 0 {
+				fraction = 100
+			}
+			runtime.SetMutexProfileFraction(fraction)
+		}
+		if rate := c.BlockProfileRate; rate >= 0 {
+			if rate == 0 {
+				rate = 10000
+			}
+			runtime.SetBlockProfileRate(rate)
+		}
+	}
+	if s, ok := c.readContention("mutex"); ok {
+		c.fields.MutexWaitNs, c.fields.MutexEvents = s.WaitNs, s.Events
+	}
+	if s, ok := c.readContention("block"); ok {
+		c.fields.BlockWaitNs, c.fields.BlockEvents = s.WaitNs, s.Events
+	}
+}
+
+// readContention sums the records of the mutex or block profile.
+func (c *Collector) readContention(name string) (contention, bool) {
+	c.profileBuf.Reset()
+	if err := pprof.Lookup(name).WriteTo(&c.profileBuf, 1); err != nil {
+		return contention{}, false
+	}
+	return parseContention(c.profileBuf.Bytes()), true
+}
+
+// parseContention sums the records of a mutex or block profile in the legacy text format,
+// which starts with a header such as
+//
+//	--- mutex:
+//	cycles/second=2000000000
+//	sampling period=100
+//
+// followed by a "<cycles> <count> @ <stack>" line per record. The mutex profile only holds
+// one in sampling period events, so its totals are scaled up, while the runtime already
+// scales those of the block profile.
+func parseContention(b []byte) contention {
+	var cyclesPerSecond, cycles, count float64
+	period := 1.0
+	for len(b) > 0 {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line, b = b[:i], b[i+1:]
+		} else {
+			b = nil
+		}
+		if v := string(line); strings.HasPrefix(v, "cycles/second=") {
+			cyclesPerSecond, _ = strconv.ParseFloat(v[len("cycles/second="):], 64)
+			continue
+		}
+		if v := string(line); strings.HasPrefix(v, "sampling period=") {
+			if p, err := strconv.ParseFloat(v[len("sampling period="):], 64); err == nil && p > 0 {
+				period = p
+			}
+			continue
+		}
+		f := bytes.Fields(line)
+		if len(f) < 3 || string(f[2]) != "@" {
+			continue
+		}
+		cy, err1 := strconv.ParseFloat(string(f[0]), 64)
+		n, err2 := strconv.ParseFloat(string(f[1]), 64)
+		if err1 == nil && err2 == nil {
+			cycles += cy
+			count += n
+		}
+	}
+	s := contention{Events: int64(count * period)}
+	if cyclesPerSecond > 0 {
+		s.WaitNs = int64(cycles * period / cyclesPerSecond * 1e9)
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DebugState is the state of a Collector laid out for a debugger such as Delve, which
+// prints plain structs, maps and slices but cannot call methods that take locks while the
+// process is stopped. See DebugStates.
+type DebugState struct {
+	Instance string
+
+	// Collected is when Latest was collected.
+	Collected time.Time
+
+	// Latest maps the name of every field of the latest sample to its value, as ToMap.
+	Latest map[string]interface{}
+	Tags   map[string]string
+
+	// History is the samples kept under HistorySize, oldest first.
+	History []TimestampedFields
+
+	// FiringAlerts names the Alerts firing as of Latest.
+	FiringAlerts []string
+}
+
+var (
+	debugMu sync.Mutex
+
+	// DebugStates holds the state of every Collector with EnableDebugState set, in the
+	// order they first collected, replaced after every collection. From a breakpoint,
+	//
+	//	(dlv) print collector.DebugStates[0].Latest["mem.heap.alloc"]
+	//	(dlv) call collector.DumpDebug()
+	//
+	// print a field of the latest sample and dump every state as JSON. Programs must not
+	// read it; they use Snapshot, History and Status instead.
+	DebugStates []*DebugState
+
+	// debugDump keeps DumpDebug in the binaries of the programs setting EnableDebugState,
+	// where the linker would otherwise drop it for being unused.
+	debugDump func() string
+)
+
+// updateDebugState mirrors the latest sample into the DebugState of c.
+func (c *Collector) updateDebugState(at time.Time) {
+	st := &DebugState{
+		Instance:  c.Instance,
+		Collected: at,
+		Latest:    c.fields.ToMap(),
+		Tags:      c.fields.Tags,
+		History:   c.history.all(),
+	}
+	for _, a := range c.Alerts {
+		if a.firing {
+			st.FiringAlerts = append(st.FiringAlerts, a.name())
+		}
+	}
+
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	debugDump = DumpDebug
+	if c.debugState == nil {
+		DebugStates = append(DebugStates, st)
+	} else {
+		for i, prev := range DebugStates {
+			if prev == c.debugState {
+				DebugStates[i] = st
+			}
+		}
+	}
+	c.debugState = st
+}
+
+// DumpDebug writes DebugStates to standard error as indented JSON and returns it, for
+// calling from a breakpoint. Should the process have stopped with DebugStates being
+// replaced, it dumps them anyway rather than waiting for a goroutine that cannot run.
+func DumpDebug() string {
+	return writeDebug(os.Stderr)
+}
+
+func writeDebug(w io.Writer) string {
+	if debugMu.TryLock() {
+		defer debugMu.Unlock()
+	}
+	b, err := json.MarshalIndent(DebugStates, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	w.Write(append(b, '\n'))
+	return string(b)
+}
@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"runtime"
+	"time"
+)
+
+// outputDeltas derives per-interval deltas and per-second rates of the cumulative counters
+// from the previous sample, taken elapsed ago.
+func (c *Collector) outputDeltas(m, prev *runtime.MemStats, elapsed time.Duration) {
+	rate := func(delta int64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return float64(delta) / elapsed.Seconds()
+	}
+
+	c.fields.TotalAllocDelta = int64(m.TotalAlloc - prev.TotalAlloc)
+	c.fields.AllocRate = rate(c.fields.TotalAllocDelta)
+	c.fields.MallocsDelta = int64(m.Mallocs - prev.Mallocs)
+	c.fields.MallocRate = rate(c.fields.MallocsDelta)
+	c.fields.FreesDelta = int64(m.Frees - prev.Frees)
+	c.fields.FreeRate = rate(c.fields.FreesDelta)
+	c.fields.PauseTotalNsDelta = int64(m.PauseTotalNs - prev.PauseTotalNs)
+	c.fields.PauseRate = rate(c.fields.PauseTotalNsDelta)
+	c.fields.NumGCDelta = int64(m.NumGC - prev.NumGC)
+	c.fields.GCRate = rate(c.fields.NumGCDelta)
+}
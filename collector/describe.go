@@ -0,0 +1,321 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+)
+
+// FieldType is how a field behaves over time.
+type FieldType string
+
+const (
+	// Gauge is a value that may go up and down.
+	Gauge FieldType = "gauge"
+
+	// Counter is a cumulative value that only increases while the process runs.
+	Counter FieldType = "counter"
+)
+
+// FieldInfo describes a field of Fields, for provisioning dashboards and alerts.
+type FieldInfo struct {
+	Name string    `json:"name"`
+	Type FieldType `json:"type"`
+
+	// Unit is the unit of the value, such as "bytes" or "seconds", and empty for counts.
+	Unit string `json:"unit,omitempty"`
+
+	Help string `json:"help"`
+}
+
+// Units used by FieldInfo.
+const (
+	unitBytes       = "bytes"
+	unitBytesPerSec = "bytes_per_second"
+	unitPerSec      = "per_second"
+	unitNanoseconds = "nanoseconds"
+	unitNsPerSec    = "nanoseconds_per_second"
+	unitSeconds     = "seconds"
+	unitPercent     = "percent"
+	unitRatio       = "ratio"
+	unitCPUs        = "cpus"
+	unitUnixNanos   = "unix_nanoseconds"
+)
+
+var fieldInfo = map[string]FieldInfo{
+	"cpu.goroutines": {Type: Gauge, Help: "Number of goroutines."},
+	"cpu.cgo_calls":  {Type: Counter, Help: "Number of cgo calls made by the process."},
+
+	"cpu.goroutines.running":  {Type: Gauge, Help: "Goroutines running on a thread."},
+	"cpu.goroutines.runnable": {Type: Gauge, Help: "Goroutines waiting for a thread to run on."},
+	"cpu.goroutines.syscall":  {Type: Gauge, Help: "Goroutines in a system call."},
+	"cpu.goroutines.io_wait":  {Type: Gauge, Help: "Goroutines waiting for network IO."},
+	"cpu.goroutines.chan":     {Type: Gauge, Help: "Goroutines blocked on a channel operation or select."},
+	"cpu.goroutines.lock":     {Type: Gauge, Help: "Goroutines blocked on a mutex, semaphore, condition variable or WaitGroup."},
+	"cpu.goroutines.sleep":    {Type: Gauge, Help: "Goroutines sleeping in time.Sleep."},
+	"cpu.goroutines.other":    {Type: Gauge, Help: "Goroutines waiting for anything else, such as the GC or finalizers."},
+
+	"mem.alloc":     {Type: Gauge, Unit: unitBytes, Help: "Bytes of allocated heap objects."},
+	"mem.total":     {Type: Counter, Unit: unitBytes, Help: "Cumulative bytes allocated for heap objects."},
+	"mem.sys":       {Type: Gauge, Unit: unitBytes, Help: "Total bytes of memory obtained from the OS."},
+	"mem.lookups":   {Type: Counter, Help: "Number of pointer lookups performed by the runtime."},
+	"mem.malloc":    {Type: Counter, Help: "Cumulative count of heap objects allocated."},
+	"mem.frees":     {Type: Counter, Help: "Cumulative count of heap objects freed."},
+	"mem.othersys":  {Type: Gauge, Unit: unitBytes, Help: "Bytes of memory in miscellaneous off-heap runtime allocations."},
+	"mem.heap.sys":  {Type: Gauge, Unit: unitBytes, Help: "Bytes of heap memory obtained from the OS."},
+	"mem.heap.idle": {Type: Gauge, Unit: unitBytes, Help: "Bytes in idle, unused heap spans."},
+
+	"mem.heap.alloc":    {Type: Gauge, Unit: unitBytes, Help: "Bytes of allocated heap objects."},
+	"mem.heap.inuse":    {Type: Gauge, Unit: unitBytes, Help: "Bytes in in-use heap spans."},
+	"mem.heap.released": {Type: Gauge, Unit: unitBytes, Help: "Bytes of physical memory returned to the OS."},
+	"mem.heap.objects":  {Type: Gauge, Help: "Number of allocated heap objects."},
+
+	"mem.stack.inuse":        {Type: Gauge, Unit: unitBytes, Help: "Bytes in stack spans."},
+	"mem.stack.sys":          {Type: Gauge, Unit: unitBytes, Help: "Bytes of stack memory obtained from the OS."},
+	"mem.stack.mspan_inuse":  {Type: Gauge, Unit: unitBytes, Help: "Bytes of allocated mspan structures."},
+	"mem.stack.mspan_sys":    {Type: Gauge, Unit: unitBytes, Help: "Bytes of memory obtained from the OS for mspan structures."},
+	"mem.stack.mcache_inuse": {Type: Gauge, Unit: unitBytes, Help: "Bytes of allocated mcache structures."},
+	"mem.stack.mcache_sys":   {Type: Gauge, Unit: unitBytes, Help: "Bytes of memory obtained from the OS for mcache structures."},
+
+	"mem.finalizers.queued":   {Type: Counter, Help: "Finalizers queued for execution by the GC."},
+	"mem.finalizers.executed": {Type: Counter, Help: "Finalizers executed."},
+	"mem.finalizers.backlog":  {Type: Gauge, Help: "Finalizers queued but not yet executed."},
+	"mem.cleanups.queued":     {Type: Counter, Help: "Cleanups of runtime.AddCleanup queued for execution by the GC."},
+	"mem.cleanups.executed":   {Type: Counter, Help: "Cleanups of runtime.AddCleanup executed."},
+	"mem.cleanups.backlog":    {Type: Gauge, Help: "Cleanups of runtime.AddCleanup queued but not yet executed."},
+
+	"mem.gc.sys":          {Type: Gauge, Unit: unitBytes, Help: "Bytes of memory in garbage collection metadata."},
+	"mem.gc.next":         {Type: Gauge, Unit: unitBytes, Help: "Target heap size of the next GC cycle."},
+	"mem.gc.last":         {Type: Gauge, Unit: unitUnixNanos, Help: "Time the last garbage collection finished."},
+	"mem.gc.pause_total":  {Type: Counter, Unit: unitNanoseconds, Help: "Cumulative time spent in GC stop-the-world pauses."},
+	"mem.gc.pause":        {Type: Gauge, Unit: unitNanoseconds, Help: "Duration of the most recent GC stop-the-world pause."},
+	"mem.gc.count":        {Type: Counter, Help: "Number of completed GC cycles."},
+	"mem.gc.cpu_fraction": {Type: Gauge, Unit: unitRatio, Help: "Fraction of available CPU time used by the GC since the program started."},
+	"mem.gc.pauses":       {Type: Gauge, Help: "Number of GC pauses since the previous collection."},
+	"mem.gc.pause_min":    {Type: Gauge, Unit: unitNanoseconds, Help: "Shortest GC pause since the previous collection."},
+	"mem.gc.pause_max":    {Type: Gauge, Unit: unitNanoseconds, Help: "Longest GC pause since the previous collection."},
+	"mem.gc.pause_mean":   {Type: Gauge, Unit: unitNanoseconds, Help: "Mean GC pause since the previous collection."},
+
+	"mem.heap.growth":                {Type: Gauge, Unit: unitBytes, Help: "Change in allocated heap bytes since the previous collection."},
+	"mem.heap.growth.allocated":      {Type: Gauge, Unit: unitBytes, Help: "Heap bytes allocated since the previous collection."},
+	"mem.heap.growth.reclaimed":      {Type: Gauge, Unit: unitBytes, Help: "Heap bytes reclaimed since the previous collection."},
+	"mem.heap.growth.objects":        {Type: Gauge, Help: "Change in the number of heap objects since the previous collection."},
+	"mem.heap.growth.survival_ratio": {Type: Gauge, Unit: unitRatio, Help: "Fraction of the bytes allocated since the previous collection that were not reclaimed."},
+
+	"mem.total_delta":          {Type: Gauge, Unit: unitBytes, Help: "Heap bytes allocated since the previous collection."},
+	"mem.alloc_rate":           {Type: Gauge, Unit: unitBytesPerSec, Help: "Heap bytes allocated per second since the previous collection."},
+	"mem.malloc_delta":         {Type: Gauge, Help: "Heap objects allocated since the previous collection."},
+	"mem.malloc_rate":          {Type: Gauge, Unit: unitPerSec, Help: "Heap objects allocated per second since the previous collection."},
+	"mem.frees_delta":          {Type: Gauge, Help: "Heap objects freed since the previous collection."},
+	"mem.frees_rate":           {Type: Gauge, Unit: unitPerSec, Help: "Heap objects freed per second since the previous collection."},
+	"mem.gc.pause_total_delta": {Type: Gauge, Unit: unitNanoseconds, Help: "Time spent in GC pauses since the previous collection."},
+	"mem.gc.pause_rate":        {Type: Gauge, Unit: unitNsPerSec, Help: "Time spent in GC pauses per second since the previous collection."},
+	"mem.gc.count_delta":       {Type: Gauge, Help: "GC cycles completed since the previous collection."},
+	"mem.gc.count_rate":        {Type: Gauge, Unit: unitPerSec, Help: "GC cycles completed per second since the previous collection."},
+
+	"runtime.gomaxprocs":       {Type: Gauge, Help: "GOMAXPROCS as set from the cgroup CPU quota."},
+	"runtime.gomaxprocs.quota": {Type: Gauge, Unit: unitCPUs, Help: "CPU quota of the cgroup GOMAXPROCS was derived from."},
+
+	"sched.latency.p50": {Type: Gauge, Unit: unitSeconds, Help: "Median time goroutines spent runnable before running, since the previous collection."},
+	"sched.latency.p95": {Type: Gauge, Unit: unitSeconds, Help: "95th percentile of the time goroutines spent runnable before running, since the previous collection."},
+	"sched.latency.p99": {Type: Gauge, Unit: unitSeconds, Help: "99th percentile of the time goroutines spent runnable before running, since the previous collection."},
+
+	"sync.mutex_wait_ns": {Type: Counter, Unit: unitNanoseconds, Help: "Time goroutines spent waiting for a contended mutex, estimated from the mutex profile."},
+	"sync.mutex_events":  {Type: Counter, Help: "Number of contended mutex acquisitions, estimated from the mutex profile."},
+	"sync.block_wait_ns": {Type: Counter, Unit: unitNanoseconds, Help: "Time goroutines spent blocked on synchronization primitives, estimated from the block profile."},
+	"sync.block_events":  {Type: Counter, Help: "Number of times goroutines blocked on synchronization primitives, estimated from the block profile."},
+
+	"proc.rss":     {Type: Gauge, Unit: unitBytes, Help: "Resident set size of the process."},
+	"proc.vsz":     {Type: Gauge, Unit: unitBytes, Help: "Virtual memory size of the process."},
+	"proc.fds":     {Type: Gauge, Help: "Number of open file descriptors."},
+	"proc.threads": {Type: Gauge, Help: "Number of OS threads."},
+
+	"startup.heap.alloc":   {Type: Gauge, Unit: unitBytes, Help: "Growth of the allocated heap objects since the startup baseline."},
+	"startup.heap.objects": {Type: Gauge, Help: "Growth of the number of allocated heap objects since the startup baseline."},
+	"startup.allocated":    {Type: Counter, Unit: unitBytes, Help: "Bytes allocated for heap objects since the startup baseline."},
+	"startup.sys":          {Type: Gauge, Unit: unitBytes, Help: "Growth of the memory obtained from the OS since the startup baseline."},
+	"startup.rss":          {Type: Gauge, Unit: unitBytes, Help: "Growth of the resident set size since the startup baseline."},
+	"startup.goroutines":   {Type: Gauge, Help: "Growth of the number of goroutines since the startup baseline."},
+
+	"proc.fds.files":    {Type: Gauge, Help: "Open file descriptors referring to files."},
+	"proc.fds.sockets":  {Type: Gauge, Help: "Open file descriptors referring to sockets."},
+	"proc.fds.pipes":    {Type: Gauge, Help: "Open file descriptors referring to pipes."},
+	"proc.fds.eventfds": {Type: Gauge, Help: "Open eventfd file descriptors."},
+	"proc.fds.epoll":    {Type: Gauge, Help: "Open epoll instances."},
+	"proc.fds.io_uring": {Type: Gauge, Help: "Open io_uring instances."},
+	"proc.fds.other":    {Type: Gauge, Help: "Open file descriptors of other types."},
+
+	"proc.affinity.cpus": {Type: Gauge, Unit: unitCPUs, Help: "Number of CPUs the process may be scheduled on."},
+	"proc.numa.nodes":    {Type: Gauge, Help: "Number of NUMA nodes the process has memory resident on."},
+
+	"proc.cpu.user":        {Type: Counter, Unit: unitNanoseconds, Help: "CPU time the process spent in user mode."},
+	"proc.cpu.system":      {Type: Counter, Unit: unitNanoseconds, Help: "CPU time the process spent in the kernel."},
+	"proc.cpu.utilization": {Type: Gauge, Unit: unitPercent, Help: "CPU time used since the previous collection, as a percentage of one CPU."},
+
+	"proc.thp.anon": {Type: Gauge, Unit: unitBytes, Help: "Anonymous memory backed by transparent huge pages."},
+
+	"proc.cgroup.memory.limit":            {Type: Gauge, Unit: unitBytes, Help: "Memory limit of the cgroup."},
+	"proc.cgroup.memory.usage":            {Type: Gauge, Unit: unitBytes, Help: "Memory usage of the cgroup."},
+	"proc.cgroup.memory.utilization":      {Type: Gauge, Unit: unitPercent, Help: "Memory usage of the cgroup as a percentage of its limit."},
+	"proc.cgroup.memory.heap_utilization": {Type: Gauge, Unit: unitPercent, Help: "Heap memory obtained from the OS as a percentage of the cgroup memory limit."},
+	"proc.cgroup.cpu.quota":               {Type: Gauge, Unit: unitCPUs, Help: "CPU quota of the cgroup, zero if unlimited."},
+	"proc.cgroup.cpu.periods":             {Type: Counter, Help: "Number of CPU enforcement periods elapsed."},
+	"proc.cgroup.cpu.throttled":           {Type: Counter, Help: "Number of CPU enforcement periods throttled."},
+	"proc.cgroup.cpu.throttled_time":      {Type: Counter, Unit: unitNanoseconds, Help: "Total time the cgroup was throttled."},
+	"proc.cgroup.cpu.throttled_ratio":     {Type: Gauge, Unit: unitPercent, Help: "Percentage of CPU periods throttled since the previous collection."},
+
+	"proc.children.count":      {Type: Gauge, Help: "Number of child processes."},
+	"proc.children.rss":        {Type: Gauge, Unit: unitBytes, Help: "Resident set size of the child processes."},
+	"proc.children.cpu_user":   {Type: Counter, Unit: unitNanoseconds, Help: "User CPU time of the child processes."},
+	"proc.children.cpu_system": {Type: Counter, Unit: unitNanoseconds, Help: "System CPU time of the child processes."},
+
+	"meta.experiment.arm":           {Type: Gauge, Help: "Active arm of the interval experiment, 0 for A and 1 for B."},
+	"meta.experiment.overhead_a":    {Type: Gauge, Unit: unitRatio, Help: "Fraction of wall time spent collecting while arm A was active."},
+	"meta.experiment.overhead_b":    {Type: Gauge, Unit: unitRatio, Help: "Fraction of wall time spent collecting while arm B was active."},
+	"meta.experiment.overhead_diff": {Type: Gauge, Unit: unitRatio, Help: "Overhead of arm B minus that of arm A."},
+	"meta.restarts":                 {Type: Gauge, Help: "Number of previous runs recorded in the state file."},
+	"meta.post_gc":                  {Type: Gauge, Help: "1 for samples taken right after a forced garbage collection."},
+	"meta.collect_duration_ns":      {Type: Gauge, Unit: unitNanoseconds, Help: "Time taken to collect the sample."},
+	"meta.dropped_emissions":        {Type: Counter, Help: "Number of samples discarded because the emission queue was full."},
+	"meta.emit_queue.depth":         {Type: Gauge, Help: "Number of samples waiting in the emission queue."},
+	"meta.emit_queue.high_water":    {Type: Gauge, Help: "Largest number of samples that waited in the emission queue."},
+	"meta.self.goroutines":          {Type: Gauge, Help: "Goroutines started by the collector package that are still running."},
+	"meta.self.retained":            {Type: Gauge, Help: "Samples, history entries, subscribers, silences and gauges held by the Collector."},
+	"meta.self.alloc":               {Type: Gauge, Unit: unitBytes, Help: "Bytes allocated by the process while the sample was collected."},
+}
+
+// Describe returns the description of every field of Fields, sorted by name. Fields
+// added to Extra, such as registered gauges and SLO burn rates, are not included.
+func Describe() []FieldInfo {
+	names := fieldNames()
+	infos := make([]FieldInfo, 0, len(names))
+	for _, name := range names {
+		info := fieldInfo[name]
+		info.Name = name
+		if info.Type == "" {
+			info.Type = Gauge
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// DescribeField returns the description of the named field of Fields.
+func DescribeField(name string) (FieldInfo, bool) {
+	info, ok := fieldInfo[name]
+	info.Name = name
+	return info, ok
+}
+
+// Describe returns the description of the fields c emits with its current configuration,
+// sorted by name: those of the enabled statistics that AllowFields and DenyFields let
+// through, followed by the registered gauges, which are described as gauges without help.
+func (c *Collector) Describe() []FieldInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ff := c.filter()
+
+	var infos []FieldInfo
+	for _, info := range Describe() {
+		if c.emits(info.Name) && (ff == nil || ff.allowed(info.Name)) {
+			infos = append(infos, info)
+		}
+	}
+	names := make([]string, 0, len(c.gauges))
+	for name := range c.gauges {
+		if ff == nil || ff.allowed(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		infos = append(infos, FieldInfo{Name: name, Type: Gauge})
+	}
+	return infos
+}
+
+// emits reports whether the statistics the named field belongs to are enabled.
+func (c *Collector) emits(name string) bool {
+	if i := emitRuleOf(name); i >= 0 {
+		return emitRules[i].enabled(c)
+	}
+	return true
+}
+
+// omitted returns the fields of Fields that c does not emit with its current
+// configuration, which ToMap leaves out of its samples.
+func (c *Collector) omitted() map[string]bool {
+	var omit map[string]bool
+	for _, name := range fieldNames() {
+		if !c.emits(name) {
+			if omit == nil {
+				omit = make(map[string]bool)
+			}
+			omit[name] = true
+		}
+	}
+	return omit
+}
+
+// emitRule ties the fields matching one of patterns to the configuration enabling them.
+type emitRule struct {
+	patterns []string
+	enabled  func(c *Collector) bool
+}
+
+// emitRules are tried in order, the more specific groups first. Fields matching none of
+// them are always emitted.
+var emitRules = []emitRule{
+	{goroutineStatesGroup, func(c *Collector) bool { return c.EnableGoroutineStates }},
+	{cpuGroup, func(c *Collector) bool { return c.EnableCPU }},
+	{deltasGroup, func(c *Collector) bool { return c.EnableMem && c.EnableDeltas }},
+	{pausesGroup, func(c *Collector) bool { return c.EnableMem && c.EnableGC && c.EnablePauses }},
+	{heapGrowthGroup, func(c *Collector) bool { return c.EnableMem && c.EnableHeapGrowth }},
+	{gcGroup, func(c *Collector) bool { return c.EnableMem && c.EnableGC }},
+	{finalizerGroup, func(c *Collector) bool { return c.EnableFinalizers }},
+	{sizeClassGroup, func(c *Collector) bool { return c.EnableMem && c.EnableSizeClasses }},
+	{memGroup, func(c *Collector) bool { return c.EnableMem }},
+	{contentionGroup, func(c *Collector) bool { return c.EnableContention }},
+	{schedGroup, func(c *Collector) bool { return c.EnableSched }},
+	{processGroup, func(c *Collector) bool { return c.EnableProcess }},
+	{procCPUGroup, func(c *Collector) bool { return c.EnableProcCPU }},
+	{fdTypesGroup, func(c *Collector) bool { return c.EnableFDTypes }},
+	{numaGroup, func(c *Collector) bool { return c.EnableNUMA }},
+	{thpGroup, func(c *Collector) bool { return c.EnableTHP }},
+	{cgroupGroup, func(c *Collector) bool { return c.EnableCgroup }},
+	{childrenGroup, func(c *Collector) bool { return c.EnableChildren }},
+	{startupGroup, func(c *Collector) bool { return c.EnableStartupBaseline }},
+	{[]string{"runtime.gomaxprocs*"}, func(c *Collector) bool { return c.AdjustMaxProcs }},
+	{[]string{"meta.experiment.*"}, func(c *Collector) bool { return c.Experiment != nil }},
+	{[]string{"meta.restarts"}, func(c *Collector) bool { return c.StateFile != "" }},
+	{[]string{"meta.dropped_emissions", "meta.emit_queue.*"}, func(c *Collector) bool { return c.EmitQueue > 0 }},
+	{[]string{"meta.self.*", "meta.collect_duration_ns"}, func(c *Collector) bool { return c.SelfCheck != nil }},
+	{[]string{"meta.post_gc"}, func(c *Collector) bool { return c.ForceGC }},
+}
+
+var (
+	emitRuleMu    sync.Mutex
+	emitRuleIndex = make(map[string]int)
+)
+
+// emitRuleOf returns the index of the first rule of emitRules matching name, or -1. The
+// index is computed once per name, as collections look up every field.
+func emitRuleOf(name string) int {
+	emitRuleMu.Lock()
+	defer emitRuleMu.Unlock()
+	if i, ok := emitRuleIndex[name]; ok {
+		return i
+	}
+	i := -1
+rules:
+	for j, r := range emitRules {
+		for _, p := range r.patterns {
+			if matchField(p, name) {
+				i = j
+				break rules
+			}
+		}
+	}
+	emitRuleIndex[name] = i
+	return i
+}
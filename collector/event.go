@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event is a notable occurrence detected by the Collector, such as an alert firing.
+type Event struct {
+	// Kind identifies the detector that raised the event, such as "memory_limit".
+	Kind string `json:"kind"`
+
+	// Name identifies the source of the event among those of the same Kind, such as the
+	// Name of an Alert.
+	Name string `json:"name,omitempty"`
+
+	// ID identifies the event when the Collector sets IDFunc.
+	ID string `json:"id,omitempty"`
+
+	Time time.Time `json:"time"`
+
+	// Instance is the Instance of the Collector that raised the event.
+	Instance string `json:"instance,omitempty"`
+
+	// Tags are the Tags of the Collector that raised the event.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Message is a human readable description of the event.
+	Message string `json:"message"`
+
+	// Field, Value and Threshold describe the measurement that caused the event, when it
+	// is a single value compared with a threshold.
+	Field     string  `json:"field,omitempty"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+
+	// Resolved is set on the event raised when the condition of an earlier event of the
+	// same Kind, Name, Instance and Field has cleared.
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+// EventSink receives the events raised by a Collector.
+type EventSink interface {
+	EmitEvent(Event) error
+}
+
+// EventSinkFunc is an adapter to allow the use of ordinary functions as an EventSink.
+type EventSinkFunc func(Event) error
+
+// EmitEvent implements EventSink.
+func (f EventSinkFunc) EmitEvent(e Event) error {
+	return f(e)
+}
+
+// AddEventSink registers s to receive every event.
+func (c *Collector) AddEventSink(s EventSink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.EventSinks = append(c.EventSinks, s)
+}
+
+// WithEventSink registers an event sink at construction.
+func WithEventSink(s EventSink) Option {
+	return func(c *Collector) { c.EventSinks = append(c.EventSinks, s) }
+}
+
+// emitEvent queues e for every event sink unless it is silenced. It is called with c.mu
+// held; unlockAndDeliverEvents passes the queued events on once the collection is done, so
+// that a slow or reentrant sink does not block the Collector.
+func (c *Collector) emitEvent(e Event) {
+	e.Instance = c.Instance
+	e.Tags = c.sampleTags()
+	if c.silences.silenced(e) {
+		return
+	}
+	if c.IDFunc != nil {
+		e.ID = c.IDFunc()
+	}
+	c.pendingEvents = append(c.pendingEvents, e)
+}
+
+// unlockAndDeliverEvents releases c.mu, then runs the callbacks queued during the
+// collection, such as the Func of MemoryLimitAlert, and passes the events queued by
+// emitEvent to every event sink, reporting failures to ErrorFunc.
+func (c *Collector) unlockAndDeliverEvents() {
+	calls, events, sinks := c.pendingCalls, c.pendingEvents, c.EventSinks
+	c.pendingCalls, c.pendingEvents = nil, nil
+	c.mu.Unlock()
+	for _, fn := range calls {
+		fn()
+	}
+	for _, e := range events {
+		for _, s := range sinks {
+			if err := s.EmitEvent(e); err != nil {
+				c.reportError(fmt.Errorf("collector: event sink %T: %w", s, err))
+			}
+		}
+	}
+}
+
+// event describes p as a "memory_limit" Event.
+func (p MemoryPressure) event() Event {
+	msg := fmt.Sprintf("memory usage is at %.0f%% of the %s limit", p.Ratio*100, p.Source)
+	if p.TimeToLimit > 0 {
+		msg += fmt.Sprintf(", projected to be reached in %s", p.TimeToLimit.Round(time.Second))
+	}
+	return Event{
+		Kind:      "memory_limit",
+		Time:      time.Now(),
+		Message:   msg,
+		Value:     float64(p.Used),
+		Threshold: float64(p.Limit),
+	}
+}
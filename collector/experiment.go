@@ -0,0 +1,69 @@
+package collector
+
+import "time"
+
+// Experiment alternates the collection interval of a Collector between two arms over
+// fixed windows and reports the overhead each arm costs, so the interval can be chosen
+// with evidence rather than by guessing. Overhead is the fraction of wall time spent
+// gathering statistics while the arm was active, and is reported through the
+// meta.experiment.* fields.
+type Experiment struct {
+	// A and B are the collection intervals being compared.
+	A, B time.Duration
+
+	// Window is how long each arm stays active before switching to the other one.
+	// Defaults to 10 minutes.
+	Window time.Duration
+
+	arm      int
+	armStart time.Time
+	wall     [2]time.Duration
+	busy     [2]time.Duration
+}
+
+// interval returns the collection interval of the active arm, switching arms when the
+// current window has elapsed.
+func (e *Experiment) interval(now time.Time) time.Duration {
+	window := e.Window
+	if window <= 0 {
+		window = 10 * time.Minute
+	}
+	if e.armStart.IsZero() {
+		e.armStart = now
+	}
+	if now.Sub(e.armStart) >= window {
+		e.wall[e.arm] += now.Sub(e.armStart)
+		e.arm = 1 - e.arm
+		e.armStart = now
+	}
+
+	if e.arm == 0 {
+		return e.A
+	}
+	return e.B
+}
+
+// record accounts the time spent on a collection to the active arm and writes the
+// current standing of the experiment to f.
+func (e *Experiment) record(took time.Duration, now time.Time, f *Fields) {
+	if e.armStart.IsZero() {
+		e.armStart = now
+	}
+	e.busy[e.arm] += took
+
+	var overhead [2]float64
+	for arm := range overhead {
+		wall := e.wall[arm]
+		if arm == e.arm {
+			wall += now.Sub(e.armStart)
+		}
+		if wall > 0 {
+			overhead[arm] = float64(e.busy[arm]) / float64(wall)
+		}
+	}
+
+	f.ExperimentArm = int64(e.arm)
+	f.ExperimentOverheadA = overhead[0]
+	f.ExperimentOverheadB = overhead[1]
+	f.ExperimentOverheadDiff = overhead[1] - overhead[0]
+}
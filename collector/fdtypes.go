@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"strings"
+	"time"
+)
+
+// fdTypes counts the open file descriptors of the process by what they refer to.
+type fdTypes struct {
+	Files, Sockets, Pipes, Eventfds, Epolls, IOUrings, Other int64
+}
+
+// add counts the descriptor whose /proc/<pid>/fd link points to target, such as
+// "socket:[123]" or "anon_inode:[eventpoll]".
+func (t *fdTypes) add(target string) {
+	switch {
+	case strings.HasPrefix(target, "/"):
+		t.Files++
+	case strings.HasPrefix(target, "socket:"):
+		t.Sockets++
+	case strings.HasPrefix(target, "pipe:"):
+		t.Pipes++
+	case target == "anon_inode:[eventfd]":
+		t.Eventfds++
+	case target == "anon_inode:[eventpoll]":
+		t.Epolls++
+	case target == "anon_inode:[io_uring]":
+		t.IOUrings++
+	default:
+		t.Other++
+	}
+}
+
+// outputFDTypes reports the open file descriptors by type. Classifying them takes a
+// system call per descriptor, so they are only read every FDTypesInterval and the previous
+// breakdown is reported in between.
+func (c *Collector) outputFDTypes(now time.Time) {
+	interval := c.FDTypesInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if c.fdTypesAt.IsZero() || now.Sub(c.fdTypesAt) >= interval {
+		if t, ok := readFDTypes(); ok {
+			c.fdTypes, c.fdTypesAt = t, now
+		}
+	}
+	t := c.fdTypes
+	c.fields.FDFiles = t.Files
+	c.fields.FDSockets = t.Sockets
+	c.fields.FDPipes = t.Pipes
+	c.fields.FDEventfds = t.Eventfds
+	c.fields.FDEpolls = t.Epolls
+	c.fields.FDIOUrings = t.IOUrings
+	c.fields.FDOther = t.Other
+}
@@ -0,0 +1,168 @@
+package collector
+
+import "sync"
+
+// fieldFilter restricts the fields of a sample to those matching allow, if any, and none
+// of deny. Patterns are field names or path.Match patterns.
+type fieldFilter struct {
+	allow []string
+	deny  []string
+}
+
+func (ff *fieldFilter) allowed(name string) bool {
+	for _, p := range ff.deny {
+		if matchField(p, name) {
+			return false
+		}
+	}
+	if len(ff.allow) == 0 {
+		return true
+	}
+	for _, p := range ff.allow {
+		if matchField(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithFields restricts the emitted fields to those matching patterns, which are field
+// names or path.Match patterns such as "mem.heap.*". See AllowFields.
+func WithFields(patterns ...string) Option {
+	return func(c *Collector) { c.AllowFields = append(c.AllowFields, patterns...) }
+}
+
+// WithoutFields drops the fields matching patterns from the emitted fields. See
+// DenyFields.
+func WithoutFields(patterns ...string) Option {
+	return func(c *Collector) { c.DenyFields = append(c.DenyFields, patterns...) }
+}
+
+// filter returns the filter applied to emitted samples, or nil if every field is emitted.
+func (c *Collector) filter() *fieldFilter {
+	if len(c.AllowFields) == 0 && len(c.DenyFields) == 0 {
+		return nil
+	}
+	return &fieldFilter{allow: c.AllowFields, deny: c.DenyFields}
+}
+
+// Field groups, described by patterns of the fields each collection step produces, used
+// to skip the steps whose fields are all filtered out.
+var (
+	cpuGroup             = []string{"cpu.*"}
+	goroutineStatesGroup = []string{"cpu.goroutines.*"}
+	memGroup             = []string{"mem.*"}
+	gcGroup              = []string{"mem.gc.*"}
+	pausesGroup          = []string{"mem.gc.pauses", "mem.gc.pause_min", "mem.gc.pause_max", "mem.gc.pause_mean", "mem.gc.pause.*"}
+	heapGrowthGroup      = []string{"mem.heap.growth*"}
+	sizeClassGroup       = []string{"mem.sizeclass.*"}
+	finalizerGroup       = []string{"mem.finalizers.*", "mem.cleanups.*"}
+	deltasGroup          = []string{"mem.*_delta", "mem.*_rate"}
+	schedGroup           = []string{"sched.*"}
+	contentionGroup      = []string{"sync.*"}
+	processGroup         = []string{"proc.rss", "proc.vsz", "proc.fds", "proc.threads"}
+	procCPUGroup         = []string{"proc.cpu.*"}
+	fdTypesGroup         = []string{"proc.fds.*"}
+	cgroupGroup          = []string{"proc.cgroup.*"}
+	numaGroup            = []string{"proc.affinity.*", "proc.numa.*"}
+	thpGroup             = []string{"proc.thp.*"}
+	childrenGroup        = []string{"proc.children.*"}
+	startupGroup         = []string{"startup.*"}
+)
+
+// collects reports whether a field of group passes the filter, or is needed by an SLO.
+func (c *Collector) collects(ff *fieldFilter, group []string) bool {
+	if ff == nil {
+		return true
+	}
+	for _, name := range fieldNames() {
+		for _, p := range group {
+			if matchField(p, name) && ff.allowed(name) {
+				return true
+			}
+		}
+	}
+	for _, slo := range c.SLOs {
+		for _, p := range group {
+			if matchField(p, slo.Field) {
+				return true
+			}
+		}
+	}
+	for _, a := range c.Alerts {
+		if ruleInGroup(a.Rule, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// keeps reports whether the dynamically named field passes the filter, or is needed by an
+// SLO or Alert, which are evaluated before the filter is applied to the sample.
+func (c *Collector) keeps(ff *fieldFilter, name string) bool {
+	if ff == nil || ff.allowed(name) {
+		return true
+	}
+	for _, slo := range c.SLOs {
+		if matchField(slo.Field, name) {
+			return true
+		}
+	}
+	for _, a := range c.Alerts {
+		if ruleApplies(a.Rule, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleApplies reports whether r, or any rule it is composed of, applies to the named field.
+func ruleApplies(r Rule, name string) bool {
+	if r.composite() {
+		for _, rules := range [][]Rule{r.All, r.Any} {
+			for _, sub := range rules {
+				if ruleApplies(sub, name) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return matchField(r.Field, name)
+}
+
+// ruleInGroup reports whether r, or any rule it is composed of, applies to a field of
+// group.
+func ruleInGroup(r Rule, group []string) bool {
+	if r.composite() {
+		for _, rules := range [][]Rule{r.All, r.Any} {
+			for _, sub := range rules {
+				if ruleInGroup(sub, group) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, p := range group {
+		if matchField(p, r.Field) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	fieldNamesOnce sync.Once
+	staticNames    []string
+)
+
+// fieldNames returns the names of the fields that are always present in ToMap.
+func fieldNames() []string {
+	fieldNamesOnce.Do(func() {
+		for name := range (&Fields{}).ToMap() {
+			staticNames = append(staticNames, name)
+		}
+	})
+	return staticNames
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// outputFinalizers reports the finalizers and cleanups queued and executed, and their
+// backlogs.
+func (c *Collector) outputFinalizers() {
+	if c.finalizerSamples == nil {
+		c.finalizerSamples = []metrics.Sample{
+			{Name: "/gc/finalizers/queued:finalizers"},
+			{Name: "/gc/finalizers/executed:finalizers"},
+			{Name: "/gc/cleanups/queued:cleanups"},
+			{Name: "/gc/cleanups/executed:cleanups"},
+		}
+	}
+	metrics.Read(c.finalizerSamples)
+	v := make([]int64, len(c.finalizerSamples))
+	for i, s := range c.finalizerSamples {
+		if s.Value.Kind() == metrics.KindUint64 {
+			v[i] = int64(s.Value.Uint64())
+		}
+	}
+	c.fields.FinalizersQueued, c.fields.FinalizersExecuted = v[0], v[1]
+	c.fields.CleanupsQueued, c.fields.CleanupsExecuted = v[2], v[3]
+	c.fields.FinalizerBacklog = backlog(v[0], v[1])
+	c.fields.CleanupBacklog = backlog(v[2], v[3])
+}
+
+// backlog returns the functions queued but not executed yet. The counters are read at
+// slightly different times, so the difference is clamped at zero.
+func backlog(queued, executed int64) int64 {
+	if queued < executed {
+		return 0
+	}
+	return queued - executed
+}
+
+// FinalizerBacklogAlert returns an Alert that fires when the backlog of finalizers grew
+// by more than growth finalizers per minute over window, and kept growing for sustained, the
+// telltale of finalizers that cannot keep up. EnableFinalizers must be set for it to see
+// the backlog. Set Func to respond, such as by shedding the load creating the finalized
+// objects, and Capture to find the goroutine the finalizers are stuck on.
+func FinalizerBacklogAlert(growth float64, window, sustained time.Duration) *Alert {
+	return &Alert{
+		Name: "finalizer_backlog",
+		Rule: Rule{Field: "mem.finalizers.backlog", Kind: Trend, Limit: growth, Per: time.Minute, Window: window, For: sustained},
+	}
+}
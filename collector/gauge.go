@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RegisterGauge registers fn to be sampled on every collection, its value emitted as the
+// field name alongside the runtime statistics. Registering a name again replaces its
+// gauge. fn is called while the Collector is busy and must not call back into it.
+// RegisterGauge panics if name is that of a built-in field.
+func (c *Collector) RegisterGauge(name string, fn func() float64) {
+	for _, builtin := range fieldNames() {
+		if name == builtin {
+			panic("collector: gauge name is a built-in field: " + name)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gauges == nil {
+		c.gauges = make(map[string]func() float64)
+	}
+	c.gauges[name] = fn
+}
+
+// UnregisterGauge removes the gauge registered under name, if any.
+func (c *Collector) UnregisterGauge(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.gauges, name)
+}
+
+// RegisterGauges registers fn to be called on every collection, each of the values it
+// returns emitted as a field alongside the runtime statistics, for gauges whose names are
+// only known at run time. Registering a name again replaces its function. Values named
+// after built-in fields are dropped, and errors reported to ErrorFunc. fn is called while
+// the Collector is busy and must not call back into it.
+func (c *Collector) RegisterGauges(name string, fn func() (map[string]float64, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gaugeSets == nil {
+		c.gaugeSets = make(map[string]func() (map[string]float64, error))
+	}
+	c.gaugeSets[name] = fn
+}
+
+// UnregisterGauges removes the function registered under name with RegisterGauges, if
+// any.
+func (c *Collector) UnregisterGauges(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.gaugeSets, name)
+}
+
+// outputGauges samples the registered gauges in name order, skipping those filtered out
+// that no SLO or Alert needs, then the gauge sets in name order.
+func (c *Collector) outputGauges(ff *fieldFilter) {
+	names := make([]string, 0, len(c.gauges))
+	for name := range c.gauges {
+		if c.keeps(ff, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.fields.setExtra(name, c.gauges[name]())
+	}
+
+	names = names[:0]
+	for name := range c.gaugeSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values, err := c.gaugeSets[name]()
+		if err != nil {
+			c.reportError(fmt.Errorf("collector: gauges %q: %w", name, err))
+		}
+		for k, v := range values {
+			if _, builtin := builtinField(k); !builtin && c.keeps(ff, k) {
+				c.fields.setExtra(k, v)
+			}
+		}
+	}
+}
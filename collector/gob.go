@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/gob"
+	"runtime"
+)
+
+// plainFields has the fields of Fields but not its methods, so that gob encodes it field
+// by field rather than calling GobEncode.
+type plainFields Fields
+
+// gobFields is the gob encoding of Fields. It carries the unexported state ToMap, ToMapU64
+// and Series depend on, so that a sample sent to a plugin or spooled to disk renders the
+// fields the Collector emitted, and no others.
+type gobFields struct {
+	Fields    plainFields
+	Filtered  bool
+	Allow     []string
+	Deny      []string
+	Critical  []string
+	Omit      map[string]bool
+	MemStats  *runtime.MemStats
+	GCStats   *runtime.MemStats
+	SameStats bool // GCStats is MemStats
+}
+
+// GobEncode implements gob.GobEncoder.
+func (f Fields) GobEncode() ([]byte, error) {
+	g := gobFields{Fields: plainFields(f), Critical: f.critical, Omit: f.omit, MemStats: f.memStats}
+	if f.filter != nil {
+		g.Filtered, g.Allow, g.Deny = true, f.filter.allow, f.filter.deny
+	}
+	if f.gcStats != nil && f.gcStats == f.memStats {
+		g.SameStats = true
+	} else {
+		g.GCStats = f.gcStats
+	}
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(&g)
+	return b.Bytes(), err
+}
+
+// GobDecode implements gob.GobDecoder.
+func (f *Fields) GobDecode(b []byte) error {
+	var g gobFields
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&g); err != nil {
+		return err
+	}
+	*f = Fields(g.Fields)
+	f.critical, f.omit, f.memStats, f.gcStats = g.Critical, g.Omit, g.MemStats, g.GCStats
+	if g.SameStats {
+		f.gcStats = g.MemStats
+	}
+	if g.Filtered {
+		f.filter = &fieldFilter{allow: g.Allow, deny: g.Deny}
+	}
+	return nil
+}
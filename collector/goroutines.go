@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// goroutineStates counts goroutines by what they are doing.
+type goroutineStates struct {
+	Running, Runnable, Syscall, IOWait, Chan, Lock, Sleep, Other int64
+}
+
+// add counts the goroutine whose stack dump header carries state, such as "chan receive"
+// in "goroutine 7 [chan receive, 5 minutes]:".
+func (s *goroutineStates) add(state string) {
+	if i := strings.IndexByte(state, ','); i >= 0 {
+		state = state[:i]
+	}
+	switch {
+	case state == "running":
+		s.Running++
+	case state == "runnable", state == "preempted":
+		s.Runnable++
+	case state == "syscall":
+		s.Syscall++
+	case state == "IO wait":
+		s.IOWait++
+	case strings.HasPrefix(state, "chan "), strings.HasPrefix(state, "select"):
+		s.Chan++
+	case state == "semacquire", strings.HasPrefix(state, "sync."):
+		s.Lock++
+	case state == "sleep":
+		s.Sleep++
+	default:
+		s.Other++
+	}
+}
+
+// parse counts the goroutines of a dump in the format of runtime.Stack.
+func (s *goroutineStates) parse(dump []byte) {
+	prefix := []byte("goroutine ")
+	for len(dump) > 0 {
+		line := dump
+		if i := bytes.IndexByte(dump, '\n'); i >= 0 {
+			line, dump = dump[:i], dump[i+1:]
+		} else {
+			dump = nil
+		}
+		if !bytes.HasPrefix(line, prefix) || !bytes.HasSuffix(line, []byte("]:")) {
+			continue
+		}
+		if i := bytes.IndexByte(line, '['); i >= 0 {
+			s.add(string(line[i+1 : len(line)-2]))
+		}
+	}
+}
+
+// outputGoroutineStates reports the goroutines by state, counted from a dump of every
+// stack at most once per GoroutineStatesInterval.
+func (c *Collector) outputGoroutineStates(now time.Time) {
+	if c.goroutineStatesAt.IsZero() || now.Sub(c.goroutineStatesAt) >= c.GoroutineStatesInterval {
+		c.goroutineStates, c.goroutineStatesAt = c.countGoroutines(), now
+	}
+	s := c.goroutineStates
+	c.fields.GoroutinesRunning = s.Running
+	c.fields.GoroutinesRunnable = s.Runnable
+	c.fields.GoroutinesSyscall = s.Syscall
+	c.fields.GoroutinesIOWait = s.IOWait
+	c.fields.GoroutinesChan = s.Chan
+	c.fields.GoroutinesLock = s.Lock
+	c.fields.GoroutinesSleep = s.Sleep
+	c.fields.GoroutinesOther = s.Other
+}
+
+// countGoroutines dumps every stack into stackBuf, growing it until the dump fits, and
+// counts the goroutines by state.
+func (c *Collector) countGoroutines() goroutineStates {
+	if len(c.stackBuf) == 0 {
+		c.stackBuf = make([]byte, 64<<10)
+	}
+	n := runtime.Stack(c.stackBuf, true)
+	for n == len(c.stackBuf) {
+		c.stackBuf = make([]byte, 2*len(c.stackBuf))
+		n = runtime.Stack(c.stackBuf, true)
+	}
+	var s goroutineStates
+	s.parse(c.stackBuf[:n])
+	return s
+}
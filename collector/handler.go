@@ -0,0 +1,36 @@
+//go:build !runtimemetrics_minimal
+
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler returns an http.Handler that serves the most recent sample as JSON, keyed by
+// field name. With the query parameter fresh=1, or before the first collection, it
+// collects a new sample instead, which is emitted to the FieldsFunc and sinks like any
+// other.
+func (c *Collector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		f, ok := c.Snapshot()
+		if fresh := r.URL.Query().Get("fresh"); !ok || fresh == "1" || fresh == "true" {
+			f = c.OneOff()
+		}
+
+		b, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(append(b, '\n'))
+	})
+}
@@ -0,0 +1,47 @@
+//go:build !runtimemetrics_minimal
+
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var collections int
+	c := New(func(Fields) { collections++ })
+	h := c.Handler()
+
+	get := func(url string) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	if m := get("/"); m["cpu.goroutines"] == nil || collections != 1 {
+		t.Errorf("expected a collection before the first sample, got %d", collections)
+	}
+	get("/")
+	if collections != 1 {
+		t.Errorf("expected the latest sample to be served, got %d collections", collections)
+	}
+	get("/?fresh=1")
+	if collections != 2 {
+		t.Errorf("expected a fresh collection, got %d", collections)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected POST to be rejected, got %d", rec.Code)
+	}
+}
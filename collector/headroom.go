@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// The latest values published by any Collector. They are written once per collection and
+// read without locks, so hot paths can consult them on every request.
+var (
+	heapHeadroom   int64
+	goroutineCount int64
+)
+
+// HeapHeadroomBytes returns how many more bytes the process could use, as of the latest
+// collection, before reaching the nearest of GOMEMLIMIT and its cgroup memory limit. When
+// neither limit is set, it returns the distance of the heap to the next GC target instead.
+// The value is negative once a limit has been exceeded.
+func HeapHeadroomBytes() int64 {
+	return atomic.LoadInt64(&heapHeadroom)
+}
+
+// GoroutineCount returns the number of goroutines observed by the latest collection.
+func GoroutineCount() int64 {
+	return atomic.LoadInt64(&goroutineCount)
+}
+
+func publishHeadroom(m *runtime.MemStats) {
+	headroom := int64(math.MaxInt64)
+	if limit := debug.SetMemoryLimit(-1); limit > 0 && limit < math.MaxInt64 {
+		headroom = limit - int64(m.Sys-m.HeapReleased)
+	}
+	if limit, ok := cgroupMemoryLimit(); ok {
+		if rss, ok := readRSS(); ok && limit-rss < headroom {
+			headroom = limit - rss
+		}
+	}
+	if headroom == math.MaxInt64 {
+		headroom = int64(m.NextGC) - int64(m.HeapAlloc)
+	}
+	atomic.StoreInt64(&heapHeadroom, headroom)
+}
+
+func publishGoroutines(n int64) {
+	atomic.StoreInt64(&goroutineCount, n)
+}
@@ -0,0 +1,77 @@
+package collector
+
+import "time"
+
+// TimestampedFields is a sample kept in the history of a Collector, along with the time
+// it was collected.
+type TimestampedFields struct {
+	Time time.Time
+	Fields
+}
+
+// history is a ring buffer of the most recent samples.
+type history struct {
+	samples []TimestampedFields
+	next    int
+	full    bool
+}
+
+func (h *history) record(size int, s TimestampedFields) {
+	if len(h.samples) != size {
+		h.resize(size)
+	}
+	h.samples[h.next] = s
+	h.next++
+	if h.next == size {
+		h.next, h.full = 0, true
+	}
+}
+
+// resize keeps the most recent samples that fit in size.
+func (h *history) resize(size int) {
+	all := h.all()
+	if len(all) > size {
+		all = all[len(all)-size:]
+	}
+	h.samples = make([]TimestampedFields, size)
+	h.next = copy(h.samples, all)
+	h.full = h.next == size
+	if h.full {
+		h.next = 0
+	}
+}
+
+// all returns the samples in the order they were collected.
+func (h *history) all() []TimestampedFields {
+	if !h.full {
+		return append([]TimestampedFields(nil), h.samples[:h.next]...)
+	}
+	all := make([]TimestampedFields, 0, len(h.samples))
+	all = append(all, h.samples[h.next:]...)
+	return append(all, h.samples[:h.next]...)
+}
+
+// WithHistory keeps the last n samples, to be inspected with History and Since.
+func WithHistory(n int) Option {
+	return func(c *Collector) { c.HistorySize = n }
+}
+
+// History returns the samples kept under HistorySize, oldest first. It returns nil if
+// HistorySize is not set.
+func (c *Collector) History() []TimestampedFields {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.history.all()
+}
+
+// Since returns the samples kept under HistorySize that were collected after t, oldest
+// first, such as the last 10 minutes with Since(time.Now().Add(-10 * time.Minute)).
+func (c *Collector) Since(t time.Time) []TimestampedFields {
+	all := c.History()
+	for i, s := range all {
+		if s.Time.After(t) {
+			return all[i:]
+		}
+	}
+	return nil
+}
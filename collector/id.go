@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// WithIDFunc sets IDFunc, such as NewULID or NewUUIDv7.
+func WithIDFunc(fn func() string) Option {
+	return func(c *Collector) { c.IDFunc = fn }
+}
+
+// crockford is the alphabet of ULIDs, which leaves out I, L, O and U.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a ULID, 26 characters sorting in the order they were generated, to
+// the millisecond, such as "01HZX3M7Q0K8C2V5R9T1W4YB6N".
+func NewULID() string {
+	var b [16]byte
+	putMillis(b[:6], time.Now())
+	rand.Read(b[6:])
+
+	// The 128 bits are encoded 5 at a time, the first character holding the top 3.
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var s [26]byte
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// NewUUIDv7 returns a version 7 UUID as defined by RFC 9562, whose leading timestamp
+// makes them sort in the order they were generated, to the millisecond, such as
+// "0190163d-8694-739b-aea5-966c26f8ad91".
+func NewUUIDv7() string {
+	var b [16]byte
+	putMillis(b[:6], time.Now())
+	rand.Read(b[6:])
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// putMillis writes the Unix time of t in milliseconds to the 6 bytes of b, big-endian.
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
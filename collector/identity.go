@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+var (
+	identityOnce sync.Once
+	identity     map[string]string
+)
+
+// WithIdentityTags enables the build and process identity tags, see EnableIdentityTags.
+func WithIdentityTags() Option {
+	return func(c *Collector) { c.EnableIdentityTags = true }
+}
+
+// identityTags returns the tags added under EnableIdentityTags, which are read once and
+// must not be modified.
+func identityTags() map[string]string {
+	identityOnce.Do(func() {
+		readBuildSettings()
+		identity = map[string]string{
+			"go_version": runtime.Version(),
+			"pid":        strconv.Itoa(os.Getpid()),
+		}
+		for k, v := range map[string]string{
+			"module_path":    modulePath,
+			"module_version": moduleVersion,
+			"vcs_revision":   vcsRevision,
+			"vcs_modified":   vcsModified,
+		} {
+			if v != "" {
+				identity[k] = v
+			}
+		}
+		if host, err := os.Hostname(); err == nil && host != "" {
+			identity["hostname"] = host
+		}
+	})
+	return identity
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"time"
+)
+
+// adjustMaxProcs sets GOMAXPROCS to the CPU quota of the process's cgroup, rounded down,
+// and raises a "gomaxprocs" event when it changes it. An explicit GOMAXPROCS environment
+// variable is respected.
+func (c *Collector) adjustMaxProcs() {
+	st, _ := readCgroupStats()
+	c.fields.MaxProcsQuota = st.CPUQuota
+
+	current := runtime.GOMAXPROCS(0)
+	if want := maxProcsFor(st.CPUQuota, runtime.NumCPU()); want != current && os.Getenv("GOMAXPROCS") == "" {
+		runtime.GOMAXPROCS(want)
+		c.emitEvent(Event{
+			Kind:      "gomaxprocs",
+			Time:      time.Now(),
+			Message:   fmt.Sprintf("GOMAXPROCS changed from %d to %d for a CPU quota of %v", current, want, st.CPUQuota),
+			Field:     "runtime.gomaxprocs",
+			Value:     float64(want),
+			Threshold: st.CPUQuota,
+		})
+		current = want
+	}
+	c.fields.MaxProcs = int64(current)
+}
+
+// maxProcsFor returns the GOMAXPROCS matching a CPU quota, zero meaning unlimited.
+func maxProcsFor(quota float64, numCPU int) int {
+	if quota <= 0 {
+		return numCPU
+	}
+	n := int(math.Floor(quota))
+	if n < 1 {
+		n = 1
+	}
+	if n > numCPU {
+		n = numCPU
+	}
+	return n
+}
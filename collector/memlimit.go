@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// MemoryPressure describes how close the process is to the nearest of its memory limits.
+type MemoryPressure struct {
+	// Source names the limit being approached, either "gomemlimit" or "cgroup".
+	Source string
+
+	// Limit is the limit in bytes.
+	Limit int64
+
+	// Used is the memory in bytes that counts against Limit. For GOMEMLIMIT this is the
+	// memory mapped by the runtime minus what it returned to the OS, for the cgroup limit
+	// it is the usage of the whole cgroup, page cache and other processes included, as
+	// the kernel accounts it. The resident set size of the process stands in when the
+	// usage cannot be read.
+	Used int64
+
+	// Ratio is Used divided by Limit.
+	Ratio float64
+
+	// TimeToLimit is the projected time until Used reaches Limit at the growth rate seen
+	// since the previous collection. It is zero when usage is not growing.
+	TimeToLimit time.Duration
+}
+
+// MemoryLimitAlert watches GOMEMLIMIT proximity and the usage of the cgroup against its
+// memory limit, and invokes Func before the process is likely to be OOM killed.
+// It requires memory statistics to be enabled on the Collector. Every time Func is invoked
+// the Collector also emits a "memory_limit" Event, followed by a resolved one once the
+// pressure has subsided.
+type MemoryLimitAlert struct {
+	// LeadTime is how long before the projected time of reaching the limit Func is
+	// invoked. Defaults to 1 minute.
+	LeadTime time.Duration
+
+	// Threshold is the usage ratio at which Func is invoked regardless of growth rate.
+	// Defaults to 0.9.
+	Threshold float64
+
+	// Func is invoked once each time the process enters the danger zone, and again only
+	// after the pressure has subsided. It is a good place to drop caches or shed load. It
+	// is called once the collection is done, so it may call back into the Collector.
+	Func func(MemoryPressure)
+
+	lastUsed map[string]int64
+	lastTime time.Time
+	firing   bool
+}
+
+// check evaluates the memory limits against the latest memory statistics and reports the
+// pressure if the alert fired or resolved.
+func (a *MemoryLimitAlert) check(m *runtime.MemStats, now time.Time) (MemoryPressure, bool) {
+	var candidates []MemoryPressure
+	if limit := debug.SetMemoryLimit(-1); limit > 0 && limit < math.MaxInt64 {
+		candidates = append(candidates, MemoryPressure{
+			Source: "gomemlimit",
+			Limit:  limit,
+			Used:   int64(m.Sys - m.HeapReleased),
+		})
+	}
+	if limit, ok := cgroupMemoryLimit(); ok {
+		used, ok := cgroupMemoryUsage()
+		if !ok {
+			used, ok = readRSS()
+		}
+		if ok {
+			candidates = append(candidates, MemoryPressure{Source: "cgroup", Limit: limit, Used: used})
+		}
+	}
+	return a.observe(candidates, now)
+}
+
+// observe projects the time to limit for each candidate. It returns the pressure of the
+// one under the most pressure and whether the alert fired or resolved, in which case the
+// caller invokes Func if it fired.
+func (a *MemoryLimitAlert) observe(candidates []MemoryPressure, now time.Time) (MemoryPressure, bool) {
+	lead := a.LeadTime
+	if lead <= 0 {
+		lead = time.Minute
+	}
+	threshold := a.Threshold
+	if threshold <= 0 {
+		threshold = 0.9
+	}
+
+	elapsed := now.Sub(a.lastTime)
+	used := make(map[string]int64, len(candidates))
+	breach := false
+	var worst MemoryPressure
+	for i, p := range candidates {
+		p.Ratio = float64(p.Used) / float64(p.Limit)
+		if last, ok := a.lastUsed[p.Source]; ok && p.Used > last && p.Used < p.Limit && elapsed > 0 {
+			rate := float64(p.Used-last) / elapsed.Seconds()
+			p.TimeToLimit = time.Duration(float64(p.Limit-p.Used) / rate * float64(time.Second))
+		}
+		used[p.Source] = p.Used
+
+		b := p.Ratio >= threshold || (p.TimeToLimit > 0 && p.TimeToLimit <= lead)
+		if i == 0 || (b && !breach) || (b == breach && p.Ratio > worst.Ratio) {
+			worst = p
+		}
+		breach = breach || b
+	}
+	a.lastUsed, a.lastTime = used, now
+
+	changed := breach != a.firing
+	a.firing = breach
+	return worst, changed
+}
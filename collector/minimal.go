@@ -0,0 +1,13 @@
+//go:build runtimemetrics_minimal
+
+package collector
+
+import "time"
+
+// The runtimemetrics_minimal build tag strips the OS collectors, SLO evaluation, profile
+// capture and the HTTP handlers. Batching, tiers, alerts and history are still built in.
+// Configured SLOs and the Capture of alerts are ignored in such builds.
+
+func (s *SLO) observe(f *Fields, now time.Time) {}
+
+func (p *ProfileCapture) capture(name string, t time.Time, report func(error)) {}
@@ -0,0 +1,77 @@
+package collector
+
+import "errors"
+
+// ErrNoSink is reported to ErrorFunc when Run collects a sample that nothing receives: no
+// FieldsFunc, Sink, subscriber, History, Tier or EventSink.
+var ErrNoSink = errors.New("collector: no FieldsFunc, Sink or other consumer attached, samples are discarded")
+
+// NoSinkPolicy determines what Run does with samples while nothing receives them.
+type NoSinkPolicy int
+
+const (
+	// NoSinkWarn discards samples and reports ErrNoSink once.
+	NoSinkWarn NoSinkPolicy = iota
+
+	// NoSinkDrop silently discards samples.
+	NoSinkDrop
+
+	// NoSinkBuffer keeps the most recent BufferSize samples and replays them to the first
+	// Sink added with AddSink.
+	NoSinkBuffer
+)
+
+// defaultBufferSize is the number of samples kept under NoSinkBuffer when BufferSize is
+// not set.
+const defaultBufferSize = 60
+
+// WithNoSinkPolicy sets what Run does with samples while nothing receives them.
+func WithNoSinkPolicy(p NoSinkPolicy) Option {
+	return func(c *Collector) { c.NoSink = p }
+}
+
+// collect gathers a sample for Run and applies NoSink if nothing received it.
+func (c *Collector) collect() {
+	f := c.outputStats(false)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.consumed() {
+		return
+	}
+
+	switch c.NoSink {
+	case NoSinkWarn:
+		if !c.warnedNoSink {
+			c.warnedNoSink = true
+			c.reportError(ErrNoSink)
+		}
+	case NoSinkBuffer:
+		size := c.BufferSize
+		if size <= 0 {
+			size = defaultBufferSize
+		}
+		if len(c.buffered) >= size {
+			c.buffered = append(c.buffered[:0], c.buffered[len(c.buffered)-size+1:]...)
+		}
+		c.buffered = append(c.buffered, f)
+	}
+}
+
+// consumed reports whether anything receives the samples of c: a FieldsFunc, Sink or
+// subscriber, the History kept under HistorySize, a Tier, or the EventSinks notified of
+// the alerts evaluated against them.
+func (c *Collector) consumed() bool {
+	return c.hasFieldsFunc || len(c.Sinks) > 0 || c.subs.len() > 0 || c.HistorySize > 0 ||
+		len(c.Tiers) > 0 || len(c.EventSinks) > 0
+}
+
+// replayBuffered emits the samples buffered under NoSinkBuffer to s.
+func (c *Collector) replayBuffered(s Sink) {
+	for _, f := range c.buffered {
+		if err := s.Emit(f); err != nil {
+			c.reportError(&SinkError{Sink: s, Err: err})
+		}
+	}
+	c.buffered = nil
+}
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// numaStats is the CPU affinity and NUMA placement of the process.
+type numaStats struct {
+	// AffinityCPUs is the number of CPUs the process may be scheduled on.
+	AffinityCPUs int64
+
+	// NodeMemory is the memory in bytes the process has resident on each NUMA node.
+	NodeMemory map[int]int64
+}
+
+// outputNUMAStats reports the CPU affinity of the process and its resident memory per
+// NUMA node, as proc.numa.node<N>.memory in Extra.
+func (c *Collector) outputNUMAStats(ff *fieldFilter) {
+	st, ok := readNUMAStats()
+	if !ok {
+		return
+	}
+	c.fields.ProcAffinityCPUs = st.AffinityCPUs
+	c.fields.ProcNUMANodes = int64(len(st.NodeMemory))
+	for node, bytes := range st.NodeMemory {
+		name := "proc.numa.node" + strconv.Itoa(node) + ".memory"
+		if c.keeps(ff, name) {
+			c.fields.setExtra(name, float64(bytes))
+		}
+	}
+}
+
+// cpuListLen returns the number of CPUs in a list such as "0-3,8,10-11", the format of
+// Cpus_allowed_list in /proc/<pid>/status.
+func cpuListLen(list string) int64 {
+	var n int64
+	for _, r := range strings.Split(strings.TrimSpace(list), ",") {
+		if r == "" {
+			continue
+		}
+		lo, hi := r, r
+		if i := strings.IndexByte(r, '-'); i >= 0 {
+			lo, hi = r[:i], r[i+1:]
+		}
+		a, err1 := strconv.Atoi(lo)
+		b, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || b < a {
+			continue
+		}
+		n += int64(b - a + 1)
+	}
+	return n
+}
+
+// parseNUMAMaps sums the pages of every mapping in /proc/<pid>/numa_maps by node, see
+// numa(7), and returns the bytes resident on each node.
+func parseNUMAMaps(r io.Reader) map[int]int64 {
+	nodes := make(map[int]int64)
+	pages := make(map[int]int64)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		pageSize := int64(4096)
+		for k := range pages {
+			delete(pages, k)
+		}
+		for _, tok := range strings.Fields(sc.Text()) {
+			switch {
+			case strings.HasPrefix(tok, "kernelpagesize_kB="):
+				if kb, err := strconv.ParseInt(tok[len("kernelpagesize_kB="):], 10, 64); err == nil {
+					pageSize = kb << 10
+				}
+			case len(tok) > 1 && tok[0] == 'N':
+				i := strings.IndexByte(tok, '=')
+				if i < 0 {
+					continue
+				}
+				node, err1 := strconv.Atoi(tok[1:i])
+				n, err2 := strconv.ParseInt(tok[i+1:], 10, 64)
+				if err1 == nil && err2 == nil {
+					pages[node] += n
+				}
+			}
+		}
+		for node, n := range pages {
+			nodes[node] += n * pageSize
+		}
+	}
+	return nodes
+}
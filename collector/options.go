@@ -0,0 +1,63 @@
+package collector
+
+import "time"
+
+// Option configures a Collector at construction, which guarantees its settings are in
+// place before Run starts. Run takes a snapshot of them and ignores later assignments to
+// the exported fields; see Run.
+type Option func(*Collector)
+
+// WithInterval sets the interval in-between each set of stats output.
+func WithInterval(d time.Duration) Option {
+	return func(c *Collector) { c.PauseDur = d }
+}
+
+// WithJitter randomizes every interval within ±fraction of its duration.
+func WithJitter(fraction float64) Option {
+	return func(c *Collector) { c.Jitter = fraction }
+}
+
+// WithCPU enables or disables CPU statistics.
+func WithCPU(enabled bool) Option {
+	return func(c *Collector) { c.EnableCPU = enabled }
+}
+
+// WithMem enables or disables memory statistics.
+func WithMem(enabled bool) Option {
+	return func(c *Collector) { c.EnableMem = enabled }
+}
+
+// WithGC enables or disables garbage collection statistics.
+func WithGC(enabled bool) Option {
+	return func(c *Collector) { c.EnableGC = enabled }
+}
+
+// WithDeltas enables or disables deltas and rates of cumulative counters.
+func WithDeltas(enabled bool) Option {
+	return func(c *Collector) { c.EnableDeltas = enabled }
+}
+
+// WithPauses enables or disables the summary and histogram of GC pauses.
+func WithPauses(enabled bool) Option {
+	return func(c *Collector) { c.EnablePauses = enabled }
+}
+
+// WithHeapGrowth enables or disables heap growth statistics.
+func WithHeapGrowth(enabled bool) Option {
+	return func(c *Collector) { c.EnableHeapGrowth = enabled }
+}
+
+// WithRuntimeMetrics selects the runtime/metrics collection backend.
+func WithRuntimeMetrics(enabled bool) Option {
+	return func(c *Collector) { c.UseRuntimeMetrics = enabled }
+}
+
+// WithDone sets the channel that stops Run when closed.
+func WithDone(done <-chan struct{}) Option {
+	return func(c *Collector) { c.Done = done }
+}
+
+// WithOnTick sets the hook called with every sample before it is emitted.
+func WithOnTick(fn func(f *Fields) (skip bool)) Option {
+	return func(c *Collector) { c.OnTick = fn }
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"runtime"
+	"time"
+)
+
+// pauseBuckets are the upper bounds of the GC pause histogram and the suffixes of the
+// mem.gc.pause.le_* fields. Like Prometheus histogram buckets they are cumulative: each
+// holds the number of pauses no longer than its bound, so le_inf counts every pause.
+var pauseBuckets = []struct {
+	le   time.Duration
+	name string
+}{
+	{100 * time.Microsecond, "100us"},
+	{500 * time.Microsecond, "500us"},
+	{time.Millisecond, "1ms"},
+	{5 * time.Millisecond, "5ms"},
+	{10 * time.Millisecond, "10ms"},
+	{50 * time.Millisecond, "50ms"},
+	{100 * time.Millisecond, "100ms"},
+	{1<<63 - 1, "inf"},
+}
+
+// outputPauses summarizes every GC pause since the previous collection by walking the
+// pause ring buffer from the last observed cycle. The ring holds the latest 256 pauses, so
+// with more cycles than that per interval the oldest ones are not accounted for.
+func (c *Collector) outputPauses(m, prev *runtime.MemStats) {
+	var from uint32
+	if prev != nil {
+		from = prev.NumGC
+	}
+	if m.NumGC-from > uint32(len(m.PauseNs)) {
+		from = m.NumGC - uint32(len(m.PauseNs))
+	}
+
+	c.fields.NumPauses, c.fields.PauseMinNs, c.fields.PauseMaxNs, c.fields.PauseMeanNs = 0, 0, 0, 0
+	counts := make([]int64, len(pauseBuckets))
+	var sum int64
+	for n := from; n < m.NumGC; n++ {
+		p := int64(m.PauseNs[n%uint32(len(m.PauseNs))])
+		if c.fields.NumPauses == 0 || p < c.fields.PauseMinNs {
+			c.fields.PauseMinNs = p
+		}
+		if p > c.fields.PauseMaxNs {
+			c.fields.PauseMaxNs = p
+		}
+		sum += p
+		c.fields.NumPauses++
+		for i, b := range pauseBuckets {
+			if time.Duration(p) <= b.le {
+				counts[i]++
+			}
+		}
+	}
+	if c.fields.NumPauses > 0 {
+		c.fields.PauseMeanNs = sum / c.fields.NumPauses
+	}
+	for i, b := range pauseBuckets {
+		c.fields.setExtra("mem.gc.pause.le_"+b.name, float64(counts[i]))
+	}
+}
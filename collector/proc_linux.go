@@ -0,0 +1,306 @@
+//go:build linux && !runtimemetrics_minimal
+
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readRSS returns the resident set size of the current process in bytes.
+func readRSS() (int64, bool) {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, false
+	}
+	parts := strings.Fields(string(b))
+	if len(parts) < 2 {
+		return 0, false
+	}
+	pages, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return pages * int64(os.Getpagesize()), true
+}
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc. It is 100 on every
+// architecture Go supports.
+const clockTicks = 100
+
+// readProcStats returns the status of every process visible in /proc.
+func readProcStats() ([]procStat, bool) {
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, false
+	}
+	stats := make([]procStat, 0, len(dirs))
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+		if st, ok := readProcStat(pid); ok {
+			stats = append(stats, st)
+		}
+	}
+	return stats, true
+}
+
+// readProcStat parses /proc/<pid>/stat, see proc(5).
+func readProcStat(pid int) (procStat, bool) {
+	b, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return procStat{}, false
+	}
+	// The command name may contain spaces and parentheses, so the remaining fields are
+	// located from the last closing parenthesis.
+	i := strings.LastIndexByte(string(b), ')')
+	if i < 0 {
+		return procStat{}, false
+	}
+	f := strings.Fields(string(b[i+1:]))
+	if len(f) < 22 {
+		return procStat{}, false
+	}
+	atoi := func(s string) int64 {
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	tick := int64(time.Second / clockTicks)
+	return procStat{
+		PID:      pid,
+		PPID:     int(atoi(f[1])),
+		UserNs:   atoi(f[11]) * tick,
+		SystemNs: atoi(f[12]) * tick,
+		RSS:      atoi(f[21]) * int64(os.Getpagesize()),
+		VSZ:      atoi(f[20]),
+		Threads:  atoi(f[17]),
+	}, true
+}
+
+// readFDCount returns the number of open file descriptors of the current process.
+func readFDCount() (int64, bool) {
+	f, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, false
+	}
+	// The directory itself is open while it is being read.
+	return int64(len(names)) - 1, true
+}
+
+// cgroupMemoryLimit returns the memory limit in bytes of the cgroup the process belongs
+// to. It reports false when no limit is set or cgroupfs is not available.
+func cgroupMemoryLimit() (int64, bool) {
+	s, ok := readCgroup("memory", "memory.max", "memory.limit_in_bytes")
+	if !ok || s == "max" {
+		return 0, false
+	}
+	limit, err := strconv.ParseInt(s, 10, 64)
+	// cgroup v1 reports an unlimited group as a huge page-aligned number.
+	if err != nil || limit <= 0 || limit >= 1<<62 {
+		return 0, false
+	}
+	return limit, true
+}
+
+// cgroupMemoryUsage returns the memory in bytes the cgroup the process belongs to is
+// charged for, which is what its limit applies to.
+func cgroupMemoryUsage() (int64, bool) {
+	s, ok := readCgroup("memory", "memory.current", "memory.usage_in_bytes")
+	if !ok {
+		return 0, false
+	}
+	usage, err := strconv.ParseInt(s, 10, 64)
+	return usage, err == nil
+}
+
+// readCgroupStats reads the memory and CPU accounting of the cgroup the process belongs to.
+func readCgroupStats() (cgroupStats, bool) {
+	var st cgroupStats
+	found := false
+	if limit, ok := cgroupMemoryLimit(); ok {
+		st.MemoryLimit, found = limit, true
+	}
+	if usage, ok := cgroupMemoryUsage(); ok {
+		st.MemoryUsage, found = usage, true
+	}
+
+	if s, ok := readCgroup("cpu", "cpu.max", ""); ok {
+		// cpu.max holds the quota and the period, the quota being "max" when unlimited.
+		if f := strings.Fields(s); len(f) == 2 && f[0] != "max" {
+			quota, _ := strconv.ParseFloat(f[0], 64)
+			period, _ := strconv.ParseFloat(f[1], 64)
+			if period > 0 {
+				st.CPUQuota = quota / period
+			}
+		}
+		found = true
+	} else if s, ok := readCgroup("cpu", "", "cpu.cfs_quota_us"); ok {
+		// cgroup v1 reports an unlimited quota as -1.
+		quota, _ := strconv.ParseFloat(s, 64)
+		if p, ok := readCgroup("cpu", "", "cpu.cfs_period_us"); ok && quota > 0 {
+			if period, _ := strconv.ParseFloat(p, 64); period > 0 {
+				st.CPUQuota = quota / period
+			}
+		}
+		found = true
+	}
+
+	if s, ok := readCgroup("cpu", "cpu.stat", "cpu.stat"); ok {
+		for _, line := range strings.Split(s, "\n") {
+			f := strings.Fields(line)
+			if len(f) != 2 {
+				continue
+			}
+			n, _ := strconv.ParseInt(f[1], 10, 64)
+			switch f[0] {
+			case "nr_periods":
+				st.Periods = n
+			case "nr_throttled":
+				st.Throttled = n
+			case "throttled_usec": // v2
+				st.ThrottledNs = n * int64(time.Microsecond)
+			case "throttled_time": // v1
+				st.ThrottledNs = n
+			}
+		}
+		found = true
+	}
+	return st, found
+}
+
+// readCgroup returns the trimmed contents of a cgroup control file, named v2 in the
+// unified hierarchy and v1 in the hierarchy of the given controller.
+func readCgroup(controller, v2, v1 string) (string, bool) {
+	for _, p := range cgroupPaths(controller, v2, v1) {
+		if b, err := os.ReadFile(p); err == nil {
+			return strings.TrimSpace(string(b)), true
+		}
+	}
+	return "", false
+}
+
+// cgroupPaths returns the candidate locations of a cgroup control file, most specific
+// first. The mount root is included as a fallback for containers that run in their own
+// cgroup namespace.
+func cgroupPaths(controller, v2, v1 string) []string {
+	var paths []string
+	if f, err := os.Open("/proc/self/cgroup"); err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			// Each line has the form hierarchy-ID:controller-list:cgroup-path.
+			parts := strings.SplitN(sc.Text(), ":", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			switch {
+			case parts[0] == "0" && parts[1] == "" && v2 != "":
+				paths = append(paths, filepath.Join("/sys/fs/cgroup", parts[2], v2))
+			case v1 != "" && hasController(parts[1], controller):
+				paths = append(paths, filepath.Join("/sys/fs/cgroup", controller, parts[2], v1))
+			}
+		}
+	}
+	if v2 != "" {
+		paths = append(paths, filepath.Join("/sys/fs/cgroup", v2))
+	}
+	if v1 != "" {
+		paths = append(paths, filepath.Join("/sys/fs/cgroup", controller, v1))
+	}
+	return paths
+}
+
+func hasController(list, controller string) bool {
+	for _, c := range strings.Split(list, ",") {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// readNUMAStats reads the CPU affinity of the current process from /proc/self/status and
+// its NUMA placement from /proc/self/numa_maps, which is absent on kernels without NUMA
+// support.
+func readNUMAStats() (numaStats, bool) {
+	b, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return numaStats{}, false
+	}
+	var st numaStats
+	for _, line := range strings.Split(string(b), "\n") {
+		if v := strings.TrimPrefix(line, "Cpus_allowed_list:"); v != line {
+			st.AffinityCPUs = cpuListLen(v)
+			break
+		}
+	}
+	if f, err := os.Open("/proc/self/numa_maps"); err == nil {
+		st.NodeMemory = parseNUMAMaps(f)
+		f.Close()
+	}
+	return st, true
+}
+
+// readAnonHugePages returns the anonymous memory in bytes of the current process backed
+// by transparent huge pages, from /proc/self/smaps_rollup.
+func readAnonHugePages() (int64, bool) {
+	f, err := os.Open("/proc/self/smaps_rollup")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if v := strings.TrimPrefix(sc.Text(), "AnonHugePages:"); v != sc.Text() {
+			kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(v), " kB"), 10, 64)
+			return kb << 10, err == nil
+		}
+	}
+	return 0, false
+}
+
+// readTHPSettings returns the system's transparent huge page settings from sysfs.
+func readTHPSettings() (enabled, defrag string, ok bool) {
+	const dir = "/sys/kernel/mm/transparent_hugepage/"
+	e, err := os.ReadFile(dir + "enabled")
+	if err != nil {
+		return "", "", false
+	}
+	d, _ := os.ReadFile(dir + "defrag")
+	return strings.TrimSpace(string(e)), strings.TrimSpace(string(d)), true
+}
+
+// readFDTypes classifies the open file descriptors of the current process by the targets
+// of their links in /proc/self/fd.
+func readFDTypes() (fdTypes, bool) {
+	const dir = "/proc/self/fd/"
+	f, err := os.Open(dir)
+	if err != nil {
+		return fdTypes{}, false
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return fdTypes{}, false
+	}
+	var t fdTypes
+	for _, name := range names {
+		// The descriptor of the directory itself is closed by now, along with any other
+		// closed since it was read.
+		if target, err := os.Readlink(dir + name); err == nil {
+			t.add(target)
+		}
+	}
+	return t, true
+}
@@ -0,0 +1,79 @@
+//go:build linux && !runtimemetrics_minimal
+
+package collector
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestProcessStats(t *testing.T) {
+	c := New(nil)
+	c.EnableProcess = true
+	f := c.OneOff()
+
+	if f.ProcRSS <= 0 || f.ProcVSZ < f.ProcRSS {
+		t.Errorf("unexpected memory: rss %d, vsz %d", f.ProcRSS, f.ProcVSZ)
+	}
+	if f.ProcThreads < 1 || f.ProcFDs < 3 {
+		t.Errorf("unexpected threads %d or fds %d", f.ProcThreads, f.ProcFDs)
+	}
+}
+
+func TestNUMAStats(t *testing.T) {
+	c := New(nil)
+	c.EnableNUMA = true
+	f := c.OneOff()
+
+	if f.ProcAffinityCPUs < 1 {
+		t.Errorf("expected at least one CPU in the affinity mask, got %d", f.ProcAffinityCPUs)
+	}
+	var total float64
+	for node := 0; node < int(f.ProcNUMANodes); node++ {
+		total += f.Extra["proc.numa.node"+strconv.Itoa(node)+".memory"]
+	}
+	if f.ProcNUMANodes > 0 && total <= 0 {
+		t.Errorf("expected resident memory on %d nodes, got %v", f.ProcNUMANodes, f.Extra)
+	}
+}
+
+func TestTHPStats(t *testing.T) {
+	c := New(nil)
+	c.EnableTHP = true
+	f := c.OneOff()
+
+	if f.ProcAnonHugePages < 0 {
+		t.Errorf("unexpected huge page usage %d", f.ProcAnonHugePages)
+	}
+	if _, _, ok := readTHPSettings(); ok && f.Tags["thp_enabled"] == "" {
+		t.Errorf("expected the thp_enabled tag, got %v", f.Tags)
+	}
+}
+
+func TestFDTypeStats(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	c := New(nil)
+	c.EnableFDTypes = true
+	f := c.OneOff()
+	if f.FDPipes < 2 {
+		t.Errorf("expected at least 2 pipes, got %d", f.FDPipes)
+	}
+
+	// The breakdown is not read again within FDTypesInterval.
+	r2, w2, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	defer w2.Close()
+	if again := c.OneOff(); again.FDPipes != f.FDPipes {
+		t.Errorf("expected the cached breakdown, got %d pipes instead of %d", again.FDPipes, f.FDPipes)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux || runtimemetrics_minimal
+
+package collector
+
+func readRSS() (int64, bool) { return 0, false }
+
+func cgroupMemoryLimit() (int64, bool) { return 0, false }
+
+func cgroupMemoryUsage() (int64, bool) { return 0, false }
+
+func readCgroupStats() (cgroupStats, bool) { return cgroupStats{}, false }
+
+func readCgroup(controller, v2, v1 string) (string, bool) { return "", false }
+
+func readProcStats() ([]procStat, bool) { return nil, false }
+
+func readProcStat(pid int) (procStat, bool) { return procStat{}, false }
+
+func readFDCount() (int64, bool) { return 0, false }
+
+func readNUMAStats() (numaStats, bool) { return numaStats{}, false }
+
+func readAnonHugePages() (int64, bool) { return 0, false }
+
+func readTHPSettings() (enabled, defrag string, ok bool) { return "", "", false }
+
+func readFDTypes() (fdTypes, bool) { return fdTypes{}, false }
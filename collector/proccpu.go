@@ -0,0 +1,34 @@
+package collector
+
+import "time"
+
+// procCPUTimes is the CPU time consumed by the process.
+type procCPUTimes struct {
+	UserNs   int64
+	SystemNs int64
+}
+
+// outputProcCPU reports the CPU time consumed by the process and its utilization since the
+// previous collection. The first collection reports no utilization.
+func (c *Collector) outputProcCPU(now time.Time) {
+	times, ok := readProcCPU()
+	if !ok {
+		return
+	}
+	c.fields.ProcCPUUserNs = times.UserNs
+	c.fields.ProcCPUSystemNs = times.SystemNs
+	if !c.procCPUAt.IsZero() {
+		c.fields.ProcCPUUtilization = cpuUtilization(c.procCPU, times, now.Sub(c.procCPUAt))
+	}
+	c.procCPU, c.procCPUAt = times, now
+}
+
+// cpuUtilization returns the CPU time consumed between prev and cur as a percentage of
+// one CPU over elapsed.
+func cpuUtilization(prev, cur procCPUTimes, elapsed time.Duration) float64 {
+	used := cur.UserNs + cur.SystemNs - prev.UserNs - prev.SystemNs
+	if elapsed <= 0 || used < 0 {
+		return 0
+	}
+	return float64(used) / float64(elapsed) * 100
+}
@@ -0,0 +1,5 @@
+//go:build !(unix || windows) || runtimemetrics_minimal
+
+package collector
+
+func readProcCPU() (procCPUTimes, bool) { return procCPUTimes{}, false }
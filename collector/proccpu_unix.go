@@ -0,0 +1,14 @@
+//go:build unix && !runtimemetrics_minimal
+
+package collector
+
+import "syscall"
+
+// readProcCPU returns the CPU time consumed by the process, see getrusage(2).
+func readProcCPU() (procCPUTimes, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return procCPUTimes{}, false
+	}
+	return procCPUTimes{UserNs: ru.Utime.Nano(), SystemNs: ru.Stime.Nano()}, true
+}
@@ -0,0 +1,20 @@
+//go:build windows && !runtimemetrics_minimal
+
+package collector
+
+import "syscall"
+
+// readProcCPU returns the CPU time consumed by the process, see GetProcessTimes.
+func readProcCPU() (procCPUTimes, bool) {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return procCPUTimes{}, false
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return procCPUTimes{}, false
+	}
+	// The times are durations, not dates, in 100-nanosecond intervals.
+	ticks := func(ft syscall.Filetime) int64 { return (int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime)) * 100 }
+	return procCPUTimes{UserNs: ticks(user), SystemNs: ticks(kernel)}, true
+}
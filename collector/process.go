@@ -0,0 +1,16 @@
+package collector
+
+import "os"
+
+// outputProcessStats reports the OS-level resources held by the process, which account
+// for memory the Go heap statistics do not, such as cgo allocations and mapped files.
+func (c *Collector) outputProcessStats() {
+	if st, ok := readProcStat(os.Getpid()); ok {
+		c.fields.ProcRSS = st.RSS
+		c.fields.ProcVSZ = st.VSZ
+		c.fields.ProcThreads = st.Threads
+	}
+	if n, ok := readFDCount(); ok {
+		c.fields.ProcFDs = n
+	}
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// ProfileCapture writes profiles to Dir when the Alert it is attached to fires, to catch
+// the state of the process at the moment a threshold is crossed, such as the goroutines of
+// a leak. Profiles are written in the background and named
+// <alert>-<time>.<profile>.pb.gz. Failures are reported to ErrorFunc.
+type ProfileCapture struct {
+	// Dir is the directory profiles are written to. It must exist.
+	Dir string
+
+	// Profiles names the profiles captured: "cpu" or any profile known to runtime/pprof,
+	// such as "heap", "goroutine", "allocs", "block" or "mutex". Defaults to heap and
+	// goroutine.
+	Profiles []string
+
+	// CPUDuration is how long the CPU profile is recorded for. Defaults to 10 seconds.
+	CPUDuration time.Duration
+
+	// MinInterval is the minimum time between two captures, so that a flapping alert does
+	// not fill Dir. Defaults to 5 minutes.
+	MinInterval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+
+	// wg tracks the captures in progress.
+	wg sync.WaitGroup
+}
+
+// allow reports whether a capture may start at t, and records it if so.
+func (p *ProfileCapture) allow(t time.Time) bool {
+	min := p.MinInterval
+	if min <= 0 {
+		min = 5 * time.Minute
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.last.IsZero() && t.Sub(p.last) < min {
+		return false
+	}
+	p.last = t
+	return true
+}
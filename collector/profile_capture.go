@@ -0,0 +1,79 @@
+//go:build !runtimemetrics_minimal
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"time"
+)
+
+// capture writes the profiles in the background unless a capture happened within
+// MinInterval, naming the files after name and t.
+func (p *ProfileCapture) capture(name string, t time.Time, report func(error)) {
+	if !p.allow(t) {
+		return
+	}
+	profiles := p.Profiles
+	if len(profiles) == 0 {
+		profiles = []string{"heap", "goroutine"}
+	}
+	prefix := filepath.Join(p.Dir, profileName(name)+"-"+t.UTC().Format("20060102T150405Z")+".")
+
+	p.wg.Add(1)
+	goSelf(func() {
+		defer p.wg.Done()
+		for _, kind := range profiles {
+			if err := p.write(prefix+kind+".pb.gz", kind); err != nil {
+				report(fmt.Errorf("collector: capturing %s profile: %w", kind, err))
+			}
+		}
+	})
+}
+
+func (p *ProfileCapture) write(path, kind string) (err error) {
+	var prof *pprof.Profile
+	if kind != "cpu" {
+		if prof = pprof.Lookup(kind); prof == nil {
+			return fmt.Errorf("unknown profile %q", kind)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if prof != nil {
+		return prof.WriteTo(f, 0)
+	}
+	d := p.CPUDuration
+	if d <= 0 {
+		d = 10 * time.Second
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	pprof.StopCPUProfile()
+	return nil
+}
+
+// profileName makes name, such as the description of a rule, safe to use in a file name.
+func profileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
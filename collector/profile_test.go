@@ -0,0 +1,50 @@
+//go:build !runtimemetrics_minimal
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestProfileCapture(t *testing.T) {
+	dir := t.TempDir()
+	capture := &ProfileCapture{Dir: dir, Profiles: []string{"goroutine", "heap", "cpu"}, CPUDuration: 10 * time.Millisecond}
+	goroutines := 10.0
+	c := New(nil, WithAlert(&Alert{
+		Name:    "goroutine leak",
+		Rule:    Rule{Field: "app.goroutines", Kind: Max, Limit: 100},
+		Capture: capture,
+	}))
+	c.RegisterGauge("app.goroutines", func() float64 { return goroutines })
+
+	c.OneOff()
+	goroutines = 1000
+	c.OneOff()
+	goroutines = 10
+	c.OneOff()
+	goroutines = 1000
+	c.OneOff() // Within MinInterval of the first capture.
+	capture.wg.Wait()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 profiles, got %v", files)
+	}
+	for i, kind := range []string{"cpu", "goroutine", "heap"} {
+		name := filepath.Base(files[i])
+		if ok, _ := filepath.Match("goroutine_leak-*."+kind+".pb.gz", name); !ok {
+			t.Errorf("unexpected profile name %s", name)
+		}
+		if st, err := os.Stat(files[i]); err != nil || st.Size() == 0 {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+}
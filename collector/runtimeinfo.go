@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// godebugPrefix is the prefix of the runtime/metrics counting the uses of non-default
+// GODEBUG behaviors.
+const godebugPrefix = "/godebug/non-default-behavior/"
+
+var (
+	buildInfoOnce  sync.Once
+	defaultGODEBUG string
+	goexperiment   string
+	modulePath     string
+	moduleVersion  string
+	vcsRevision    string
+	vcsModified    string
+)
+
+func readBuildSettings() {
+	buildInfoOnce.Do(func() {
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		modulePath, moduleVersion = bi.Main.Path, bi.Main.Version
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "DefaultGODEBUG":
+				defaultGODEBUG = s.Value
+			case "GOEXPERIMENT":
+				goexperiment = s.Value
+			case "vcs.revision":
+				vcsRevision = s.Value
+			case "vcs.modified":
+				vcsModified = s.Value
+			}
+		}
+	})
+}
+
+// godebugSettings returns the effective GODEBUG settings: the defaults the binary was
+// built with, overridden by the GODEBUG environment variable, sorted by name.
+func godebugSettings() string {
+	readBuildSettings()
+	settings := make(map[string]string)
+	for _, src := range []string{defaultGODEBUG, os.Getenv("GODEBUG")} {
+		for _, kv := range strings.Split(src, ",") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(kv), "="); ok && k != "" {
+				settings[k] = v
+			}
+		}
+	}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + settings[k]
+	}
+	return strings.Join(keys, ",")
+}
+
+// addRuntimeInfoTags adds the "godebug" and "goexperiment" tags to tags.
+func addRuntimeInfoTags(tags map[string]string) {
+	readBuildSettings()
+	if s := godebugSettings(); s != "" {
+		tags["godebug"] = s
+	}
+	if goexperiment != "" {
+		tags["goexperiment"] = goexperiment
+	}
+}
+
+// outputRuntimeInfo reports how often each non-default GODEBUG behavior was used, as the
+// godebug.<name>.non_default fields, and raises a "runtime_settings" event when GODEBUG
+// changed since the previous collection.
+func (c *Collector) outputRuntimeInfo() {
+	if c.godebugSamples == nil {
+		for _, d := range metrics.All() {
+			if strings.HasPrefix(d.Name, godebugPrefix) {
+				c.godebugSamples = append(c.godebugSamples, metrics.Sample{Name: d.Name})
+			}
+		}
+	}
+	metrics.Read(c.godebugSamples)
+	for _, s := range c.godebugSamples {
+		if s.Value.Kind() != metrics.KindUint64 || s.Value.Uint64() == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(s.Name, godebugPrefix), ":events")
+		c.fields.setExtra("godebug."+name+".non_default", float64(s.Value.Uint64()))
+	}
+
+	settings := godebugSettings()
+	if c.godebugSeen && settings != c.godebug {
+		c.emitEvent(Event{
+			Kind:    "runtime_settings",
+			Time:    time.Now(),
+			Message: "GODEBUG changed from \"" + c.godebug + "\" to \"" + settings + "\"",
+		})
+	}
+	c.godebug, c.godebugSeen = settings, true
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+// memStatsMetrics lists the runtime/metrics samples readMemStats is built from. The order
+// must match the indexes used in readMemStats.
+var memStatsMetrics = []string{
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/allocs:objects",
+	"/gc/heap/frees:objects",
+	"/gc/heap/tiny/allocs:objects",
+	"/gc/heap/objects:objects",
+	"/gc/heap/goal:bytes",
+	"/gc/cycles/total:gc-cycles",
+	"/memory/classes/total:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/unused:bytes",
+	"/memory/classes/heap/free:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/memory/classes/heap/stacks:bytes",
+	"/memory/classes/os-stacks:bytes",
+	"/memory/classes/metadata/mspan/inuse:bytes",
+	"/memory/classes/metadata/mspan/free:bytes",
+	"/memory/classes/metadata/mcache/inuse:bytes",
+	"/memory/classes/metadata/mcache/free:bytes",
+	"/memory/classes/metadata/other:bytes",
+	"/memory/classes/profiling/buckets:bytes",
+	"/memory/classes/other:bytes",
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+}
+
+// runtimeMetricsReader reads memory statistics from runtime/metrics, reusing its buffers
+// across reads.
+type runtimeMetricsReader struct {
+	samples []metrics.Sample
+	pauses  []time.Duration
+	bySize  []metrics.Sample
+}
+
+// read fills m from runtime/metrics and debug.ReadGCStats, neither of which stops the
+// world. Lookups has no equivalent and is left at zero.
+func (r *runtimeMetricsReader) read(m *runtime.MemStats) {
+	if r.samples == nil {
+		r.samples = make([]metrics.Sample, len(memStatsMetrics))
+		for i, name := range memStatsMetrics {
+			r.samples[i].Name = name
+		}
+	}
+	metrics.Read(r.samples)
+
+	u := func(i int) uint64 {
+		if v := r.samples[i].Value; v.Kind() == metrics.KindUint64 {
+			return v.Uint64()
+		}
+		return 0
+	}
+	f := func(i int) float64 {
+		if v := r.samples[i].Value; v.Kind() == metrics.KindFloat64 {
+			return v.Float64()
+		}
+		return 0
+	}
+
+	m.TotalAlloc = u(0)
+	m.Mallocs = u(1) + u(3)
+	m.Frees = u(2) + u(3)
+	m.HeapObjects = u(4)
+	m.NextGC = u(5)
+	m.NumGC = uint32(u(6))
+	m.Sys = u(7)
+
+	m.HeapAlloc = u(8)
+	m.Alloc = m.HeapAlloc
+	m.HeapInuse = u(8) + u(9)
+	m.HeapIdle = u(10) + u(11)
+	m.HeapSys = m.HeapInuse + m.HeapIdle
+	m.HeapReleased = u(11)
+
+	m.StackInuse = u(12)
+	m.StackSys = u(12) + u(13)
+	m.MSpanInuse = u(14)
+	m.MSpanSys = u(14) + u(15)
+	m.MCacheInuse = u(16)
+	m.MCacheSys = u(16) + u(17)
+	m.GCSys = u(18)
+	m.BuckHashSys = u(19)
+	m.OtherSys = u(20)
+
+	if total := f(22); total > 0 {
+		m.GCCPUFraction = f(21) / total
+	}
+
+	gc := debug.GCStats{Pause: r.pauses[:0]}
+	debug.ReadGCStats(&gc)
+	r.pauses = gc.Pause
+	if !gc.LastGC.IsZero() {
+		m.LastGC = uint64(gc.LastGC.UnixNano())
+	}
+	m.PauseTotalNs = uint64(gc.PauseTotal)
+	// gc.Pause holds the most recent pauses first, PauseNs is a circular buffer indexed by
+	// cycle number.
+	for i, p := range gc.Pause {
+		if i >= len(m.PauseNs) || int64(m.NumGC)-1-int64(i) < 0 {
+			break
+		}
+		m.PauseNs[(m.NumGC-1-uint32(i))%uint32(len(m.PauseNs))] = uint64(p)
+	}
+}
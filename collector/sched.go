@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"math"
+	"runtime/metrics"
+)
+
+// outputSchedStats reports the scheduling latency percentiles of the goroutines that
+// became runnable since the previous collection, or since the process started on the
+// first collection.
+func (c *Collector) outputSchedStats() {
+	sample := []metrics.Sample{{Name: "/sched/latencies:seconds"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return
+	}
+	h := sample[0].Value.Float64Histogram()
+
+	counts := make([]uint64, len(h.Counts))
+	copy(counts, h.Counts)
+	if len(c.prevSched) == len(counts) {
+		for i, prev := range c.prevSched {
+			counts[i] -= prev
+		}
+	}
+	c.prevSched = append(c.prevSched[:0], h.Counts...)
+
+	c.fields.SchedLatencyP50 = histogramQuantile(h.Buckets, counts, 0.5)
+	c.fields.SchedLatencyP95 = histogramQuantile(h.Buckets, counts, 0.95)
+	c.fields.SchedLatencyP99 = histogramQuantile(h.Buckets, counts, 0.99)
+}
+
+// histogramQuantile estimates the q-quantile of a runtime/metrics histogram, where
+// counts[i] is the number of observations within [buckets[i], buckets[i+1]), by linear
+// interpolation within the bucket holding it. Infinite bucket bounds are replaced by the
+// finite one. It returns zero for an empty histogram.
+func histogramQuantile(buckets []float64, counts []uint64, q float64) float64 {
+	var total uint64
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+	var seen float64
+	for i, n := range counts {
+		if n == 0 || seen+float64(n) < rank {
+			seen += float64(n)
+			continue
+		}
+		lo, hi := buckets[i], buckets[i+1]
+		switch {
+		case math.IsInf(lo, -1):
+			return hi
+		case math.IsInf(hi, 1):
+			return lo
+		}
+		return lo + (hi-lo)*(rank-seen)/float64(n)
+	}
+	return buckets[len(buckets)-1]
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSelfLeak is reported by a SelfCheck when the footprint of the collector itself keeps
+// growing.
+var ErrSelfLeak = errors.New("collector: telemetry pipeline is leaking")
+
+// selfGoroutines counts the goroutines started by this package that are still running.
+var selfGoroutines int64
+
+// goSelf runs fn on a goroutine counted in selfGoroutines.
+func goSelf(fn func()) {
+	atomic.AddInt64(&selfGoroutines, 1)
+	go func() {
+		defer atomic.AddInt64(&selfGoroutines, -1)
+		fn()
+	}()
+}
+
+// SelfCheck monitors the footprint of the Collector itself over long runs, so that a leak
+// in the telemetry pipeline is caught rather than blamed on the application. It reports
+// meta.self.goroutines, the goroutines started by this package; meta.self.retained, the
+// samples, history entries, subscribers, silences and gauges the Collector holds; and
+// meta.self.alloc, the bytes allocated by the process while the sample was collected,
+// an upper bound of what collecting costs. Once Window has passed, ErrSelfLeak is
+// reported whenever the goroutines or retained entries grew by more than one per Window,
+// or the mean allocation per collection over Window exceeded MaxAlloc. A "self_leak" event
+// is raised along with it, and a resolved one once growth stops.
+type SelfCheck struct {
+	// Window is the period growth is measured over, and the warm-up period before the
+	// first check. Defaults to 1 hour.
+	Window time.Duration
+
+	// MaxAlloc is the mean number of bytes a collection may allocate. Defaults to 1 MiB.
+	MaxAlloc float64
+
+	// Func receives ErrSelfLeak, wrapped with the violations, when the check fails.
+	// Defaults to the Collector's ErrorFunc.
+	Func func(error)
+
+	started  time.Time
+	asserter Asserter
+	failing  bool
+	samples  []metrics.Sample
+}
+
+// WithSelfCheck enables monitoring of the Collector's own footprint.
+func WithSelfCheck(s *SelfCheck) Option {
+	return func(c *Collector) { c.SelfCheck = s }
+}
+
+func (s *SelfCheck) window() time.Duration {
+	if s.Window <= 0 {
+		return time.Hour
+	}
+	return s.Window
+}
+
+func (s *SelfCheck) rules() []Rule {
+	w := s.window()
+	growth := 1 / w.Seconds()
+	maxAlloc := s.MaxAlloc
+	if maxAlloc <= 0 {
+		maxAlloc = 1 << 20
+	}
+	return []Rule{
+		{Field: "meta.self.goroutines", Kind: Trend, Limit: growth, Window: w},
+		{Field: "meta.self.retained", Kind: Trend, Limit: growth, Window: w},
+		{Field: "meta.self.alloc", Kind: Max, Over: Mean, Limit: maxAlloc, Window: w},
+	}
+}
+
+// heapAllocs returns the bytes allocated by the process so far.
+func (s *SelfCheck) heapAllocs() uint64 {
+	if s.samples == nil {
+		s.samples = []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	}
+	metrics.Read(s.samples)
+	if s.samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return s.samples[0].Value.Uint64()
+}
+
+// retained returns the number of entries the Collector holds on to.
+func (c *Collector) retained() int64 {
+	n := len(c.history.samples) + len(c.buffered) + len(c.gauges) + c.subs.len()
+	c.silences.mu.Lock()
+	n += len(c.silences.all)
+	c.silences.mu.Unlock()
+	for _, a := range c.Alerts {
+		n += len(a.asserter.history)
+	}
+	return int64(n)
+}
+
+// checkSelf reports the footprint of the Collector and evaluates it, given the bytes
+// allocated by the process when the collection started.
+func (c *Collector) checkSelf(startAllocs uint64, at time.Time) {
+	s := c.SelfCheck
+	c.fields.SelfGoroutines = atomic.LoadInt64(&selfGoroutines)
+	c.fields.SelfRetained = c.retained()
+	if allocs := s.heapAllocs(); allocs >= startAllocs {
+		c.fields.SelfAlloc = int64(allocs - startAllocs)
+	}
+
+	if s.started.IsZero() {
+		s.started = at
+	}
+	s.asserter.Rules = s.rules()
+	violations, err := s.asserter.Assert(c.fields, at)
+	if err != nil || at.Sub(s.started) < s.window() {
+		return
+	}
+	failing := len(violations) > 0
+	if failing == s.failing {
+		return
+	}
+	s.failing = failing
+
+	e := Event{Kind: "self_leak", Time: at, Resolved: !failing, Message: "collector footprint stopped growing"}
+	if failing {
+		err := fmt.Errorf("%w: %v", ErrSelfLeak, violations)
+		if s.Func != nil {
+			s.Func(err)
+		} else {
+			c.reportError(err)
+		}
+		v := violations[0]
+		e.Message, e.Field, e.Value, e.Threshold = err.Error(), v.Field, v.Value, v.Rule.Limit
+	}
+	c.emitEvent(e)
+}
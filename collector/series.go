@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"time"
+)
+
+// DefaultCriticalFields are the fields emitted first when a Collector does not configure
+// CriticalFields.
+var DefaultCriticalFields = []string{
+	"mem.heap.inuse",
+	"mem.heap.alloc",
+	"cpu.goroutines",
+	"mem.sys",
+	"mem.gc.pause",
+}
+
+// ErrDeadline is returned by SendSeries when the deadline passed before all series were
+// sent.
+var ErrDeadline = errors.New("collector: emission deadline exceeded")
+
+// Series is a single named value of a sample. Value is either an int64 or a float64.
+type Series struct {
+	Name  string
+	Value interface{}
+}
+
+// Series returns the fields in emission order: the critical fields first, in the order
+// they are configured, followed by all other fields sorted by name. Sinks that write one
+// series at a time use this order so that running out of time drops filler series rather
+// than the ones that matter.
+func (f *Fields) Series() []Series {
+	critical := f.critical
+	if critical == nil {
+		critical = DefaultCriticalFields
+	}
+
+	m := f.ToMap()
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	series := make([]Series, 0, len(m))
+	for _, pattern := range critical {
+		for _, name := range names {
+			if v, ok := m[name]; ok && matchField(pattern, name) {
+				series = append(series, Series{Name: name, Value: v})
+				delete(m, name)
+			}
+		}
+	}
+	for _, name := range names {
+		if v, ok := m[name]; ok {
+			series = append(series, Series{Name: name, Value: v})
+		}
+	}
+	return series
+}
+
+// SendSeries passes series to send in order until all are sent, send fails or the
+// deadline passes. It returns the number of series sent. A zero deadline never expires.
+func SendSeries(series []Series, deadline time.Time, send func(Series) error) (int, error) {
+	for i, s := range series {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return i, ErrDeadline
+		}
+		if err := send(s); err != nil {
+			return i, err
+		}
+	}
+	return len(series), nil
+}
+
+// matchField reports whether name matches pattern, which is either a field name or a
+// path.Match pattern such as "mem.heap.*".
+func matchField(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// ErrSettingChanged is reported to ErrorFunc when an exported field of a running Collector
+// was assigned. Run works with the settings it started with and reverts the assignment.
+var ErrSettingChanged = errors.New("collector: setting changed while Run is running, reverted")
+
+// addedFields are the exported fields of Collector that AddSink, AddAlert, AddTier and
+// AddEventSink keep growing while Run is running, and which are not part of its settings.
+var addedFields = map[string]bool{"Sinks": true, "Alerts": true, "Tiers": true, "EventSinks": true}
+
+// snapshotSettings copies the exported fields of c for restoreSettings. It is called with
+// c.mu held when Run starts.
+func (c *Collector) snapshotSettings() {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	c.settings = make([]reflect.Value, t.NumField())
+	for i := range c.settings {
+		if f := t.Field(i); f.PkgPath == "" && !addedFields[f.Name] {
+			c.settings[i] = reflect.New(f.Type).Elem()
+			c.settings[i].Set(v.Field(i))
+		}
+	}
+}
+
+// restoreSettings reverts the exported fields of c assigned since snapshotSettings,
+// reporting ErrSettingChanged for each. Nothing is written while the settings are
+// unchanged. It is called with c.mu held.
+func (c *Collector) restoreSettings() {
+	if c.settings == nil {
+		return
+	}
+	v := reflect.ValueOf(c).Elem()
+	for i, want := range c.settings {
+		if !want.IsValid() || sameValue(v.Field(i), want) {
+			continue
+		}
+		v.Field(i).Set(want)
+		c.reportError(fmt.Errorf("%w: %s", ErrSettingChanged, v.Type().Field(i).Name))
+	}
+}
+
+// sameValue reports whether a and b, of the same type, hold the same value. Functions,
+// maps, pointers, channels and slices are compared by identity rather than contents.
+func sameValue(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(a.Float()) == math.Float64bits(b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Slice:
+		return a.Pointer() == b.Pointer() && a.Len() == b.Len()
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return a.Elem().Type() == b.Elem().Type() && sameValue(a.Elem(), b.Elem())
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if !sameValue(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !sameValue(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Pointer() == b.Pointer()
+	}
+}
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// Silence suppresses the events matching it while it is active, so planned deploys and
+// batch windows do not notify anyone. Resolved events are never suppressed. Events are
+// matched by Kind, Instance and Tags, each either a value or a path.Match pattern; empty
+// matches everything.
+type Silence struct {
+	Kind     string `json:"kind,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Tags must all be present on the event with matching values.
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Start and End bound the window the silence is active in. A zero Start is active
+	// immediately and a zero End never expires.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Repeat, when positive, makes the window recur every Repeat after Start, such as
+	// every 24 hours for a nightly batch window. Both Start and End must be set.
+	Repeat time.Duration `json:"repeat_ns,omitempty"`
+
+	// Comment describes why the silence exists.
+	Comment string `json:"comment,omitempty"`
+}
+
+// SilenceFor returns a Silence active for d from now.
+func SilenceFor(d time.Duration, comment string) Silence {
+	now := time.Now()
+	return Silence{Start: now, End: now.Add(d), Comment: comment}
+}
+
+// Active reports whether the window of s includes t.
+func (s Silence) Active(t time.Time) bool {
+	if !s.Start.IsZero() && t.Before(s.Start) {
+		return false
+	}
+	if s.End.IsZero() {
+		return true
+	}
+	if s.Repeat > 0 && s.End.After(s.Start) {
+		elapsed := t.Sub(s.Start) % s.Repeat
+		return elapsed < s.End.Sub(s.Start)
+	}
+	return t.Before(s.End)
+}
+
+// expired reports whether s will never be active again after t.
+func (s Silence) expired(t time.Time) bool {
+	return !s.End.IsZero() && s.Repeat <= 0 && !t.Before(s.End)
+}
+
+func (s Silence) matches(e Event) bool {
+	if s.Kind != "" && !matchField(s.Kind, e.Kind) {
+		return false
+	}
+	if s.Instance != "" && !matchField(s.Instance, e.Instance) {
+		return false
+	}
+	for k, pattern := range s.Tags {
+		if v, ok := e.Tags[k]; !ok || !matchField(pattern, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// silences holds the silences added with Silence. It has its own lock so silences may be
+// managed while a collection is in progress.
+type silences struct {
+	mu   sync.Mutex
+	next int
+	all  map[int]Silence
+}
+
+// Silence adds s and returns a function that removes it. Expired silences are removed
+// automatically.
+func (c *Collector) Silence(s Silence) (remove func()) {
+	c.silences.mu.Lock()
+	defer c.silences.mu.Unlock()
+	if c.silences.all == nil {
+		c.silences.all = make(map[int]Silence)
+	}
+	id := c.silences.next
+	c.silences.next++
+	c.silences.all[id] = s
+	return func() {
+		c.silences.mu.Lock()
+		defer c.silences.mu.Unlock()
+		delete(c.silences.all, id)
+	}
+}
+
+// ActiveSilences returns the silences active now, ordered by when they were added.
+func (c *Collector) ActiveSilences() []Silence {
+	return c.silences.active(time.Now())
+}
+
+func (ss *silences) active(now time.Time) []Silence {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	var active []Silence
+	for id := 0; id < ss.next; id++ {
+		s, ok := ss.all[id]
+		switch {
+		case !ok:
+		case s.expired(now):
+			delete(ss.all, id)
+		case s.Active(now):
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// silenced reports whether e is suppressed by an active silence. Resolved events are never
+// suppressed, so that alerts raised before a silence started still resolve.
+func (ss *silences) silenced(e Event) bool {
+	if e.Resolved {
+		return false
+	}
+	now := e.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for _, s := range ss.active(now) {
+		if s.matches(e) {
+			return true
+		}
+	}
+	return false
+}
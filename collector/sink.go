@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+)
+
+// Sink receives every sample emitted by a Collector.
+type Sink interface {
+	Emit(Fields) error
+}
+
+// SinkFunc is an adapter to allow the use of ordinary functions as a Sink.
+type SinkFunc func(Fields) error
+
+// Emit implements Sink.
+func (f SinkFunc) Emit(fields Fields) error {
+	return f(fields)
+}
+
+// SinkError is passed to a Collector's ErrorFunc when a sink fails to emit a sample.
+type SinkError struct {
+	Sink Sink
+	Err  error
+}
+
+func (e *SinkError) Error() string {
+	return fmt.Sprintf("collector: sink %T: %v", e.Sink, e.Err)
+}
+
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// AddSink registers s to receive every sample after the Collector's FieldsFunc. Samples
+// buffered under NoSinkBuffer are replayed to s first.
+func (c *Collector) AddSink(s Sink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replayBuffered(s)
+	c.Sinks = append(c.Sinks, s)
+}
+
+// WithSink registers a sink at construction.
+func WithSink(s Sink) Option {
+	return func(c *Collector) { c.Sinks = append(c.Sinks, s) }
+}
+
+// WithErrorFunc sets the callback receiving errors.
+func WithErrorFunc(fn func(error)) Option {
+	return func(c *Collector) { c.ErrorFunc = fn }
+}
+
+// emitSinks passes f to sinks, reporting failures to ErrorFunc.
+func (c *Collector) emitSinks(f Fields, sinks []Sink) {
+	for _, s := range sinks {
+		if err := s.Emit(f); err != nil {
+			c.reportError(&SinkError{Sink: s, Err: err})
+		}
+		if b, ok := s.(BufferedSink); ok {
+			c.sinkBuffers.observe(b)
+		}
+	}
+}
+
+// reportError passes err to ErrorFunc, or logs it if no ErrorFunc is set.
+func (c *Collector) reportError(err error) {
+	if c.ErrorFunc != nil {
+		c.ErrorFunc(err)
+		return
+	}
+	log.Println(err)
+}
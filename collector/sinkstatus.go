@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BufferedSink is implemented by sinks that keep a local buffer of what they could not
+// send yet, such as while their backend is unreachable, so that the Collector can report
+// the buffering of the telemetry path itself.
+type BufferedSink interface {
+	Sink
+
+	// Buffered returns the number of items, such as lines or samples, waiting in the
+	// buffer, and the rate in items per second the sink last drained it at, or zero if it
+	// has not drained any yet.
+	Buffered() (depth int, drainRate float64)
+}
+
+// SinkStatus describes the local buffer of a BufferedSink, as observed after its latest
+// emission.
+type SinkStatus struct {
+	// Name identifies the sink in the meta.sink.<name>.* fields, such as "graphite", or
+	// "graphite_2" for the second graphite sink.
+	Name string `json:"name"`
+
+	Sink Sink `json:"-"`
+
+	// Depth is the number of items waiting in the buffer, and HighWater the most that ever
+	// waited.
+	Depth     int `json:"depth"`
+	HighWater int `json:"high_water"`
+
+	// Drain is how long sending the buffer is expected to take at the rate the sink last
+	// drained it at, zero for an empty buffer and -1 if the rate is not known yet.
+	Drain time.Duration `json:"drain_ns"`
+}
+
+// sinkBuffers tracks the buffers of the sinks of a Collector. It has its own lock, since
+// sinks are emitted to without holding the Collector's while Run emits asynchronously.
+type sinkBuffers struct {
+	mu      sync.Mutex
+	buffers map[Sink]*SinkStatus
+	names   map[string]int
+}
+
+// observe records the state of the buffer of s after an emission. Sinks whose type cannot
+// be used as a map key, such as functions, are not tracked.
+func (sb *sinkBuffers) observe(s BufferedSink) {
+	if !reflect.TypeOf(s).Comparable() {
+		return
+	}
+	depth, rate := s.Buffered()
+
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	st, ok := sb.buffers[s]
+	if !ok {
+		if sb.buffers == nil {
+			sb.buffers, sb.names = make(map[Sink]*SinkStatus), make(map[string]int)
+		}
+		name := sinkName(s)
+		if sb.names[name]++; sb.names[name] > 1 {
+			name += "_" + strconv.Itoa(sb.names[name])
+		}
+		st = &SinkStatus{Name: name, Sink: s}
+		sb.buffers[s] = st
+	}
+	st.Depth = depth
+	if depth > st.HighWater {
+		st.HighWater = depth
+	}
+	switch {
+	case depth == 0:
+		st.Drain = 0
+	case rate > 0:
+		st.Drain = time.Duration(float64(depth) / rate * float64(time.Second))
+	default:
+		st.Drain = -1
+	}
+}
+
+func (sb *sinkBuffers) statuses() []SinkStatus {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	statuses := make([]SinkStatus, 0, len(sb.buffers))
+	for _, st := range sb.buffers {
+		statuses = append(statuses, *st)
+	}
+	return statuses
+}
+
+// sinkName derives the name of a sink from the package of its type, such as "graphite" for
+// a *graphite.Sink.
+func sinkName(s Sink) string {
+	t := reflect.TypeOf(s)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := t.PkgPath()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || name == "collector" || name == "main" {
+		name = strings.ToLower(t.Name())
+	}
+	return name
+}
+
+// SinkStatus returns the status of the local buffers of the sinks implementing
+// BufferedSink, including those of Tiers, that were emitted to at least once, sorted by
+// name.
+func (c *Collector) SinkStatus() []SinkStatus {
+	statuses := c.sinkBuffers.statuses()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// outputSinkBuffers reports the buffers of the sinks as meta.sink.<name>.depth,
+// meta.sink.<name>.high_water and meta.sink.<name>.drain_seconds in Extra, the latter -1
+// while the drain rate is not known.
+func (c *Collector) outputSinkBuffers(ff *fieldFilter) {
+	for _, st := range c.sinkBuffers.statuses() {
+		prefix := "meta.sink." + st.Name + "."
+		drain := st.Drain.Seconds()
+		if st.Drain < 0 {
+			drain = -1
+		}
+		for _, v := range []struct {
+			name  string
+			value float64
+		}{
+			{"depth", float64(st.Depth)},
+			{"high_water", float64(st.HighWater)},
+			{"drain_seconds", drain},
+		} {
+			if ff == nil || ff.allowed(prefix+v.name) {
+				c.fields.setExtra(prefix+v.name, v.value)
+			}
+		}
+	}
+}
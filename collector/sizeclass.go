@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+)
+
+// sizeClass is the cumulative number of objects allocated and freed in a size class.
+type sizeClass struct {
+	Size    uint32
+	Mallocs uint64
+	Frees   uint64
+}
+
+// outputSizeClasses reports the size classes objects were allocated in, as
+// mem.sizeclass.<bytes>.mallocs and mem.sizeclass.<bytes>.frees in Extra.
+func (c *Collector) outputSizeClasses(m *runtime.MemStats, ff *fieldFilter) {
+	classes := memStatsSizeClasses(m)
+	if c.UseRuntimeMetrics {
+		classes = c.rm.sizeClasses()
+	}
+	for _, sc := range classes {
+		if sc.Mallocs == 0 {
+			continue
+		}
+		prefix := "mem.sizeclass." + strconv.FormatUint(uint64(sc.Size), 10)
+		if c.keeps(ff, prefix+".mallocs") {
+			c.fields.setExtra(prefix+".mallocs", float64(sc.Mallocs))
+		}
+		if c.keeps(ff, prefix+".frees") {
+			c.fields.setExtra(prefix+".frees", float64(sc.Frees))
+		}
+	}
+}
+
+// collectsSizeClasses reports whether the size classes may pass ff. Their fields are named
+// after the classes, so those of the smallest class stand for them all.
+func (c *Collector) collectsSizeClasses(ff *fieldFilter) bool {
+	return ff == nil || ff.allowed("mem.sizeclass.8.mallocs") || ff.allowed("mem.sizeclass.8.frees") ||
+		c.collects(ff, sizeClassGroup)
+}
+
+// memStatsSizeClasses returns the size classes of m.BySize, which stops at 18432 bytes.
+func memStatsSizeClasses(m *runtime.MemStats) []sizeClass {
+	classes := make([]sizeClass, 0, len(m.BySize))
+	for _, s := range m.BySize {
+		if s.Size > 0 {
+			classes = append(classes, sizeClass{Size: s.Size, Mallocs: s.Mallocs, Frees: s.Frees})
+		}
+	}
+	return classes
+}
+
+// sizeClasses reads the size classes from the allocs-by-size and frees-by-size histograms
+// of runtime/metrics, whose buckets end one byte past the size of each class. The last
+// bucket, of objects too large for any class, is left out.
+func (r *runtimeMetricsReader) sizeClasses() []sizeClass {
+	if r.bySize == nil {
+		r.bySize = []metrics.Sample{
+			{Name: "/gc/heap/allocs-by-size:bytes"},
+			{Name: "/gc/heap/frees-by-size:bytes"},
+		}
+	}
+	metrics.Read(r.bySize)
+	if r.bySize[0].Value.Kind() != metrics.KindFloat64Histogram || r.bySize[1].Value.Kind() != metrics.KindFloat64Histogram {
+		return nil
+	}
+	allocs, frees := r.bySize[0].Value.Float64Histogram(), r.bySize[1].Value.Float64Histogram()
+
+	var classes []sizeClass
+	for i, n := range allocs.Counts {
+		end := allocs.Buckets[i+1]
+		if math.IsInf(end, 1) {
+			break
+		}
+		sc := sizeClass{Size: uint32(end - 1), Mallocs: n}
+		if i < len(frees.Counts) {
+			sc.Frees = frees.Counts[i]
+		}
+		classes = append(classes, sc)
+	}
+	return classes
+}
@@ -0,0 +1,52 @@
+package collector
+
+import "time"
+
+// sloBuckets is the number of buckets an SLO window is divided into.
+const sloBuckets = 720
+
+// SLO is a runtime service level objective, such as "GC pause below 10ms in 99% of samples
+// over 30 days", evaluated on every collection. Each sample counts as good when the value
+// of Field is below Threshold. The resulting burn rates are emitted as
+// slo.<Name>.burn_rate (over Window), slo.<Name>.burn_rate_short (over ShortWindow) and
+// slo.<Name>.budget_remaining, which is the fraction of the error budget left in Window.
+// A burn rate of 1 consumes the error budget exactly over Window.
+type SLO struct {
+	// Name identifies the SLO in the emitted fields.
+	Name string
+
+	// Field is the name of the field evaluated, e.g. "mem.gc.pause".
+	Field string
+
+	// Threshold is the value a sample must stay below to count as good.
+	Threshold float64
+
+	// Objective is the targeted fraction of good samples, e.g. 0.99.
+	Objective float64
+
+	// Window is the compliance period. Defaults to 30 days.
+	Window time.Duration
+
+	// ShortWindow is the period of the short burn rate, used for fast-burn alerts.
+	// Defaults to 1 hour.
+	ShortWindow time.Duration
+
+	buckets []sloBucket
+}
+
+type sloBucket struct {
+	Index int64 `json:"index"`
+	Good  int64 `json:"good"`
+	Bad   int64 `json:"bad"`
+}
+
+func (s *SLO) width() time.Duration {
+	window := s.Window
+	if window <= 0 {
+		window = 30 * 24 * time.Hour
+	}
+	if w := window / sloBuckets; w > 0 {
+		return w
+	}
+	return 1
+}
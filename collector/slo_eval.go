@@ -0,0 +1,57 @@
+//go:build !runtimemetrics_minimal
+
+package collector
+
+import "time"
+
+// observe records whether the sample in f met the objective and writes the burn rates to f.
+func (s *SLO) observe(f *Fields, now time.Time) {
+	v, ok := f.Value(s.Field)
+	if !ok {
+		return
+	}
+	if s.buckets == nil {
+		s.buckets = make([]sloBucket, sloBuckets)
+	}
+
+	width := s.width()
+	idx := now.UnixNano() / int64(width)
+	b := &s.buckets[idx%sloBuckets]
+	if b.Index != idx {
+		*b = sloBucket{Index: idx}
+	}
+	if v < s.Threshold {
+		b.Good++
+	} else {
+		b.Bad++
+	}
+
+	short := s.ShortWindow
+	if short <= 0 {
+		short = time.Hour
+	}
+	shortBuckets := int64((short + width - 1) / width)
+
+	budget := 1 - s.Objective
+	burn, shortBurn := s.burnRate(idx, sloBuckets, budget), s.burnRate(idx, shortBuckets, budget)
+	prefix := "slo." + s.Name + "."
+	f.setExtra(prefix+"burn_rate", burn)
+	f.setExtra(prefix+"burn_rate_short", shortBurn)
+	f.setExtra(prefix+"budget_remaining", 1-burn)
+}
+
+// burnRate returns the rate at which the error budget is consumed over the n buckets up to
+// and including idx.
+func (s *SLO) burnRate(idx, n int64, budget float64) float64 {
+	var good, bad int64
+	for _, b := range s.buckets {
+		if b.Index > idx-n && b.Index <= idx {
+			good += b.Good
+			bad += b.Bad
+		}
+	}
+	if good+bad == 0 || budget <= 0 {
+		return 0
+	}
+	return float64(bad) / float64(good+bad) / budget
+}
@@ -0,0 +1,56 @@
+//go:build !runtimemetrics_minimal
+
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSLO(t *testing.T) {
+	slo := &SLO{Name: "gc_pause", Field: "mem.gc.pause", Threshold: 10, Objective: 0.9}
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		f := Fields{PauseNs: 1}
+		if i%5 == 0 {
+			f.PauseNs = 20
+		}
+		slo.observe(&f, now)
+		if i == 9 {
+			if burn := f.Extra["slo.gc_pause.burn_rate"]; burn < 1.99 || burn > 2.01 {
+				t.Errorf("expected a burn rate of 2, got %v", burn)
+			}
+			if left := f.Extra["slo.gc_pause.budget_remaining"]; left > -0.99 || left < -1.01 {
+				t.Errorf("expected the budget to be overspent by 100%%, got %v", left)
+			}
+		}
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	run := func() Fields {
+		var last Fields
+		c := New(func(f Fields) { last = f })
+		c.StateFile = path
+		c.SLOs = []*SLO{{Name: "heap", Field: "mem.heap.alloc", Threshold: 1, Objective: 0.5}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		c.RunContext(ctx)
+		return last
+	}
+
+	run()
+	f := run()
+	if f.Restarts != 1 {
+		t.Errorf("expected one restart, got %d", f.Restarts)
+	}
+	// Both runs had a heap above the threshold, so the restored window holds two bad samples.
+	if burn := f.Extra["slo.heap.burn_rate"]; burn != 2 {
+		t.Errorf("expected the SLO window to be restored, got a burn rate of %v", burn)
+	}
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Source shares runtime readings between the Collectors of a process. When several logical
+// instances, such as embedded test servers or plugin runtimes, each run their own Collector
+// with their own settings, reading the runtime once per instance would multiply the
+// stop-the-world cost of ReadMemStats and yield slightly different numbers for the same
+// moment. Collectors sharing a Source instead reuse a reading for up to MaxAge.
+type Source struct {
+	// MaxAge is how long a reading is reused. Defaults to 1 second.
+	MaxAge time.Duration
+
+	mu   sync.Mutex
+	mem  [2]*runtime.MemStats
+	read [2]time.Time
+	rm   runtimeMetricsReader
+}
+
+// NewSource returns a Source reusing readings for up to maxAge.
+func NewSource(maxAge time.Duration) *Source {
+	return &Source{MaxAge: maxAge}
+}
+
+// readMem returns the memory statistics of the requested backend, read at most MaxAge
+// before now. The result is shared and must not be modified.
+func (s *Source) readMem(useRuntimeMetrics bool, now time.Time) *runtime.MemStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxAge := s.MaxAge
+	if maxAge <= 0 {
+		maxAge = time.Second
+	}
+	backend := 0
+	if useRuntimeMetrics {
+		backend = 1
+	}
+	if m := s.mem[backend]; m != nil && now.Sub(s.read[backend]) < maxAge {
+		return m
+	}
+
+	m := &runtime.MemStats{}
+	if useRuntimeMetrics {
+		s.rm.read(m)
+	} else {
+		runtime.ReadMemStats(m)
+	}
+	s.mem[backend], s.read[backend] = m, now
+	return m
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"os"
+	"runtime"
+)
+
+// startupBaseline is the state of the process the startup.* fields are relative to.
+type startupBaseline struct {
+	mem        runtime.MemStats
+	goroutines int
+	rss        int64
+	hasRSS     bool
+}
+
+func captureStartup() *startupBaseline {
+	b := &startupBaseline{goroutines: runtime.NumGoroutine()}
+	runtime.ReadMemStats(&b.mem)
+	if st, ok := readProcStat(os.Getpid()); ok {
+		b.rss, b.hasRSS = st.RSS, true
+	}
+	return b
+}
+
+// WithStartupBaseline enables the startup.* fields, capturing their baseline in New.
+func WithStartupBaseline() Option {
+	return func(c *Collector) { c.EnableStartupBaseline = true }
+}
+
+// MarkStartup captures the baseline of the startup.* fields now, such as once the
+// application has initialized, so that the fields report the growth since then.
+func (c *Collector) MarkStartup() {
+	b := captureStartup()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startup = b
+}
+
+// outputStartupDeltas reports the growth since the startup baseline, capturing it on the
+// first collection if EnableStartupBaseline was set after New. m is nil if the memory
+// statistics were not read.
+func (c *Collector) outputStartupDeltas(m *runtime.MemStats) {
+	if c.startup == nil {
+		c.startup = captureStartup()
+	}
+	b := c.startup
+	c.fields.StartupGoroutines = int64(runtime.NumGoroutine() - b.goroutines)
+	if m != nil {
+		c.fields.StartupHeapAlloc = int64(m.HeapAlloc) - int64(b.mem.HeapAlloc)
+		c.fields.StartupHeapObjects = int64(m.HeapObjects) - int64(b.mem.HeapObjects)
+		c.fields.StartupAllocated = int64(m.TotalAlloc - b.mem.TotalAlloc)
+		c.fields.StartupSys = int64(m.Sys) - int64(b.mem.Sys)
+	}
+	if b.hasRSS {
+		if st, ok := readProcStat(os.Getpid()); ok {
+			c.fields.StartupRSS = st.RSS - b.rss
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistedState is the derived state written to StateFile. Cumulative runtime counters
+// restart at zero with the process, so the previous sample is deliberately not part of it.
+type persistedState struct {
+	Restarts   int64                  `json:"restarts"`
+	SLOs       map[string][]sloBucket `json:"slos,omitempty"`
+	Experiment *experimentState       `json:"experiment,omitempty"`
+}
+
+type experimentState struct {
+	Arm  int      `json:"arm"`
+	Wall [2]int64 `json:"wall"`
+	Busy [2]int64 `json:"busy"`
+}
+
+// loadState restores the derived state from StateFile and counts the restart.
+func (c *Collector) loadState() {
+	if c.StateFile == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := persistedState{}
+	b, err := os.ReadFile(c.StateFile)
+	if err == nil {
+		err = json.Unmarshal(b, &st)
+		c.restarts = st.Restarts + 1
+	}
+	if err != nil && !os.IsNotExist(err) {
+		c.reportError(fmt.Errorf("collector: restoring state: %w", err))
+		return
+	}
+
+	for _, slo := range c.SLOs {
+		if buckets, ok := st.SLOs[slo.Name]; ok && len(buckets) == sloBuckets {
+			slo.buckets = buckets
+		}
+	}
+	if e := c.Experiment; e != nil && st.Experiment != nil {
+		e.arm = st.Experiment.Arm & 1
+		for i := range e.wall {
+			e.wall[i] = time.Duration(st.Experiment.Wall[i])
+			e.busy[i] = time.Duration(st.Experiment.Busy[i])
+		}
+	}
+}
+
+// saveState writes the derived state to StateFile, replacing it atomically.
+func (c *Collector) saveState() {
+	if c.StateFile == "" {
+		return
+	}
+	c.mu.Lock()
+	st := persistedState{Restarts: c.restarts}
+	for _, slo := range c.SLOs {
+		if slo.buckets != nil {
+			if st.SLOs == nil {
+				st.SLOs = make(map[string][]sloBucket)
+			}
+			st.SLOs[slo.Name] = append([]sloBucket(nil), slo.buckets...)
+		}
+	}
+	if e := c.Experiment; e != nil {
+		// The time spent in the active window counts towards its arm.
+		wall := e.wall
+		if !e.armStart.IsZero() {
+			wall[e.arm] += time.Since(e.armStart)
+		}
+		st.Experiment = &experimentState{Arm: e.arm}
+		for i := range wall {
+			st.Experiment.Wall[i] = int64(wall[i])
+			st.Experiment.Busy[i] = int64(e.busy[i])
+		}
+	}
+	c.mu.Unlock()
+
+	if err := writeFileAtomic(c.StateFile, st); err != nil {
+		c.reportError(fmt.Errorf("collector: saving state: %w", err))
+	}
+}
+
+func writeFileAtomic(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
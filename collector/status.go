@@ -0,0 +1,56 @@
+package collector
+
+import "time"
+
+// Status summarizes the state of a Collector, such as for a health endpoint.
+type Status struct {
+	Instance string `json:"instance,omitempty"`
+
+	// LastCollection is when the latest sample was collected, zero before the first.
+	LastCollection time.Time `json:"last_collection"`
+
+	// Interval is the pause between collections, PauseDur.
+	Interval time.Duration `json:"interval_ns"`
+
+	// Sinks is the number of registered sinks.
+	Sinks int `json:"sinks"`
+
+	// FiringAlerts names the Alerts currently firing.
+	FiringAlerts []string `json:"firing_alerts,omitempty"`
+
+	// ActiveSilences are the silences suppressing events now, see ActiveSilences.
+	ActiveSilences []Silence `json:"active_silences,omitempty"`
+
+	// DroppedEmissions is the number of samples discarded because the emission queue was
+	// full.
+	DroppedEmissions int64 `json:"dropped_emissions"`
+
+	// SinkBuffers describes the local buffers of the sinks implementing BufferedSink.
+	SinkBuffers []SinkStatus `json:"sink_buffers,omitempty"`
+}
+
+// Status returns the current status of c.
+func (c *Collector) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	st := Status{
+		Instance:         c.Instance,
+		Interval:         c.PauseDur,
+		Sinks:            len(c.Sinks),
+		DroppedEmissions: c.droppedEmissions,
+	}
+	st.ActiveSilences = c.ActiveSilences()
+	st.SinkBuffers = c.SinkStatus()
+	if len(st.SinkBuffers) == 0 {
+		st.SinkBuffers = nil
+	}
+	if c.hasLatest {
+		st.LastCollection = c.latest.Time()
+	}
+	for _, a := range c.Alerts {
+		if a.firing {
+			st.FiringAlerts = append(st.FiringAlerts, a.name())
+		}
+	}
+	return st
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"context"
+	"sync"
+)
+
+// subscribers holds the FieldsFuncs registered with Subscribe. It has its own lock so
+// that subscribing does not wait for a collection in progress.
+type subscribers struct {
+	mu    sync.Mutex
+	next  int
+	funcs map[int]FieldsFunc
+	order []int
+}
+
+// Subscribe registers fn to receive every sample after the Collector's FieldsFunc, and
+// returns a function that unregisters it. It is safe to call at any time, including while
+// Run is collecting and from within a subscriber. Calling the returned function more than
+// once has no effect.
+func (c *Collector) Subscribe(fn FieldsFunc) (unsubscribe func()) {
+	s := &c.subs
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.funcs == nil {
+		s.funcs = make(map[int]FieldsFunc)
+	}
+	id := s.next
+	s.next++
+	s.funcs[id] = fn
+	s.order = append(s.order, id)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.funcs, id)
+			for i, v := range s.order {
+				if v == id {
+					s.order = append(s.order[:i:i], s.order[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// streamBuffer is the number of samples Stream buffers for a slow receiver.
+const streamBuffer = 16
+
+// Stream delivers every sample on the returned channel until ctx is done, when the
+// channel is closed. Up to 16 samples are buffered; when the receiver falls further behind
+// the oldest ones are discarded, so that the Collector never waits for it.
+func (c *Collector) Stream(ctx context.Context) <-chan Fields {
+	ch := make(chan Fields, streamBuffer)
+	var mu sync.Mutex
+	closed := false
+	unsubscribe := c.Subscribe(func(f Fields) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		for {
+			select {
+			case ch <- f:
+				return
+			default:
+			}
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	})
+
+	goSelf(func() {
+		<-ctx.Done()
+		unsubscribe()
+		mu.Lock()
+		closed = true
+		close(ch)
+		mu.Unlock()
+	})
+	return ch
+}
+
+// notify passes f to every subscriber in the order they subscribed. The subscribers are
+// called without holding the lock so they may subscribe and unsubscribe.
+func (s *subscribers) notify(f Fields) {
+	s.mu.Lock()
+	funcs := make([]FieldsFunc, 0, len(s.order))
+	for _, id := range s.order {
+		funcs = append(funcs, s.funcs[id])
+	}
+	s.mu.Unlock()
+
+	for _, fn := range funcs {
+		fn(f)
+	}
+}
+
+// len returns the number of subscribers.
+func (s *subscribers) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.order)
+}
@@ -0,0 +1,40 @@
+package collector
+
+// WithTags adds tags to the Collector's Tags.
+func WithTags(tags map[string]string) Option {
+	return func(c *Collector) {
+		if c.Tags == nil {
+			c.Tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			c.Tags[k] = v
+		}
+	}
+}
+
+// sampleTags returns a copy of the tags carried on samples and events, so that sinks may
+// retain them while Tags is changed.
+func (c *Collector) sampleTags() map[string]string {
+	if len(c.Tags) == 0 && c.Instance == "" && !c.EnableRuntimeInfo && !c.EnableTHP && !c.EnableIdentityTags {
+		return nil
+	}
+	tags := make(map[string]string, len(c.Tags)+5)
+	if c.EnableIdentityTags {
+		for k, v := range identityTags() {
+			tags[k] = v
+		}
+	}
+	for k, v := range c.Tags {
+		tags[k] = v
+	}
+	if c.Instance != "" {
+		tags["instance"] = c.Instance
+	}
+	if c.EnableRuntimeInfo {
+		addRuntimeInfoTags(tags)
+	}
+	if c.EnableTHP {
+		addTHPTags(tags)
+	}
+	return tags
+}
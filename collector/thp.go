@@ -0,0 +1,30 @@
+package collector
+
+import "strings"
+
+// outputTHPStats reports the anonymous memory of the process backed by transparent huge
+// pages.
+func (c *Collector) outputTHPStats() {
+	if n, ok := readAnonHugePages(); ok {
+		c.fields.ProcAnonHugePages = n
+	}
+}
+
+// addTHPTags adds the system's transparent huge page settings to tags as "thp_enabled"
+// and "thp_defrag".
+func addTHPTags(tags map[string]string) {
+	if enabled, defrag, ok := readTHPSettings(); ok {
+		tags["thp_enabled"] = thpMode(enabled)
+		tags["thp_defrag"] = thpMode(defrag)
+	}
+}
+
+// thpMode returns the selected mode of a sysfs setting such as "always [madvise] never".
+func thpMode(setting string) string {
+	if i := strings.IndexByte(setting, '['); i >= 0 {
+		if j := strings.IndexByte(setting[i:], ']'); j > 0 {
+			return setting[i+1 : i+j]
+		}
+	}
+	return strings.TrimSpace(setting)
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Tier is an emission tier: it aggregates every Every samples into one and emits it to
+// Sinks, so that a single collection loop can feed a local dashboard every sample and a
+// remote backend a fraction of them:
+//
+//	collector.New(nil,
+//		collector.WithSink(local),
+//		collector.WithTier(6, collector.Mean, remote),
+//	)
+type Tier struct {
+	// Every is the number of samples aggregated into each emitted sample. Values below 2
+	// emit every sample.
+	Every int
+
+	// Aggregation combines the values of the gauges over the samples. Counters and
+	// timestamps, for which only the latest value makes sense, are taken from the latest
+	// sample, as are the tags. Defaults to Instant, emitting the latest sample as is.
+	Aggregation Aggregation
+
+	// Sinks receive the aggregated samples. Failures are reported to the ErrorFunc of the
+	// Collector.
+	Sinks []Sink
+
+	mu      sync.Mutex
+	pending []Fields
+}
+
+// WithTier adds a tier emitting every Nth sample, aggregated by agg, to sinks.
+func WithTier(every int, agg Aggregation, sinks ...Sink) Option {
+	return func(c *Collector) {
+		c.Tiers = append(c.Tiers, &Tier{Every: every, Aggregation: agg, Sinks: sinks})
+	}
+}
+
+// AddTier registers t to receive every sample from now on.
+func (c *Collector) AddTier(t *Tier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Tiers = append(c.Tiers, t)
+}
+
+// add records f and returns the aggregated sample once Every samples have been recorded.
+func (t *Tier) add(f Fields) (Fields, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, f)
+	if len(t.pending) < t.Every {
+		return Fields{}, false
+	}
+	agg := aggregateFields(t.pending, t.Aggregation)
+	t.pending = t.pending[:0]
+	return agg, true
+}
+
+// aggregateFields combines samples, oldest first, into a copy of the latest one whose
+// gauges are aggregated by a.
+func aggregateFields(samples []Fields, a Aggregation) Fields {
+	latest := samples[len(samples)-1]
+	if a == Instant || len(samples) == 1 {
+		return latest
+	}
+	agg := latest
+	if latest.Extra != nil {
+		agg.Extra = make(map[string]float64, len(latest.Extra))
+		for k, v := range latest.Extra {
+			agg.Extra[k] = v
+		}
+	}
+	for name := range latest.ToMap() {
+		if info, ok := DescribeField(name); ok && (info.Type == Counter || info.Unit == unitUnixNanos) {
+			continue
+		}
+		values := make([]float64, 0, len(samples))
+		for i := range samples {
+			if v, ok := samples[i].Value(name); ok {
+				values = append(values, v)
+			}
+		}
+		agg.setValue(name, a.aggregate(values))
+	}
+	return agg
+}
+
+var (
+	fieldIndexOnce sync.Once
+	fieldIndex     map[string]int
+)
+
+// builtinField returns the index in Fields of the named built-in field.
+func builtinField(name string) (int, bool) {
+	fieldIndexOnce.Do(func() {
+		fieldIndex = make(map[string]int)
+		t := reflect.TypeOf(Fields{})
+		for i := 0; i < t.NumField(); i++ {
+			if tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+				fieldIndex[tag] = i
+			}
+		}
+	})
+	i, ok := fieldIndex[name]
+	return i, ok
+}
+
+// setValue sets the named field, rounding v for integer fields.
+func (f *Fields) setValue(name string, v float64) {
+	if _, ok := f.Extra[name]; ok {
+		f.Extra[name] = v
+		return
+	}
+	i, ok := builtinField(name)
+	if !ok {
+		return
+	}
+	switch fv := reflect.ValueOf(f).Elem().Field(i); fv.Kind() {
+	case reflect.Int64:
+		fv.SetInt(int64(math.Round(v)))
+	case reflect.Float64:
+		fv.SetFloat(v)
+	}
+}
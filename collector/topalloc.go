@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AllocSite is a function that allocated memory still in use on the heap.
+type AllocSite struct {
+	// Function is the fully qualified name of the function, such as
+	// "github.com/org/app/cache.(*LRU).Add". Allocations made by the runtime on behalf of
+	// a function, such as growing a slice, are attributed to the function.
+	Function string `json:"function"`
+
+	// InUseBytes and InUseObjects are the memory and objects allocated by the function
+	// that are still in use.
+	InUseBytes   int64 `json:"inuse_bytes"`
+	InUseObjects int64 `json:"inuse_objects"`
+}
+
+// TopAllocators summarizes the heap profile into the N functions holding the most heap
+// memory, bridging the gap between a growing mem.heap.inuse and the code growing it. The
+// summary is attached to every sample as Fields.TopAllocators and passed to Func.
+//
+// The heap profile samples about one allocation per runtime.MemProfileRate bytes and is
+// only updated by garbage collections, so the summary is an estimate as of the latest
+// GC. Walking the profile takes time proportional to the number of allocation sites, so
+// it is only summarized every Interval and the previous summary is reported in between.
+type TopAllocators struct {
+	// N is the number of functions reported. Defaults to 10.
+	N int
+
+	// Interval is how often the heap profile is summarized. Defaults to 1 minute.
+	Interval time.Duration
+
+	// Func, when set, receives every new summary, largest first.
+	Func func([]AllocSite)
+
+	sites   []AllocSite
+	at      time.Time
+	records []runtime.MemProfileRecord
+}
+
+// WithTopAllocators enables the summary of the heap profile.
+func WithTopAllocators(t *TopAllocators) Option {
+	return func(c *Collector) { c.TopAllocators = t }
+}
+
+// summarize returns the summary of the heap profile, refreshing it once Interval passed.
+func (t *TopAllocators) summarize(now time.Time) []AllocSite {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if !t.at.IsZero() && now.Sub(t.at) < interval {
+		return t.sites
+	}
+	t.readProfile()
+	n := t.N
+	if n <= 0 {
+		n = 10
+	}
+	t.sites, t.at = topAllocSites(t.records, n, int64(runtime.MemProfileRate)), now
+	if t.Func != nil {
+		t.Func(append([]AllocSite(nil), t.sites...))
+	}
+	return t.sites
+}
+
+// readProfile reads the heap profile into records, growing it until the profile fits.
+func (t *TopAllocators) readProfile() {
+	n, ok := runtime.MemProfile(t.records, false)
+	for !ok {
+		t.records = make([]runtime.MemProfileRecord, n+n/4+16)
+		n, ok = runtime.MemProfile(t.records, false)
+	}
+	t.records = t.records[:n]
+}
+
+// topAllocSites groups the records of the heap profile by allocating function and returns
+// the n holding the most memory, largest first. The sampled values are scaled to estimates
+// of the totals like pprof does for a profile sampled at rate.
+func topAllocSites(records []runtime.MemProfileRecord, n int, rate int64) []AllocSite {
+	byFunc := map[string]*AllocSite{}
+	for i := range records {
+		r := &records[i]
+		objects, bytes := scaleHeapSample(r.InUseObjects(), r.InUseBytes(), rate)
+		if bytes == 0 {
+			continue
+		}
+		fn := allocatingFunction(r.Stack())
+		site := byFunc[fn]
+		if site == nil {
+			site = &AllocSite{Function: fn}
+			byFunc[fn] = site
+		}
+		site.InUseObjects += objects
+		site.InUseBytes += bytes
+	}
+
+	sites := make([]AllocSite, 0, len(byFunc))
+	for _, site := range byFunc {
+		sites = append(sites, *site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].InUseBytes != sites[j].InUseBytes {
+			return sites[i].InUseBytes > sites[j].InUseBytes
+		}
+		return sites[i].Function < sites[j].Function
+	})
+	if len(sites) > n {
+		sites = sites[:n]
+	}
+	return sites
+}
+
+// allocatingFunction returns the first function of stack outside of the runtime, the one
+// whose code made the allocation.
+func allocatingFunction(stack []uintptr) string {
+	frames := runtime.CallersFrames(stack)
+	first := ""
+	for {
+		frame, more := frames.Next()
+		if first == "" {
+			first = frame.Function
+		}
+		if frame.Function != "" && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame.Function
+		}
+		if !more {
+			break
+		}
+	}
+	if first == "" {
+		return "unknown"
+	}
+	return first
+}
+
+// scaleHeapSample estimates the objects and bytes of which a heap profile sampled at rate
+// recorded count objects of size bytes in total, see runtime.MemProfileRate.
+func scaleHeapSample(count, size, rate int64) (int64, int64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+	if rate <= 1 {
+		return count, size
+	}
+	avg := float64(size) / float64(count)
+	scale := 1 / (1 - math.Exp(-avg/float64(rate)))
+	return int64(float64(count) * scale), int64(float64(size) * scale)
+}
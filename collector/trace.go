@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"context"
+	"math/rand"
+)
+
+// Tracer starts spans for the collector's own operations, so a slow telemetry pipeline
+// shows up in distributed tracing. It is small enough to be satisfied by an adapter over
+// an OpenTelemetry tracer without this package depending on OpenTelemetry:
+//
+//	tracer := otel.Tracer("go-runtime-metrics")
+//	c.Tracer = collector.TracerFunc(func(ctx context.Context, name string) (context.Context, func()) {
+//	    ctx, span := tracer.Start(ctx, name)
+//	    return ctx, func() { span.End() }
+//	})
+//
+// Every traced collection produces a "collector.collect" span with "collector.read" and
+// "collector.emit" children.
+type Tracer interface {
+	// Start begins a span named name as a child of any span in ctx, and returns a context
+	// holding the new span and a function ending it.
+	Start(ctx context.Context, name string) (context.Context, func())
+}
+
+// TracerFunc is an adapter to allow the use of ordinary functions as a Tracer.
+type TracerFunc func(ctx context.Context, name string) (context.Context, func())
+
+// Start implements Tracer.
+func (f TracerFunc) Start(ctx context.Context, name string) (context.Context, func()) {
+	return f(ctx, name)
+}
+
+// trace is a sampled trace of a single collection. A nil trace records nothing.
+type trace struct {
+	tracer Tracer
+	ctx    context.Context
+	end    func()
+}
+
+// startTrace begins the root span of a collection if the collection is sampled.
+func (c *Collector) startTrace() *trace {
+	if c.Tracer == nil || (c.TraceSampleRate > 0 && c.TraceSampleRate < 1 && rand.Float64() >= c.TraceSampleRate) {
+		return nil
+	}
+	ctx, end := c.Tracer.Start(context.Background(), "collector.collect")
+	return &trace{tracer: c.Tracer, ctx: ctx, end: end}
+}
+
+// span begins a child span of the collection.
+func (t *trace) span(name string) func() {
+	if t == nil {
+		return func() {}
+	}
+	_, end := t.tracer.Start(t.ctx, name)
+	return end
+}
+
+func (t *trace) finish() {
+	if t != nil {
+		t.end()
+	}
+}
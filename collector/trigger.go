@@ -0,0 +1,37 @@
+package collector
+
+import "time"
+
+// Trigger makes a running Collector collect immediately instead of waiting for the next
+// interval. It has no effect unless Run is running.
+func (c *Collector) Trigger() {
+	c.TriggerAt(time.Time{})
+}
+
+// TriggerAt makes a running Collector collect at t, or immediately if t has passed. When a
+// coordinator sends the same t to every process of a fleet, the resulting snapshots are
+// aligned to the processes' clocks rather than to whenever the trigger arrived.
+func (c *Collector) TriggerAt(t time.Time) {
+	ch := c.triggers()
+	fire := func() {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A collection is already pending.
+		}
+	}
+	if d := time.Until(t); d > 0 {
+		time.AfterFunc(d, fire)
+		return
+	}
+	fire()
+}
+
+func (c *Collector) triggers() chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.trigger == nil {
+		c.trigger = make(chan struct{}, 1)
+	}
+	return c.trigger
+}
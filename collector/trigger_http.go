@@ -0,0 +1,40 @@
+//go:build !runtimemetrics_minimal
+
+package collector
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TriggerHandler returns an http.Handler that triggers a collection on POST requests. The
+// optional "at" query parameter schedules the collection at a point in time, given either
+// in RFC 3339 format or as Unix milliseconds, so a fleet can be snapshotted consistently.
+func (c *Collector) TriggerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var at time.Time
+		if s := r.URL.Query().Get("at"); s != "" {
+			var err error
+			if at, err = parseTime(s); err != nil {
+				http.Error(w, "invalid at: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		c.TriggerAt(at)
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func parseTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
@@ -0,0 +1,48 @@
+package collector
+
+// ToMapU64 is like ToMap, but reports the fields read from runtime.MemStats as the uint64
+// values the runtime keeps, rather than int64 values that wrap past math.MaxInt64. Sinks
+// whose wire format has unsigned integers use it so that counters such as mem.total stay
+// exact on long-lived processes. Other fields, and fields modified since they were read,
+// such as by OnTick, keep the types ToMap gives them.
+func (f *Fields) ToMapU64() map[string]interface{} {
+	m := f.ToMap()
+	set := func(name string, field int64, v uint64) {
+		if _, ok := m[name]; ok && field == int64(v) {
+			m[name] = v
+		}
+	}
+	if s := f.memStats; s != nil {
+		set("mem.alloc", f.Alloc, s.Alloc)
+		set("mem.total", f.TotalAlloc, s.TotalAlloc)
+		set("mem.sys", f.Sys, s.Sys)
+		set("mem.lookups", f.Lookups, s.Lookups)
+		set("mem.malloc", f.Mallocs, s.Mallocs)
+		set("mem.frees", f.Frees, s.Frees)
+
+		set("mem.heap.alloc", f.HeapAlloc, s.HeapAlloc)
+		set("mem.heap.sys", f.HeapSys, s.HeapSys)
+		set("mem.heap.idle", f.HeapIdle, s.HeapIdle)
+		set("mem.heap.inuse", f.HeapInuse, s.HeapInuse)
+		set("mem.heap.released", f.HeapReleased, s.HeapReleased)
+		set("mem.heap.objects", f.HeapObjects, s.HeapObjects)
+
+		set("mem.stack.inuse", f.StackInuse, s.StackInuse)
+		set("mem.stack.sys", f.StackSys, s.StackSys)
+		set("mem.stack.mspan_inuse", f.MSpanInuse, s.MSpanInuse)
+		set("mem.stack.mspan_sys", f.MSpanSys, s.MSpanSys)
+		set("mem.stack.mcache_inuse", f.MCacheInuse, s.MCacheInuse)
+		set("mem.stack.mcache_sys", f.MCacheSys, s.MCacheSys)
+
+		set("mem.othersys", f.OtherSys, s.OtherSys)
+	}
+	if s := f.gcStats; s != nil {
+		set("mem.gc.sys", f.GCSys, s.GCSys)
+		set("mem.gc.next", f.NextGC, s.NextGC)
+		set("mem.gc.last", f.LastGC, s.LastGC)
+		set("mem.gc.pause_total", f.PauseTotalNs, s.PauseTotalNs)
+		set("mem.gc.pause", f.PauseNs, s.PauseNs[(s.NumGC+255)%256])
+		set("mem.gc.count", f.NumGC, uint64(s.NumGC))
+	}
+	return m
+}
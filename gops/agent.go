@@ -0,0 +1,288 @@
+//go:build !runtimemetrics_minimal
+
+package gops
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// The signals of the gops protocol, the single byte a client sends to select a command.
+const (
+	StackTrace   byte = 0x1
+	GC           byte = 0x2
+	MemStats     byte = 0x3
+	Version      byte = 0x4
+	HeapProfile  byte = 0x5
+	CPUProfile   byte = 0x6
+	Stats        byte = 0x7
+	Trace        byte = 0x8
+	BinaryDump   byte = 0x9
+	SetGCPercent byte = 0x10
+)
+
+// Options configures the agent started by Listen.
+type Options struct {
+	// Addr is the address the agent listens on. Defaults to "127.0.0.1:0", a free port on
+	// the loopback interface.
+	Addr string
+
+	// ConfigDir is the directory the port of the agent is written to, in a file named
+	// after the PID, which is where gops looks for it. Defaults to the directory gops uses:
+	// $GOPS_CONFIG_DIR, or gops in $APPDATA on Windows, $XDG_CONFIG_HOME or ~/.config.
+	ConfigDir string
+
+	// CPUProfileDuration and TraceDuration are how long the CPU profile and execution trace
+	// requested by gops are recorded for. Default to 30 and 5 seconds like the gops agent.
+	CPUProfileDuration time.Duration
+	TraceDuration      time.Duration
+}
+
+// Agent answers gops requests about the process and its Collector.
+type Agent struct {
+	c        *collector.Collector
+	o        Options
+	ln       net.Listener
+	portFile string
+
+	// profiling serializes the CPU profiles and traces, which the runtime only records one
+	// at a time.
+	profiling sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// Listen starts an agent answering gops requests for c, and writes its port where gops
+// will find it.
+func Listen(c *collector.Collector, o Options) (*Agent, error) {
+	dir := o.ConfigDir
+	if dir == "" {
+		var err error
+		if dir, err = configDir(); err != nil {
+			return nil, err
+		}
+	}
+	addr := o.Addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	a := &Agent{c: c, o: o, ln: ln, portFile: filepath.Join(dir, strconv.Itoa(os.Getpid()))}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	if err := os.WriteFile(a.portFile, []byte(port), 0o644); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	a.wg.Add(1)
+	go a.serve()
+	return a, nil
+}
+
+// Addr returns the address the agent listens on.
+func (a *Agent) Addr() string {
+	return a.ln.Addr().String()
+}
+
+// Close stops the agent and removes its port file. Requests being answered are finished.
+func (a *Agent) Close() error {
+	err := a.ln.Close()
+	if rerr := os.Remove(a.portFile); err == nil && !os.IsNotExist(rerr) {
+		err = rerr
+	}
+	a.wg.Wait()
+	return err
+}
+
+// configDir returns the directory gops reads the ports of agents from.
+func configDir() (string, error) {
+	if dir := os.Getenv("GOPS_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gops"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gops"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gops"), nil
+}
+
+func (a *Agent) serve() {
+	defer a.wg.Done()
+	for {
+		conn, err := a.ln.Accept()
+		if err != nil {
+			return
+		}
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			sig, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			if err := a.handle(conn, r, sig); err != nil {
+				fmt.Fprintf(conn, "gops: %v\n", err)
+			}
+		}()
+	}
+}
+
+// handle answers the request sig, reading its arguments from r.
+func (a *Agent) handle(w io.Writer, r io.ByteReader, sig byte) error {
+	switch sig {
+	case StackTrace:
+		return pprof.Lookup("goroutine").WriteTo(w, 2)
+	case GC:
+		runtime.GC()
+		_, err := io.WriteString(w, "ok")
+		return err
+	case MemStats:
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return writeMemStats(w, &m)
+	case Version:
+		_, err := io.WriteString(w, runtime.Version())
+		return err
+	case HeapProfile:
+		return pprof.WriteHeapProfile(w)
+	case CPUProfile:
+		a.profiling.Lock()
+		defer a.profiling.Unlock()
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return err
+		}
+		time.Sleep(duration(a.o.CPUProfileDuration, 30*time.Second))
+		pprof.StopCPUProfile()
+		return nil
+	case Stats:
+		return a.writeStats(w)
+	case Trace:
+		a.profiling.Lock()
+		defer a.profiling.Unlock()
+		if err := trace.Start(w); err != nil {
+			return err
+		}
+		time.Sleep(duration(a.o.TraceDuration, 5*time.Second))
+		trace.Stop()
+		return nil
+	case BinaryDump:
+		path, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	case SetGCPercent:
+		perc, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "New GC percent set to %v. Previous value was %v.", perc, debug.SetGCPercent(int(perc)))
+		return err
+	}
+	return fmt.Errorf("unknown signal %#x", sig)
+}
+
+func duration(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// writeStats writes the statistics of the gops agent, followed by the fields of the
+// latest sample of the Collector, sorted by name.
+func (a *Agent) writeStats(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "goroutines: %v\n", runtime.NumGoroutine())
+	n, _ := runtime.ThreadCreateProfile(nil)
+	fmt.Fprintf(bw, "OS threads: %v\n", n)
+	fmt.Fprintf(bw, "GOMAXPROCS: %v\n", runtime.GOMAXPROCS(0))
+	fmt.Fprintf(bw, "num CPU: %v\n", runtime.NumCPU())
+
+	if f, ok := a.c.Snapshot(); ok {
+		m := f.ToMap()
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(bw, "%s: %v\n", name, m[name])
+		}
+	}
+	return bw.Flush()
+}
+
+func writeMemStats(w io.Writer, m *runtime.MemStats) error {
+	bw := bufio.NewWriter(w)
+	for _, s := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"alloc", m.Alloc},
+		{"total-alloc", m.TotalAlloc},
+		{"sys", m.Sys},
+		{"lookups", m.Lookups},
+		{"mallocs", m.Mallocs},
+		{"frees", m.Frees},
+		{"heap-alloc", m.HeapAlloc},
+		{"heap-sys", m.HeapSys},
+		{"heap-idle", m.HeapIdle},
+		{"heap-in-use", m.HeapInuse},
+		{"heap-released", m.HeapReleased},
+		{"heap-objects", m.HeapObjects},
+		{"stack-in-use", m.StackInuse},
+		{"stack-sys", m.StackSys},
+		{"stack-mspan-inuse", m.MSpanInuse},
+		{"stack-mspan-sys", m.MSpanSys},
+		{"stack-mcache-inuse", m.MCacheInuse},
+		{"stack-mcache-sys", m.MCacheSys},
+		{"other-sys", m.OtherSys},
+		{"gc-sys", m.GCSys},
+		{"next-gc: when heap-alloc >=", m.NextGC},
+		{"last-gc", time.Unix(0, int64(m.LastGC))},
+		{"gc-pause-total", time.Duration(m.PauseTotalNs)},
+		{"gc-pause", time.Duration(m.PauseNs[(m.NumGC+255)%256])},
+		{"num-gc", m.NumGC},
+		{"num-forced-gc", m.NumForcedGC},
+		{"gc-cpu-fraction", m.GCCPUFraction},
+		{"enable-gc", m.EnableGC},
+		{"debug-gc", m.DebugGC},
+	} {
+		fmt.Fprintf(bw, "%s: %v\n", s.name, s.value)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,82 @@
+//go:build !runtimemetrics_minimal
+
+package gops
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestAgent(t *testing.T) {
+	c := collector.New(nil)
+	c.OneOff()
+	dir := t.TempDir()
+	a, err := Listen(c, Options{ConfigDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(os.Getpid())))
+	if err != nil || !strings.HasSuffix(a.Addr(), ":"+string(port)) {
+		t.Fatalf("expected the port of %s in the port file, got %q and %v", a.Addr(), port, err)
+	}
+
+	if got := request(t, a, Version); got != runtime.Version() {
+		t.Errorf("unexpected version %q", got)
+	}
+	if got := request(t, a, GC); got != "ok" {
+		t.Errorf("unexpected GC response %q", got)
+	}
+	stats := request(t, a, Stats)
+	if !strings.HasPrefix(stats, "goroutines: ") || !strings.Contains(stats, "\nmem.heap.alloc: ") {
+		t.Errorf("expected the gops statistics and the sample, got:\n%s", stats)
+	}
+	if got := request(t, a, MemStats); !strings.Contains(got, "\nheap-alloc: ") {
+		t.Errorf("unexpected memory statistics:\n%s", got)
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	binary.PutVarint(buf, 150)
+	prev := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(prev)
+	if got := request(t, a, append([]byte{SetGCPercent}, buf...)...); got != "New GC percent set to 150. Previous value was 100." {
+		t.Errorf("unexpected response %q", got)
+	}
+	if got := request(t, a, 0x42); !strings.Contains(got, "unknown signal") {
+		t.Errorf("expected an error for an unknown signal, got %q", got)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, strconv.Itoa(os.Getpid()))); !os.IsNotExist(err) {
+		t.Errorf("expected the port file to be removed, got %v", err)
+	}
+}
+
+func request(t *testing.T, a *Agent, b ...byte) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", a.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
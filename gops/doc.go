@@ -0,0 +1,16 @@
+// Package gops serves the agent protocol of github.com/google/gops, so that the gops
+// command lists processes instrumented with this package and queries them for their stack
+// dumps, memory statistics and profiles, or makes them collect garbage, without
+// depending on the gops module:
+//
+//	a, err := gops.Listen(c, gops.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer a.Close()
+//
+// "gops stats <pid>" prints the latest sample of the Collector after the statistics gops
+// shows for its own agent. The agent listens on the loopback interface and, like the gops
+// agent, does not authenticate its clients. The package is not available in builds with
+// the runtimemetrics_minimal tag.
+package gops
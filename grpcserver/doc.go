@@ -0,0 +1,16 @@
+// Package grpcserver streams the samples of a Collector to gRPC clients, so that a sidecar
+// aggregating many processes receives every sample as it is collected rather than polling
+// their HTTP endpoints. The service is defined in metrics.proto:
+//
+//	s := grpcserver.New(c)
+//	l, err := net.Listen("tcp", "127.0.0.1:9090")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go s.Serve(l)
+//
+// The package speaks the gRPC protocol over the HTTP/2 server of net/http and does not
+// depend on the gRPC module. Serve accepts plaintext HTTP/2 connections, which requires
+// Go 1.24; with earlier versions the Server is an http.Handler to be served over TLS. The
+// package is not available in builds with the runtimemetrics_minimal tag.
+package grpcserver
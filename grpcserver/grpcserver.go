@@ -0,0 +1,231 @@
+//go:build !runtimemetrics_minimal
+
+package grpcserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// WatchPath is the path of the Watch method, as called by gRPC clients.
+const WatchPath = "/runtimemetrics.v1.Metrics/Watch"
+
+// The gRPC status codes the Server responds with.
+const (
+	codeOK            = 0
+	codeInvalidArg    = 3
+	codeUnimplemented = 12
+)
+
+// maxRequestSize bounds the WatchRequest a client may send.
+const maxRequestSize = 1 << 20
+
+// Server serves the Metrics service of metrics.proto for a Collector.
+type Server struct {
+	c *collector.Collector
+
+	mu  sync.Mutex
+	srv *http.Server
+}
+
+// New returns a Server streaming the samples of c.
+func New(c *collector.Collector) *Server {
+	return &Server{c: c}
+}
+
+// Close closes the listeners passed to Serve, ending the streams in progress.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Close()
+}
+
+// ServeHTTP implements http.Handler, answering gRPC calls made over HTTP/2.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "grpcserver: expected a gRPC call over HTTP/2", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	if r.URL.Path != WatchPath {
+		writeStatus(w, codeUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+	msg, err := readMessage(r.Body)
+	if err != nil {
+		writeStatus(w, codeInvalidArg, err.Error())
+		return
+	}
+	fields, err := decodeWatchRequest(msg)
+	if err != nil {
+		writeStatus(w, codeInvalidArg, err.Error())
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Trailer", "Grpc-Status")
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	var buf []byte
+	for f := range s.c.Stream(r.Context()) {
+		buf = appendMessage(buf[:0], encodeSnapshot(&f, fields))
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	w.Header().Set("Grpc-Status", fmt.Sprint(codeOK))
+}
+
+// writeStatus ends a call without messages, sending the status in the headers.
+func writeStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Grpc-Status", fmt.Sprint(code))
+	w.Header().Set("Grpc-Message", msg)
+	w.WriteHeader(http.StatusOK)
+}
+
+// readMessage reads the single length-prefixed message of a unary request.
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, fmt.Errorf("grpcserver: reading request: %w", err)
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("grpcserver: compressed requests are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxRequestSize {
+		return nil, fmt.Errorf("grpcserver: request of %d bytes is too large", n)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("grpcserver: reading request: %w", err)
+	}
+	return msg, nil
+}
+
+// appendMessage appends msg to b with the prefix of an uncompressed gRPC message.
+func appendMessage(b, msg []byte) []byte {
+	b = append(b, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// decodeWatchRequest returns the fields of a WatchRequest, skipping unknown fields.
+func decodeWatchRequest(b []byte) (fields map[string]bool, err error) {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("grpcserver: malformed request")
+		}
+		b = b[n:]
+		switch key & 7 {
+		case 0:
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return nil, errors.New("grpcserver: malformed request")
+			}
+		case 1:
+			n = 8
+		case 2:
+			l, ln := binary.Uvarint(b)
+			if ln <= 0 || l > uint64(len(b)-ln) {
+				return nil, errors.New("grpcserver: malformed request")
+			}
+			if key>>3 == 1 {
+				if fields == nil {
+					fields = make(map[string]bool)
+				}
+				fields[string(b[ln:ln+int(l)])] = true
+			}
+			n = ln + int(l)
+		case 5:
+			n = 4
+		default:
+			return nil, errors.New("grpcserver: malformed request")
+		}
+		if n > len(b) {
+			return nil, errors.New("grpcserver: malformed request")
+		}
+		b = b[n:]
+	}
+	return fields, nil
+}
+
+// encodeSnapshot encodes f as a Snapshot, keeping the named fields if any.
+func encodeSnapshot(f *collector.Fields, fields map[string]bool) []byte {
+	var b []byte
+	if f.Timestamp != 0 {
+		b = binary.AppendUvarint(append(b, 1<<3|0), uint64(f.Timestamp))
+	}
+	b = appendString(b, 2, f.Instance)
+	for _, k := range sortedKeys(f.Tags) {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, f.Tags[k])
+		b = appendBytes(b, 3, entry)
+	}
+	values := f.ToMap()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if fields == nil || fields[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var v float64
+		switch x := values[name].(type) {
+		case int64:
+			v = float64(x)
+		case float64:
+			v = x
+		default:
+			continue
+		}
+		var entry []byte
+		entry = appendString(entry, 1, name)
+		entry = binary.LittleEndian.AppendUint64(append(entry, 2<<3|1), math.Float64bits(v))
+		b = appendBytes(b, 4, entry)
+	}
+	return appendString(b, 5, f.ID)
+}
+
+// appendString appends a string field, which proto3 leaves out when empty.
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytes(b, field, []byte(s))
+}
+
+// appendBytes appends a length-delimited field.
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+//go:build go1.24 && !runtimemetrics_minimal
+
+package grpcserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func call(t *testing.T, addr, path string, req []byte) *http.Response {
+	t.Helper()
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	r, _ := http.NewRequest(http.MethodPost, "http://"+addr+path, bytes.NewReader(appendMessage(nil, req)))
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+	resp, err := client.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// decodeValues returns the values map of a Snapshot.
+func decodeValues(t *testing.T, b []byte) map[string]float64 {
+	values := make(map[string]float64)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		if key&7 == 0 {
+			_, n = binary.Uvarint(b)
+			b = b[n:]
+			continue
+		}
+		l, n := binary.Uvarint(b)
+		field, entry := key>>3, b[n:n+int(l)]
+		b = b[n+int(l):]
+		if field != 4 {
+			continue
+		}
+		// The entry holds the name as field 1 and the value as the fixed64 field 2.
+		nl := int(entry[1])
+		name := string(entry[2 : 2+nl])
+		if entry[2+nl] != 2<<3|1 {
+			t.Fatalf("unexpected value in entry %q", entry)
+		}
+		values[name] = math.Float64frombits(binary.LittleEndian.Uint64(entry[3+nl:]))
+	}
+	return values
+}
+
+func TestWatch(t *testing.T) {
+	c := collector.New(nil)
+	s := New(c)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer s.Close()
+
+	resp := call(t, l.Addr().String(), "/runtimemetrics.v1.Metrics/Unknown", nil)
+	resp.Body.Close()
+	if got := resp.Header.Get("Grpc-Status"); got != "12" {
+		t.Errorf("expected an unimplemented status, got %q", got)
+	}
+
+	// A WatchRequest naming two fields.
+	var req []byte
+	req = appendString(req, 1, "cpu.goroutines")
+	req = appendString(req, 1, "app.queue")
+	resp = call(t, l.Addr().String(), WatchPath, req)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/grpc" {
+		t.Fatalf("unexpected response %s", resp.Status)
+	}
+
+	c.RegisterGauge("app.queue", func() float64 { return 2.5 })
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				c.OneOff()
+			}
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		msg, err := readMessage(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := decodeValues(t, msg)
+		if len(values) != 2 || values["cpu.goroutines"] < 1 || values["app.queue"] != 2.5 {
+			t.Errorf("expected the requested fields, got %v", values)
+		}
+	}
+}
+
+func TestDecodeWatchRequest(t *testing.T) {
+	if _, err := decodeWatchRequest([]byte{1<<3 | 2, 10, 'a'}); err == nil {
+		t.Error("expected an error for a truncated request")
+	}
+	fields, err := decodeWatchRequest([]byte{2<<3 | 0, 1, 1<<3 | 2, 1, 'a'})
+	if err != nil || !fields["a"] || len(fields) != 1 {
+		t.Errorf("expected the unknown field to be skipped, got %v and %v", fields, err)
+	}
+}
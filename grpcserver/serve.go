@@ -0,0 +1,20 @@
+//go:build go1.24 && !runtimemetrics_minimal
+
+package grpcserver
+
+import (
+	"net"
+	"net/http"
+)
+
+// Serve accepts the plaintext HTTP/2 connections of gRPC clients on l, until Close is
+// called.
+func (s *Server) Serve(l net.Listener) error {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: s, Protocols: &protocols}
+	s.mu.Lock()
+	s.srv = srv
+	s.mu.Unlock()
+	return srv.Serve(l)
+}
@@ -0,0 +1,15 @@
+//go:build !go1.24 && !runtimemetrics_minimal
+
+package grpcserver
+
+import (
+	"errors"
+	"net"
+)
+
+// Serve accepts the plaintext HTTP/2 connections of gRPC clients on l, which net/http
+// supports from Go 1.24. With earlier versions it returns an error, and the Server is to
+// be served as an http.Handler by a server using TLS.
+func (s *Server) Serve(l net.Listener) error {
+	return errors.New("grpcserver: plaintext HTTP/2 requires Go 1.24")
+}
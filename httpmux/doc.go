@@ -0,0 +1,11 @@
+// Package httpmux serves the endpoints of a Collector, and optionally the profiles of
+// net/http/pprof, from a single handler behind shared authentication, so that operators
+// get metrics, history, status and profiles from one configured port:
+//
+//	h := httpmux.New(c, httpmux.Options{Pprof: true, Username: "ops", Password: secret})
+//	go http.ListenAndServe(":6060", h)
+//
+// Importing the package imports net/http/pprof, which registers its handlers on
+// http.DefaultServeMux. Programs serving http.DefaultServeMux publicly should not import
+// it. The package is not available in builds with the runtimemetrics_minimal tag.
+package httpmux
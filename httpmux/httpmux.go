@@ -0,0 +1,132 @@
+//go:build !runtimemetrics_minimal
+
+package httpmux
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// DefaultPrefix is the path the endpoints of the Collector are served under unless Prefix
+// is set.
+const DefaultPrefix = "/debug/runtime-metrics"
+
+// Options configures the handler returned by New.
+type Options struct {
+	// Prefix is the path the endpoints of the Collector are served under:
+	//
+	//	<prefix>/metrics  the latest sample, see Collector.Handler
+	//	<prefix>/history  the samples kept under HistorySize
+	//	<prefix>/status   the status of the Collector
+	//	<prefix>/trigger  triggers a collection on POST, see Collector.TriggerHandler
+	//
+	// Defaults to DefaultPrefix.
+	Prefix string
+
+	// Pprof also serves the profiles of net/http/pprof under /debug/pprof/.
+	Pprof bool
+
+	// Username and Password, when Password is set, accept requests with HTTP basic
+	// authentication. Token, when set, accepts requests with the bearer token. Authorize,
+	// when set, accepts the requests it returns true for. A request is served if any of
+	// the configured methods accepts it, and every request is served if none is configured.
+	Username  string
+	Password  string
+	Token     string
+	Authorize func(r *http.Request) bool
+}
+
+// New returns a handler serving the endpoints of c, and of net/http/pprof if o.Pprof is
+// set, behind the authentication configured in o.
+func New(c *collector.Collector, o Options) http.Handler {
+	prefix := strings.TrimSuffix(o.Prefix, "/")
+	if o.Prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/metrics", c.Handler())
+	mux.Handle(prefix+"/history", jsonHandler(func() interface{} { return history(c) }))
+	mux.Handle(prefix+"/status", jsonHandler(func() interface{} { return c.Status() }))
+	mux.Handle(prefix+"/trigger", c.TriggerHandler())
+	if o.Pprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	if o.Password == "" && o.Token == "" && o.Authorize == nil {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !o.authorized(r) {
+			if o.Password != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="runtime metrics"`)
+			} else if o.Token != "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (o *Options) authorized(r *http.Request) bool {
+	if o.Password != "" {
+		if user, pass, ok := r.BasicAuth(); ok && equal(user, o.Username) && equal(pass, o.Password) {
+			return true
+		}
+	}
+	if o.Token != "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") && equal(auth[len("Bearer "):], o.Token) {
+			return true
+		}
+	}
+	return o.Authorize != nil && o.Authorize(r)
+}
+
+// equal compares credentials in constant time.
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+type sample struct {
+	Time   string                 `json:"time"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+func history(c *collector.Collector) []sample {
+	all := c.History()
+	samples := make([]sample, len(all))
+	for i, s := range all {
+		samples[i] = sample{Time: s.Time.UTC().Format(time.RFC3339Nano), Fields: s.ToMap()}
+	}
+	return samples
+}
+
+// jsonHandler serves the value returned by fn as JSON on GET and HEAD requests.
+func jsonHandler(fn func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := json.MarshalIndent(fn(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(append(b, '\n'))
+	})
+}
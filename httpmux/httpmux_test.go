@@ -0,0 +1,66 @@
+//go:build !runtimemetrics_minimal
+
+package httpmux
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestNew(t *testing.T) {
+	c := collector.New(nil, collector.WithHistory(5))
+	c.OneOff()
+	c.OneOff()
+	h := New(c, Options{Pprof: true, Username: "ops", Password: "secret", Token: "t0k"})
+
+	get := func(path string, auth func(r *http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != nil {
+			auth(r)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+	basic := func(r *http.Request) { r.SetBasicAuth("ops", "secret") }
+
+	if rec := get("/debug/runtime-metrics/metrics", nil); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected an unauthenticated request to be rejected, got %d", rec.Code)
+	}
+	if rec := get("/debug/pprof/", func(r *http.Request) { r.SetBasicAuth("ops", "nope") }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be rejected, got %d", rec.Code)
+	}
+	if rec := get("/debug/pprof/", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0k") }); rec.Code != http.StatusOK {
+		t.Errorf("expected the pprof index with the token, got %d", rec.Code)
+	}
+
+	var history []sample
+	rec := get("/debug/runtime-metrics/history", basic)
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil || len(history) != 2 || history[0].Fields["cpu.goroutines"] == nil {
+		t.Errorf("unexpected history %s (%v)", rec.Body.String(), err)
+	}
+
+	var st collector.Status
+	c.Silence(collector.Silence{Kind: "alert", End: time.Now().Add(time.Hour), Comment: "deploy"})
+	rec = get("/debug/runtime-metrics/status", basic)
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil || st.LastCollection.IsZero() {
+		t.Errorf("unexpected status %s (%v)", rec.Body.String(), err)
+	}
+	if len(st.ActiveSilences) != 1 || st.ActiveSilences[0].Comment != "deploy" || st.ActiveSilences[0].Kind != "alert" {
+		t.Errorf("expected the active silence in the status, got %s", rec.Body.String())
+	}
+	if rec := get("/debug/runtime-metrics/metrics", basic); rec.Code != http.StatusOK {
+		t.Errorf("expected the latest sample, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	New(c, Options{Prefix: "/rm/"}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected no pprof endpoints unless enabled, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package runstats
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/serializer"
+)
+
+var (
+	once          *bool          = flag.Bool("once", false, "Collect a single sample, write it to stdout and exit")
+	onceFormat    *string        = flag.String("once-format", "json", "Format of the sample written by -once")
+	onceWarmup    *time.Duration = flag.Duration("once-warmup", 0, "Wait before collecting the sample written by -once")
+	onceGC        *bool          = flag.Bool("once-gc", false, "Force a GC before collecting the sample written by -once")
+	maxHeap       *string        = flag.String("max-heap", "", "Exit non-zero if -once measures a larger heap, e.g. 512MiB")
+	maxGoroutines *int           = flag.Int("max-goroutines", 0, "Exit non-zero if -once counts more goroutines")
+)
+
+// runOnce collects a single sample, writes it to w and returns the exit code: 1 if an
+// assertion failed, 2 if the flags are invalid.
+func runOnce(w, errw io.Writer) int {
+	enc, err := serializer.Lookup(*onceFormat)
+	if err != nil {
+		fmt.Fprintln(errw, "error:", err)
+		return 2
+	}
+	var heapLimit int64
+	if *maxHeap != "" {
+		if heapLimit, err = parseSize(*maxHeap); err != nil {
+			fmt.Fprintln(errw, "error:", err)
+			return 2
+		}
+	}
+
+	time.Sleep(*onceWarmup)
+
+	c := collector.New(nil)
+	c.EnableCPU = *cpu
+	c.EnableMem = *mem
+	c.EnableGC = *gc
+	c.ForceGC = *onceGC
+	fields := c.OneOff()
+
+	if err := enc.Encode(w, fields); err != nil {
+		fmt.Fprintln(errw, "error:", err)
+		return 2
+	}
+
+	var rules []collector.Rule
+	if heapLimit > 0 {
+		rules = append(rules, collector.Rule{Field: "mem.heap.alloc", Kind: collector.Max, Limit: float64(heapLimit)})
+	}
+	if *maxGoroutines > 0 {
+		rules = append(rules, collector.Rule{Field: "cpu.goroutines", Kind: collector.Max, Limit: float64(*maxGoroutines)})
+	}
+	violations, err := collector.Assert(fields, rules)
+	if err != nil {
+		fmt.Fprintln(errw, "error:", err)
+		return 2
+	}
+	for _, v := range violations {
+		fmt.Fprintln(errw, "assertion failed:", v)
+	}
+	if len(violations) > 0 {
+		return 1
+	}
+	return 0
+}
+
+var sizeUnits = []struct {
+	suffix string
+	scale  float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// parseSize parses a byte size such as "512MiB", "1.5GB" or "4096".
+func parseSize(s string) (int64, error) {
+	num, scale := strings.TrimSpace(s), 1.0
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, scale = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(v * scale), nil
+}
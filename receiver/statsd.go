@@ -0,0 +1,238 @@
+// Package receiver accepts metrics from other processes on the host and merges them into
+// the samples of a Collector, so that they reach the same sinks as its own statistics
+// and a single forwarding path serves programs not written in Go:
+//
+//	r, err := receiver.ListenStatsD(c, receiver.StatsDOptions{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer r.Close()
+package receiver
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// StatsDOptions configures the receiver started by ListenStatsD.
+type StatsDOptions struct {
+	// Addr is the UDP address the receiver listens on. Defaults to "127.0.0.1:8125", the
+	// StatsD port on the loopback interface.
+	Addr string
+
+	// Prefix is prepended to the names of the received metrics, keeping them apart from
+	// the fields of the Collector, which it must not make them collide with. Defaults to
+	// "statsd.".
+	Prefix string
+
+	// MaxMetrics bounds the number of distinct names the receiver keeps. Metrics with new
+	// names are dropped once it is reached. Defaults to 1000.
+	MaxMetrics int
+}
+
+// StatsD receives StatsD packets and registers every metric they name as a gauge of the
+// Collector. Gauges keep their last value and counters their total since the receiver
+// started, while timers and histograms report their mean and sets their number of
+// distinct values over the interval since the previous collection.
+type StatsD struct {
+	c    *collector.Collector
+	o    StatsDOptions
+	conn net.PacketConn
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	metrics map[string]*statsdMetric
+	dropped int64
+}
+
+// statsdMetric is the state of a received metric between collections.
+type statsdMetric struct {
+	kind  string
+	value float64
+	sum   float64
+	n     int
+	set   map[string]struct{}
+}
+
+// ListenStatsD starts a receiver merging the StatsD metrics sent to it into the samples
+// of c.
+func ListenStatsD(c *collector.Collector, o StatsDOptions) (*StatsD, error) {
+	if o.Addr == "" {
+		o.Addr = "127.0.0.1:8125"
+	}
+	if o.Prefix == "" {
+		o.Prefix = "statsd."
+	}
+	if o.MaxMetrics <= 0 {
+		o.MaxMetrics = 1000
+	}
+	conn, err := net.ListenPacket("udp", o.Addr)
+	if err != nil {
+		return nil, err
+	}
+	r := &StatsD{c: c, o: o, conn: conn, metrics: make(map[string]*statsdMetric)}
+	r.wg.Add(1)
+	go r.serve()
+	return r, nil
+}
+
+// Addr returns the address the receiver listens on.
+func (r *StatsD) Addr() string { return r.conn.LocalAddr().String() }
+
+// Dropped returns the number of lines that could not be parsed, or named a new metric
+// once MaxMetrics was reached.
+func (r *StatsD) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Close stops the receiver and unregisters the gauges of its metrics.
+func (r *StatsD) Close() error {
+	err := r.conn.Close()
+	r.wg.Wait()
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+	for _, name := range names {
+		r.c.UnregisterGauge(name)
+	}
+	return err
+}
+
+func (r *StatsD) serve() {
+	defer r.wg.Done()
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				r.handle(string(line))
+			}
+		}
+	}
+}
+
+// handle records a line, such as "requests:1|c|@0.1" or "latency:12.5|ms|#route:/".
+// Tags are ignored.
+func (r *StatsD) handle(line string) {
+	name, value, kind, rate, err := parseStatsD(line)
+	r.mu.Lock()
+	if err != nil {
+		r.dropped++
+		r.mu.Unlock()
+		return
+	}
+	name = r.o.Prefix + name
+	m, ok := r.metrics[name]
+	if ok && m.kind != kind {
+		// A metric keeps the type it was first received with.
+		r.dropped++
+		r.mu.Unlock()
+		return
+	}
+	if !ok {
+		if len(r.metrics) >= r.o.MaxMetrics {
+			r.dropped++
+			r.mu.Unlock()
+			return
+		}
+		m = &statsdMetric{kind: kind}
+		r.metrics[name] = m
+	}
+	m.record(value, rate)
+	r.mu.Unlock()
+
+	if !ok {
+		// The gauge takes r.mu while the Collector holds its own lock, so it is registered
+		// outside of r.mu.
+		r.c.RegisterGauge(name, func() float64 {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return m.sample()
+		})
+	}
+}
+
+// record adds a received value, parsed by parseStatsD, to the metric.
+func (m *statsdMetric) record(value string, rate float64) {
+	if m.kind == "s" {
+		if m.set == nil {
+			m.set = make(map[string]struct{})
+		}
+		m.set[value] = struct{}{}
+		return
+	}
+	v, _ := strconv.ParseFloat(value, 64)
+	switch m.kind {
+	case "g":
+		if value[0] == '+' || value[0] == '-' {
+			m.value += v
+		} else {
+			m.value = v
+		}
+	case "c":
+		m.value += v / rate
+	case "ms", "h", "d":
+		m.sum += v
+		m.n++
+	}
+}
+
+// sample returns the value of the metric for a collection, resetting those aggregated
+// over the interval.
+func (m *statsdMetric) sample() float64 {
+	switch m.kind {
+	case "ms", "h", "d":
+		if m.n > 0 {
+			m.value = m.sum / float64(m.n)
+			m.sum, m.n = 0, 0
+		}
+	case "s":
+		m.value = float64(len(m.set))
+		m.set = nil
+	}
+	return m.value
+}
+
+// parseStatsD splits a StatsD line into its name, value, type and sample rate.
+func parseStatsD(line string) (name, value, kind string, rate float64, err error) {
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return "", "", "", 0, fmt.Errorf("receiver: no value in %q", line)
+	}
+	name, parts := line[:colon], strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 || parts[0] == "" {
+		return "", "", "", 0, fmt.Errorf("receiver: no type in %q", line)
+	}
+	value, kind, rate = parts[0], parts[1], 1
+	switch kind {
+	case "g", "c", "ms", "h", "d":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", "", "", 0, fmt.Errorf("receiver: invalid value in %q", line)
+		}
+	case "s":
+	default:
+		return "", "", "", 0, fmt.Errorf("receiver: unknown type in %q", line)
+	}
+	for _, p := range parts[2:] {
+		if len(p) > 1 && p[0] == '@' {
+			if rate, err = strconv.ParseFloat(p[1:], 64); err != nil || rate <= 0 || rate > 1 {
+				return "", "", "", 0, fmt.Errorf("receiver: invalid sample rate in %q", line)
+			}
+		}
+	}
+	return name, value, kind, rate, nil
+}
@@ -0,0 +1,56 @@
+package receiver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestStatsD(t *testing.T) {
+	c := collector.New(nil)
+	r, err := ListenStatsD(c, StatsDOptions{Addr: "127.0.0.1:0", MaxMetrics: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("udp", r.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("queue:10|g\nqueue:-3|g\nrequests:2|c\nrequests:1|c|@0.5\n"))
+	conn.Write([]byte("latency:10|ms|#route:/\nlatency:20|ms\nusers:a|s\nusers:b|s\nusers:a|s"))
+	conn.Write([]byte("requests:1|g\nbroken\nmore:1|c\ntoo_many:1|c"))
+
+	deadline := time.Now().Add(time.Second)
+	for r.Dropped() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	c.OneOff()
+	f, _ := c.Snapshot()
+	want := map[string]float64{
+		"statsd.queue":    7,
+		"statsd.requests": 4,
+		"statsd.latency":  15,
+		"statsd.users":    2,
+		"statsd.more":     1,
+	}
+	for name, v := range want {
+		if got, ok := f.Extra[name]; !ok || got != v {
+			t.Errorf("expected %s to be %v, got %v", name, v, got)
+		}
+	}
+	if _, ok := f.Extra["statsd.too_many"]; ok || r.Dropped() != 3 {
+		t.Errorf("expected the mismatched type, the invalid line and the sixth metric to be dropped, got %d", r.Dropped())
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c.OneOff()
+	f, _ = c.Snapshot()
+	if _, ok := f.Extra["statsd.queue"]; ok {
+		t.Errorf("expected the gauges to be unregistered, got %v", f.Extra)
+	}
+}
@@ -39,6 +39,10 @@ func runCollector() {
 		time.Sleep(1 * time.Second)
 	}
 
+	if *once {
+		os.Exit(runOnce(os.Stdout, os.Stderr))
+	}
+
 	if *database == "" {
 		log.Fatalln("error:", "no influxdb database was provided")
 
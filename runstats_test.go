@@ -0,0 +1,20 @@
+package runstats
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	for in, exp := range map[string]int64{
+		"4096":   4096,
+		"512MiB": 512 << 20,
+		"1.5GB":  1500000000,
+		"64 KiB": 64 << 10,
+		"2G":     2 << 30,
+	} {
+		if got, err := parseSize(in); err != nil || got != exp {
+			t.Errorf("parseSize(%q) = %d, %v; exp %d", in, got, err, exp)
+		}
+	}
+	if _, err := parseSize("lots"); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+}
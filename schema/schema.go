@@ -0,0 +1,264 @@
+// Package schema exports the fields of collector.Fields for provisioning metrics
+// backends: as a JSON schema of names, types, units and help, as Prometheus recording and
+// alerting rules, and as a Grafana dashboard.
+//
+// The runtime-metrics-schema command writes each of them from the command line.
+package schema
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/serializer"
+)
+
+// WriteJSON writes fields, such as the result of collector.Describe, as an indented JSON
+// array.
+func WriteJSON(w io.Writer, fields []collector.FieldInfo) error {
+	b, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// PrometheusName returns the name the Prometheus sink exposes the field under: counters
+// carry the conventional _total suffix.
+func PrometheusName(namespace string, f collector.FieldInfo) string {
+	name := serializer.MetricName(namespace, f.Name)
+	if f.Type == collector.Counter {
+		name += "_total"
+	}
+	return name
+}
+
+// PrometheusQuery returns a PromQL expression graphing the field: the per-second rate of
+// counters and the value of gauges.
+func PrometheusQuery(namespace string, f collector.FieldInfo) string {
+	name := PrometheusName(namespace, f)
+	if f.Type == collector.Counter {
+		return "rate(" + name + "[5m])"
+	}
+	return name
+}
+
+// alertScaffolds are the starting points of the alerting rules written by
+// WritePrometheusRules, for the fields they are written for. The thresholds are examples
+// to be tuned per service.
+var alertScaffolds = []struct {
+	field, name, op, threshold, summary string
+}{
+	{"cpu.goroutines", "GoroutineCountHigh", ">", "10000", "Goroutine count is high, which may indicate a leak."},
+	{"mem.gc.cpu_fraction", "GCCPUFractionHigh", ">", "0.25", "The garbage collector is using a large share of CPU time."},
+	{"sched.latency.p99", "SchedulerLatencyHigh", ">", "0.01", "Goroutines wait long to be scheduled."},
+	{"proc.cgroup.memory.utilization", "CgroupMemoryNearLimit", ">", "90", "Memory usage is close to the cgroup limit."},
+	{"proc.cgroup.cpu.throttled_ratio", "CgroupCPUThrottled", ">", "25", "The cgroup is being CPU throttled."},
+}
+
+// WritePrometheusRules writes a Prometheus rules file with a recording rule for the rate
+// of every counter in fields and alerting rules to start from for well-known fields.
+func WritePrometheusRules(w io.Writer, namespace string, fields []collector.FieldInfo) error {
+	group := "go_runtime_metrics"
+	if namespace != "" {
+		group = serializer.MetricName(namespace, "runtime_metrics")
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "groups:\n  - name: %s\n    rules:\n", group)
+
+	byName := make(map[string]collector.FieldInfo, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+		if f.Type != collector.Counter {
+			continue
+		}
+		fmt.Fprintf(bw, "      - record: %s:rate5m\n", serializer.MetricName(namespace, f.Name))
+		fmt.Fprintf(bw, "        expr: %s\n", PrometheusQuery(namespace, f))
+	}
+	for _, a := range alertScaffolds {
+		f, ok := byName[a.field]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(bw, "      - alert: %s\n", a.name)
+		fmt.Fprintf(bw, "        expr: %s %s %s\n", PrometheusQuery(namespace, f), a.op, a.threshold)
+		fmt.Fprintf(bw, "        for: 5m\n")
+		fmt.Fprintf(bw, "        labels:\n          severity: warning\n")
+		fmt.Fprintf(bw, "        annotations:\n          summary: %q\n", a.summary)
+	}
+	return bw.Flush()
+}
+
+// Flavor selects the metrics backend a Dashboard queries.
+type Flavor int
+
+const (
+	// Prometheus queries the metrics exposed by the Prometheus sink with PromQL.
+	Prometheus Flavor = iota
+
+	// Influx queries the points written by the InfluxDB sink with InfluxQL.
+	Influx
+)
+
+// Dashboard configures the Grafana dashboard written by Write. To keep a dashboard in
+// sync with the configuration of a Collector, write it for the fields returned by the
+// Collector's Describe method.
+type Dashboard struct {
+	Title string
+
+	// Flavor is the backend queried. Defaults to Prometheus.
+	Flavor Flavor
+
+	// Namespace is the namespace the Prometheus sink was configured with.
+	Namespace string
+
+	// Measurement is the measurement the InfluxDB sink writes to. Defaults to
+	// serializer.DefaultMeasurement.
+	Measurement string
+
+	// Datasource is the UID of the Grafana data source queried. Defaults to the
+	// "${datasource}" template variable, which the dashboard defines.
+	Datasource string
+}
+
+// InfluxQuery returns an InfluxQL query graphing the field: the per-second rate of
+// counters and the mean value of gauges, per instance.
+func InfluxQuery(measurement string, f collector.FieldInfo) string {
+	if measurement == "" {
+		measurement = serializer.DefaultMeasurement
+	}
+	sel := `mean("` + f.Name + `")`
+	if f.Type == collector.Counter {
+		sel = "non_negative_derivative(" + sel + ", 1s)"
+	}
+	return "SELECT " + sel + ` FROM "` + measurement + `" WHERE $timeFilter GROUP BY time($__interval), "instance" fill(null)`
+}
+
+// dsType returns the type of the data source queried.
+func (d Dashboard) dsType() string {
+	if d.Flavor == Influx {
+		return "influxdb"
+	}
+	return "prometheus"
+}
+
+// target returns the query of the panel graphing f.
+func (d Dashboard) target(f collector.FieldInfo) map[string]interface{} {
+	if d.Flavor == Influx {
+		return map[string]interface{}{"refId": "A", "query": InfluxQuery(d.Measurement, f), "rawQuery": true, "alias": "$tag_instance"}
+	}
+	return map[string]interface{}{"refId": "A", "expr": PrometheusQuery(d.Namespace, f), "legendFormat": "{{instance}}"}
+}
+
+// grafanaUnits maps the units of FieldInfo to Grafana's.
+var grafanaUnits = map[string]string{
+	"bytes":                  "bytes",
+	"bytes_per_second":       "Bps",
+	"per_second":             "ops",
+	"nanoseconds":            "ns",
+	"nanoseconds_per_second": "ns",
+	"seconds":                "s",
+	"percent":                "percent",
+	"ratio":                  "percentunit",
+	"unix_nanoseconds":       "dateTimeAsIso",
+}
+
+type grafanaPanel struct {
+	ID          int                      `json:"id"`
+	Type        string                   `json:"type"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description,omitempty"`
+	GridPos     map[string]int           `json:"gridPos"`
+	Collapsed   *bool                    `json:"collapsed,omitempty"`
+	Datasource  map[string]string        `json:"datasource,omitempty"`
+	FieldConfig map[string]interface{}   `json:"fieldConfig,omitempty"`
+	Targets     []map[string]interface{} `json:"targets,omitempty"`
+}
+
+// Write writes a Grafana dashboard graphing every field in fields, with a row for each
+// group of fields such as "mem.gc" or "proc.cgroup", in the order the groups first appear.
+func (d Dashboard) Write(w io.Writer, fields []collector.FieldInfo) error {
+	ds := d.Datasource
+	if ds == "" {
+		ds = "${datasource}"
+	}
+	title := d.Title
+	if title == "" {
+		title = "Go runtime"
+	}
+
+	var groups []string
+	byGroup := make(map[string][]collector.FieldInfo)
+	for _, f := range fields {
+		g := group(f.Name)
+		if _, ok := byGroup[g]; !ok {
+			groups = append(groups, g)
+		}
+		byGroup[g] = append(byGroup[g], f)
+	}
+
+	var panels []grafanaPanel
+	y := 0
+	for _, g := range groups {
+		collapsed := false
+		panels = append(panels, grafanaPanel{
+			ID: len(panels) + 1, Type: "row", Title: g, Collapsed: &collapsed,
+			GridPos: map[string]int{"x": 0, "y": y, "w": 24, "h": 1},
+		})
+		y++
+		for i, f := range byGroup[g] {
+			if i > 0 && i%3 == 0 {
+				y += 8
+			}
+			unit := grafanaUnits[f.Unit]
+			if unit == "" {
+				unit = "none"
+			}
+			panels = append(panels, grafanaPanel{
+				ID:          len(panels) + 1,
+				Type:        "timeseries",
+				Title:       f.Name,
+				Description: f.Help,
+				GridPos:     map[string]int{"x": i % 3 * 8, "y": y, "w": 8, "h": 8},
+				Datasource:  map[string]string{"type": d.dsType(), "uid": ds},
+				FieldConfig: map[string]interface{}{"defaults": map[string]string{"unit": unit}},
+				Targets:     []map[string]interface{}{d.target(f)},
+			})
+		}
+		y += 8
+	}
+
+	dashboard := map[string]interface{}{
+		"title":         title,
+		"schemaVersion": 39,
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"refresh":       "30s",
+		"tags":          []string{"go", "runtime"},
+		"templating": map[string]interface{}{"list": []map[string]interface{}{{
+			"name":  "datasource",
+			"type":  "datasource",
+			"query": d.dsType(),
+		}}},
+		"panels": panels,
+	}
+	b, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// group returns the group a field is shown in: its first two name segments, or the first
+// alone for fields with two segments such as "cpu.goroutines".
+func group(name string) string {
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) < 3 {
+		return parts[0]
+	}
+	return parts[0] + "." + parts[1]
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestWriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteJSON(buf, collector.Describe()); err != nil {
+		t.Fatal(err)
+	}
+	var fields []collector.FieldInfo
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) == 0 || fields[0].Name != "cpu.cgo_calls" || fields[0].Type != collector.Counter {
+		t.Errorf("unexpected schema %v", fields[:1])
+	}
+}
+
+func TestWritePrometheusRules(t *testing.T) {
+	buf := &bytes.Buffer{}
+	WritePrometheusRules(buf, "go", collector.Describe())
+	rules := buf.String()
+
+	for _, exp := range []string{
+		"  - name: go_runtime_metrics\n",
+		"      - record: go_mem_gc_count:rate5m\n        expr: rate(go_mem_gc_count_total[5m])\n",
+		"      - alert: GoroutineCountHigh\n        expr: go_cpu_goroutines > 10000\n",
+	} {
+		if !strings.Contains(rules, exp) {
+			t.Errorf("expected rules to contain %q, got:\n%s", exp, rules)
+		}
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	buf := &bytes.Buffer{}
+	fields := []collector.FieldInfo{
+		{Name: "cpu.goroutines", Type: collector.Gauge},
+		{Name: "mem.alloc", Type: collector.Gauge, Unit: "bytes"},
+		{Name: "mem.gc.count", Type: collector.Counter},
+		{Name: "mem.lookups", Type: collector.Counter},
+	}
+	if err := (Dashboard{Namespace: "go"}).Write(buf, fields); err != nil {
+		t.Fatal(err)
+	}
+
+	var dashboard struct {
+		Panels []struct {
+			Type    string
+			Title   string
+			GridPos map[string]int
+			Targets []map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, p := range dashboard.Panels {
+		titles = append(titles, p.Title)
+	}
+	if got := strings.Join(titles, ","); got != "cpu,cpu.goroutines,mem,mem.alloc,mem.lookups,mem.gc,mem.gc.count" {
+		t.Fatalf("unexpected panels %s", got)
+	}
+	if p := dashboard.Panels[4]; p.Targets[0]["expr"] != "rate(go_mem_lookups_total[5m])" || p.GridPos["x"] != 8 {
+		t.Errorf("unexpected panel %+v", p)
+	}
+}
+
+func TestInfluxDashboard(t *testing.T) {
+	buf := &bytes.Buffer{}
+	fields := []collector.FieldInfo{{Name: "mem.gc.count", Type: collector.Counter}}
+	if err := (Dashboard{Flavor: Influx, Measurement: "app"}).Write(buf, fields); err != nil {
+		t.Fatal(err)
+	}
+	exp := `SELECT non_negative_derivative(mean(\"mem.gc.count\"), 1s) FROM \"app\" WHERE $timeFilter`
+	if !strings.Contains(buf.String(), exp) || !strings.Contains(buf.String(), `"type": "influxdb"`) {
+		t.Errorf("expected an InfluxQL panel, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,73 @@
+package serializer
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// CBOR encodes Fields as a CBOR (RFC 8949) map keyed by field name. Integer fields use the
+// shortest head encoding, floating point fields are encoded as float64.
+type CBOR struct {
+	// Uint64 encodes the fields read from runtime.MemStats with full uint64 precision, as
+	// given by collector.Fields.ToMapU64.
+	Uint64 bool
+}
+
+// ContentType implements Encoder.
+func (CBOR) ContentType() string {
+	return "application/cbor"
+}
+
+// Encode implements Encoder.
+func (e CBOR) Encode(w io.Writer, f collector.Fields) error {
+	m := fieldMap(&f, e.Uint64)
+	keys := sortedKeys(m)
+
+	b := make([]byte, 0, 32*len(keys))
+	b = appendCBORHead(b, cborMap, uint64(len(keys)))
+	for _, k := range keys {
+		b = appendCBORHead(b, cborText, uint64(len(k)))
+		b = append(b, k...)
+		switch v := m[k].(type) {
+		case int64:
+			if v >= 0 {
+				b = appendCBORHead(b, cborUint, uint64(v))
+			} else {
+				b = appendCBORHead(b, cborNegInt, uint64(-1-v))
+			}
+		case uint64:
+			b = appendCBORHead(b, cborUint, v)
+		case float64:
+			b = append(b, cborFloat64)
+			b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+		}
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// CBOR major types, shifted into the high bits of the initial byte.
+const (
+	cborUint    = 0 << 5
+	cborNegInt  = 1 << 5
+	cborText    = 3 << 5
+	cborMap     = 5 << 5
+	cborFloat64 = 7<<5 | 27
+)
+
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
@@ -0,0 +1,51 @@
+package serializer
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// CSV encodes Fields as a row of comma separated values, one column per field in field
+// order. Samples with different Extra fields have different columns, so set Header when
+// they may vary.
+type CSV struct {
+	// Header writes a row of field names before the values.
+	Header bool
+
+	// Uint64 encodes the fields read from runtime.MemStats with full uint64 precision, as
+	// given by collector.Fields.ToMapU64.
+	Uint64 bool
+}
+
+// ContentType implements Encoder.
+func (e CSV) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// Encode implements Encoder.
+func (e CSV) Encode(w io.Writer, f collector.Fields) error {
+	m := fieldMap(&f, e.Uint64)
+	keys := sortedKeys(m)
+
+	cw := csv.NewWriter(w)
+	if e.Header {
+		cw.Write(keys)
+	}
+	row := make([]string, len(keys))
+	for i, k := range keys {
+		switch v := m[k].(type) {
+		case int64:
+			row[i] = strconv.FormatInt(v, 10)
+		case uint64:
+			row[i] = strconv.FormatUint(v, 10)
+		case float64:
+			row[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	cw.Write(row)
+	cw.Flush()
+	return cw.Error()
+}
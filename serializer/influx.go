@@ -0,0 +1,128 @@
+package serializer
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// DefaultMeasurement is the measurement name used by InfluxLine when none is configured.
+const DefaultMeasurement = "go_runtime_metrics"
+
+// InfluxLine encodes Fields as a single point in InfluxDB line protocol.
+type InfluxLine struct {
+	// Measurement is the measurement name of the point. Defaults to DefaultMeasurement.
+	Measurement string
+
+	// Tags are added to the point's tag set, along with the Tags of the sample, which take
+	// precedence.
+	Tags map[string]string
+
+	// Uint64 encodes the fields read from runtime.MemStats as unsigned integers with full
+	// precision, as given by collector.Fields.ToMapU64. InfluxDB 1.x only accepts them
+	// with unsigned integer support enabled.
+	Uint64 bool
+}
+
+// ContentType implements Encoder.
+func (e InfluxLine) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// Encode implements Encoder. The point is timestamped with the time the sample was taken.
+// NaN and infinite values, which line protocol cannot represent, are left out, and nothing
+// is written when no field remains.
+func (e InfluxLine) Encode(w io.Writer, f collector.Fields) error {
+	m := fieldMap(&f, e.Uint64)
+	for k, v := range m {
+		if v, ok := v.(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			delete(m, k)
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+
+	measurement := e.Measurement
+	if measurement == "" {
+		measurement = DefaultMeasurement
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(measurementEscaper.Replace(measurement))
+	tags := mergeTags(e.Tags, f.Tags)
+	for _, k := range sortedTags(tags) {
+		if tags[k] == "" {
+			continue
+		}
+		bw.WriteByte(',')
+		bw.WriteString(keyEscaper.Replace(k))
+		bw.WriteByte('=')
+		bw.WriteString(keyEscaper.Replace(tags[k]))
+	}
+
+	for i, k := range sortedKeys(m) {
+		if i == 0 {
+			bw.WriteByte(' ')
+		} else {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(keyEscaper.Replace(k))
+		bw.WriteByte('=')
+		switch v := m[k].(type) {
+		case int64:
+			bw.WriteString(strconv.FormatInt(v, 10))
+			bw.WriteByte('i')
+		case uint64:
+			bw.WriteString(strconv.FormatUint(v, 10))
+			bw.WriteByte('u')
+		case float64:
+			bw.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+
+	bw.WriteByte(' ')
+	bw.WriteString(strconv.FormatInt(f.Time().UnixNano(), 10))
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// mergeTags returns the union of two tag sets, where sample tags take precedence over the
+// encoder's.
+func mergeTags(encoder, sample map[string]string) map[string]string {
+	if len(encoder) == 0 {
+		return sample
+	}
+	if len(sample) == 0 {
+		return encoder
+	}
+	tags := make(map[string]string, len(encoder)+len(sample))
+	for k, v := range encoder {
+		tags[k] = v
+	}
+	for k, v := range sample {
+		tags[k] = v
+	}
+	return tags
+}
+
+// sortedTags returns the keys of tags in sorted order, which InfluxDB recommends for
+// performance.
+func sortedTags(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
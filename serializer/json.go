@@ -0,0 +1,39 @@
+package serializer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// JSON encodes Fields as a flat JSON object keyed by field name.
+type JSON struct {
+	// Newline terminates every object with a newline, producing newline delimited JSON.
+	Newline bool
+
+	// Uint64 encodes the fields read from runtime.MemStats with full uint64 precision, as
+	// given by collector.Fields.ToMapU64.
+	Uint64 bool
+}
+
+// ContentType implements Encoder.
+func (e JSON) ContentType() string {
+	if e.Newline {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// Encode implements Encoder.
+func (e JSON) Encode(w io.Writer, f collector.Fields) error {
+	b, err := json.Marshal(fieldMap(&f, e.Uint64))
+	if err != nil {
+		return err
+	}
+	if e.Newline {
+		b = append(b, '\n')
+	}
+	_, err = w.Write(b)
+	return err
+}
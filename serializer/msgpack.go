@@ -0,0 +1,89 @@
+package serializer
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// MsgPack encodes Fields as a MessagePack map keyed by field name. Integer fields use the
+// most compact integer encoding, floating point fields are encoded as float64.
+type MsgPack struct {
+	// Uint64 encodes the fields read from runtime.MemStats with full uint64 precision, as
+	// given by collector.Fields.ToMapU64.
+	Uint64 bool
+}
+
+// ContentType implements Encoder.
+func (MsgPack) ContentType() string {
+	return "application/msgpack"
+}
+
+// Encode implements Encoder.
+func (e MsgPack) Encode(w io.Writer, f collector.Fields) error {
+	m := fieldMap(&f, e.Uint64)
+	keys := sortedKeys(m)
+
+	b := make([]byte, 0, 32*len(keys))
+	b = appendMsgPackMapHeader(b, len(keys))
+	for _, k := range keys {
+		b = appendMsgPackString(b, k)
+		switch v := m[k].(type) {
+		case int64:
+			b = appendMsgPackInt(b, v)
+		case uint64:
+			if v <= math.MaxInt64 {
+				b = appendMsgPackInt(b, int64(v))
+			} else {
+				b = binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+			}
+		case float64:
+			b = append(b, 0xcb)
+			b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+		}
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func appendMsgPackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+}
+
+func appendMsgPackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgPackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v < 128:
+		return append(b, byte(v))
+	case v < 0 && v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+}
@@ -0,0 +1,93 @@
+package serializer
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// OpenMetrics encodes Fields in the OpenMetrics text exposition format, with every field
+// exposed as a gauge.
+type OpenMetrics struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+
+	// Uint64 encodes the fields read from runtime.MemStats with full uint64 precision, as
+	// given by collector.Fields.ToMapU64.
+	Uint64 bool
+}
+
+// ContentType implements Encoder.
+func (e OpenMetrics) ContentType() string {
+	return "application/openmetrics-text; version=1.0.0; charset=utf-8"
+}
+
+// Encode implements Encoder. The Tags of the sample are exposed as labels.
+func (e OpenMetrics) Encode(w io.Writer, f collector.Fields) error {
+	bw := bufio.NewWriter(w)
+	labels := Labels(f.Tags)
+	m := fieldMap(&f, e.Uint64)
+	for _, k := range sortedKeys(m) {
+		name := MetricName(e.Namespace, k)
+		bw.WriteString("# TYPE " + name + " gauge\n")
+		bw.WriteString(name + labels + " ")
+		switch v := m[k].(type) {
+		case int64:
+			bw.WriteString(strconv.FormatInt(v, 10))
+		case uint64:
+			bw.WriteString(strconv.FormatUint(v, 10))
+		case float64:
+			bw.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		bw.WriteByte('\n')
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// MetricName converts a field name such as "mem.heap.alloc" into a metric name that is
+// valid in Prometheus and OpenMetrics, optionally prefixed with namespace.
+func MetricName(namespace, field string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, field)
+	if namespace != "" {
+		name = namespace + "_" + name
+	}
+	return name
+}
+
+// Labels formats tags as a Prometheus and OpenMetrics label set such as
+// `{host="a",region="eu"}`, sorted by name. Names are sanitized like metric names and
+// values escaped. It returns an empty string for no tags.
+func Labels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range sortedTags(tags) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name := strings.Replace(MetricName("", k), ":", "_", -1)
+		if name != "" && name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(tags[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
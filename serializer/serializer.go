@@ -0,0 +1,95 @@
+// Package serializer provides a registry of wire formats for collector.Fields. Sinks and
+// endpoints select an encoder by name, so third-party formats can be added with Register
+// without modifying this module.
+//
+// The built-in encoders are "json", "ndjson", "csv", "influx-line", "openmetrics",
+// "msgpack" and "cbor".
+//
+// Every built-in encoder writes fields in field order: sorted by name, comparing names
+// byte by byte as sort.Strings does, with Extra fields merged in among the others. Tags
+// and labels are sorted by name the same way. Encoding the same sample twice yields the
+// same bytes, so the output is suitable for diffs and golden tests, and the order is kept
+// across releases. Third-party encoders should follow it.
+package serializer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// Encoder writes Fields in a particular wire format.
+type Encoder interface {
+	// ContentType returns the media type of the encoded output.
+	ContentType() string
+
+	// Encode writes a single sample to w.
+	Encode(w io.Writer, f collector.Fields) error
+}
+
+var (
+	mu       sync.RWMutex
+	encoders = map[string]Encoder{}
+)
+
+// Register makes an encoder available under the given name, replacing any encoder
+// previously registered under it.
+func Register(name string, e Encoder) {
+	mu.Lock()
+	defer mu.Unlock()
+	encoders[name] = e
+}
+
+// Lookup returns the encoder registered under name.
+func Lookup(name string) (Encoder, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := encoders[name]
+	if !ok {
+		return nil, fmt.Errorf("serializer: unknown encoder %q", name)
+	}
+	return e, nil
+}
+
+// Names returns the names of all registered encoders in sorted order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("json", JSON{})
+	Register("ndjson", JSON{Newline: true})
+	Register("csv", CSV{Header: true})
+	Register("influx-line", InfluxLine{})
+	Register("openmetrics", OpenMetrics{})
+	Register("msgpack", MsgPack{})
+	Register("cbor", CBOR{})
+}
+
+// fieldMap returns the fields of f keyed by name, as given by ToMapU64 if u64 is set.
+func fieldMap(f *collector.Fields, u64 bool) map[string]interface{} {
+	if u64 {
+		return f.ToMapU64()
+	}
+	return f.ToMap()
+}
+
+// sortedKeys returns the keys of m in field order, which every encoder emits fields in.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,226 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestBuiltins(t *testing.T) {
+	f := collector.Fields{NumGoroutine: 3, GCCPUFraction: 0.5}
+
+	for _, name := range []string{"json", "ndjson", "csv", "influx-line", "openmetrics", "msgpack", "cbor"} {
+		e, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := &bytes.Buffer{}
+		if err := e.Encode(buf, f); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if buf.Len() == 0 {
+			t.Errorf("%s: empty output", name)
+		}
+	}
+
+	if _, err := Lookup("nope"); err == nil {
+		t.Error("expected an error for an unknown encoder")
+	}
+}
+
+func TestFieldOrder(t *testing.T) {
+	// Extra fields sort among the others rather than after them.
+	f := collector.Fields{
+		NumGoroutine: 3,
+		HeapAlloc:    1024,
+		Extra:        map[string]float64{"a.first": 1, "mem.heap.alloc_x": 2, "zz.last": 3},
+		Tags:         map[string]string{"zone": "b", "app": "a"},
+		Timestamp:    1,
+	}
+	order := []string{"a.first", "cpu.cgo_calls", "cpu.goroutines", "mem.heap.alloc", "mem.heap.alloc_x", "zz.last"}
+
+	for _, name := range Names() {
+		e, _ := Lookup(name)
+		buf := &bytes.Buffer{}
+		if err := e.Encode(buf, f); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		out := buf.Bytes()
+		for i := 0; i < 10; i++ {
+			again := &bytes.Buffer{}
+			e.Encode(again, f)
+			if !bytes.Equal(again.Bytes(), out) {
+				t.Fatalf("%s: encoding is not deterministic", name)
+			}
+		}
+		if a, z := bytes.Index(out, []byte("app")), bytes.Index(out, []byte("zone")); a > z {
+			t.Errorf("%s: tags are not sorted", name)
+		}
+
+		last := -1
+		for _, field := range order {
+			key := field
+			if name == "openmetrics" {
+				key = MetricName("", field)
+			}
+			i := indexField(out, key)
+			if i <= last {
+				t.Errorf("%s: %s at %d is not after the previous field at %d", name, field, i, last)
+			}
+			last = i
+		}
+	}
+}
+
+// indexField returns the index of the first occurrence of key in b that is a whole name,
+// not the prefix of a longer one such as mem.heap.alloc of mem.heap.alloc_x.
+func indexField(b []byte, key string) int {
+	for off := 0; ; {
+		i := bytes.Index(b[off:], []byte(key))
+		if i < 0 {
+			return -1
+		}
+		end := off + i + len(key)
+		if end == len(b) || !strings.ContainsRune("abcdefghijklmnopqrstuvwxyz0123456789._", rune(b[end])) {
+			return off + i
+		}
+		off = end
+	}
+}
+
+func TestCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	CSV{Header: true}.Encode(buf, collector.Fields{NumGoroutine: 3, GCCPUFraction: 0.5})
+	lines := strings.Split(buf.String(), "\n")
+
+	if !strings.HasPrefix(lines[0], "cpu.cgo_calls,cpu.goroutines,") || !strings.HasPrefix(lines[1], "0,3,") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+	if len(strings.Split(lines[0], ",")) != len(strings.Split(lines[1], ",")) {
+		t.Errorf("header and row have different numbers of columns")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	JSON{}.Encode(buf, collector.Fields{NumGoroutine: 3})
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m["cpu.goroutines"]; v != float64(3) {
+		t.Errorf("expected cpu.goroutines=3, got %v", v)
+	}
+}
+
+func TestInfluxLine(t *testing.T) {
+	buf := &bytes.Buffer{}
+	InfluxLine{Measurement: "go runtime"}.Encode(buf, collector.Fields{NumGoroutine: 3, GCCPUFraction: 0.5, Timestamp: 1e18})
+	line := buf.String()
+
+	if !strings.HasPrefix(line, `go\ runtime cpu.cgo_calls=0i,cpu.goroutines=3i,`) {
+		t.Errorf("unexpected line: %s", line)
+	}
+	if !strings.Contains(line, "mem.gc.cpu_fraction=0.5,") {
+		t.Errorf("expected float field, got: %s", line)
+	}
+	if !strings.HasSuffix(line, " 1000000000000000000\n") {
+		t.Errorf("expected the sample timestamp, got: %s", line)
+	}
+}
+
+func TestInfluxLineNonFinite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	f := collector.Fields{NumGoroutine: 3, GCCPUFraction: math.NaN(), Extra: map[string]float64{"app.ratio": math.Inf(1), "app.low": math.Inf(-1)}}
+	InfluxLine{}.Encode(buf, f)
+	line := buf.String()
+	if strings.Contains(line, "mem.gc.cpu_fraction") || strings.Contains(line, "app.") || strings.Contains(strings.ToLower(line), "inf") {
+		t.Errorf("expected the non-finite values to be left out, got: %s", line)
+	}
+	if !strings.Contains(line, "cpu.goroutines=3i") {
+		t.Errorf("expected the finite values, got: %s", line)
+	}
+}
+
+func TestInfluxLineNoFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := collector.New(nil, collector.WithFields("mem.gc.cpu_fraction"))
+	f := c.OneOff()
+	f.GCCPUFraction = math.NaN()
+	if err := (InfluxLine{}).Encode(buf, f); err != nil || buf.Len() != 0 {
+		t.Errorf("expected no line without finite fields, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestUint64(t *testing.T) {
+	f := collector.New(nil).OneOff()
+	buf := &bytes.Buffer{}
+	InfluxLine{Uint64: true}.Encode(buf, f)
+	if exp := fmt.Sprintf(",mem.malloc=%du,", f.Mallocs); !strings.Contains(buf.String(), exp) {
+		t.Errorf("expected %s in %s", exp, buf.String())
+	}
+
+	buf.Reset()
+	CBOR{Uint64: true}.Encode(buf, f)
+	plain := &bytes.Buffer{}
+	CBOR{}.Encode(plain, f)
+	if !bytes.Equal(buf.Bytes(), plain.Bytes()) {
+		t.Error("expected the same encoding of counters below math.MaxInt64")
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	if name := MetricName("go", "mem.heap.alloc"); name != "go_mem_heap_alloc" {
+		t.Errorf("unexpected metric name %q", name)
+	}
+}
+
+func TestMsgPack(t *testing.T) {
+	buf := &bytes.Buffer{}
+	MsgPack{}.Encode(buf, collector.Fields{NumGoroutine: 300})
+	b := buf.Bytes()
+
+	// map16 header followed by the first key, "cpu.cgo_calls" = 0
+	if b[0] != 0xde || b[3] != 0xa0|13 || string(b[4:17]) != "cpu.cgo_calls" || b[17] != 0 {
+		t.Fatalf("unexpected encoding: % x", b[:18])
+	}
+	// "cpu.goroutines" = 300 as int16
+	if i := bytes.Index(b, []byte("cpu.goroutines")); i < 0 || !bytes.Equal(b[i+14:i+17], []byte{0xd1, 0x01, 0x2c}) {
+		t.Errorf("unexpected encoding of cpu.goroutines: % x", b)
+	}
+}
+
+func TestCBOR(t *testing.T) {
+	buf := &bytes.Buffer{}
+	CBOR{}.Encode(buf, collector.Fields{NumGoroutine: 300, HeapGrowth: -2})
+	b := buf.Bytes()
+
+	// map with a one byte length, followed by the first key, "cpu.cgo_calls" = 0
+	if b[0] != 0xb8 || b[2] != 0x60|13 || string(b[3:16]) != "cpu.cgo_calls" || b[16] != 0 {
+		t.Fatalf("unexpected encoding: % x", b[:17])
+	}
+	if i := bytes.Index(b, []byte("cpu.goroutines")); i < 0 || !bytes.Equal(b[i+14:i+17], []byte{0x19, 0x01, 0x2c}) {
+		t.Errorf("unexpected encoding of cpu.goroutines: % x", b)
+	}
+	if i := bytes.Index(b, []byte("mem.heap.growth")); i < 0 || b[i+15] != 0x21 {
+		t.Errorf("unexpected encoding of mem.heap.growth: % x", b)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	if got := Labels(map[string]string{"region": "eu", "host.name": `a"b`, "1x": "y"}); got != `{_1x="y",host_name="a\"b",region="eu"}` {
+		t.Errorf("unexpected labels %s", got)
+	}
+
+	buf := &bytes.Buffer{}
+	OpenMetrics{}.Encode(buf, collector.Fields{Tags: map[string]string{"host": "a"}})
+	if !strings.Contains(buf.String(), "\ncpu_goroutines{host=\"a\"} 0\n") {
+		t.Errorf("expected labels on every metric, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,167 @@
+// Package sinks holds what is shared by the sinks in its subdirectories, which each
+// write samples to a different backend.
+package sinks
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// BatchSink is implemented by sinks that write several samples at once, such as in a
+// single request. Batcher hands them its batches whole.
+type BatchSink interface {
+	collector.Sink
+	EmitBatch(fs []collector.Fields) error
+}
+
+// BatchStats counts what a Batcher did with the samples it was given.
+type BatchStats struct {
+	// Flushes is the number of batches written, and Flushed the samples they held.
+	Flushes int64
+	Flushed int64
+
+	// Dropped is the number of samples discarded because writing their batch failed.
+	Dropped int64
+}
+
+// Batcher buffers the samples emitted to a Sink and writes them in batches, so that a
+// short collection interval does not translate into a network write per collection.
+type Batcher struct {
+	// ErrorFunc receives the errors of the flushes made every flush interval, which have no
+	// caller to return them to. Defaults to ignoring them.
+	ErrorFunc func(error)
+
+	sink      collector.Sink
+	maxPoints int
+
+	// flushMu serializes the flushes, which write without holding mu so that samples are
+	// buffered meanwhile.
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	pending []collector.Fields
+	stats   BatchStats
+	rate    float64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ collector.BufferedSink = (*Batcher)(nil)
+
+// Batch returns a Batcher writing to s once maxPoints samples are buffered, and at least
+// every flushInterval if positive. Sinks implementing BatchSink receive each batch in one
+// call of EmitBatch, others one Emit per sample. A batch that fails to be written is
+// dropped, the sink being left to retry as configured.
+func Batch(s collector.Sink, maxPoints int, flushInterval time.Duration) *Batcher {
+	if maxPoints <= 0 {
+		maxPoints = 1
+	}
+	b := &Batcher{sink: s, maxPoints: maxPoints}
+	if flushInterval > 0 {
+		b.stop, b.done = make(chan struct{}), make(chan struct{})
+		go b.run(flushInterval)
+	}
+	return b
+}
+
+func (b *Batcher) run(interval time.Duration) {
+	defer close(b.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := b.Flush(); err != nil && b.ErrorFunc != nil {
+				b.ErrorFunc(err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Emit implements collector.Sink, buffering f. It returns the error of the flush it
+// triggers once maxPoints samples are buffered.
+func (b *Batcher) Emit(f collector.Fields) error {
+	b.mu.Lock()
+	b.pending = append(b.pending, f)
+	full := len(b.pending) >= b.maxPoints
+	b.mu.Unlock()
+	if !full {
+		return nil
+	}
+	return b.Flush()
+}
+
+// Flush writes the buffered samples.
+func (b *Batcher) Flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	var err error
+	if bs, ok := b.sink.(BatchSink); ok {
+		err = bs.EmitBatch(batch)
+	} else {
+		for _, f := range batch {
+			if err = b.sink.Emit(f); err != nil {
+				break
+			}
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.stats.Dropped += int64(len(batch))
+		return err
+	}
+	b.stats.Flushes++
+	b.stats.Flushed += int64(len(batch))
+	if d := time.Since(start).Seconds(); d > 0 {
+		b.rate = float64(len(batch)) / d
+	}
+	return nil
+}
+
+// Stats returns what the Batcher did with the samples so far.
+func (b *Batcher) Stats() BatchStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Buffered implements collector.BufferedSink, returning the number of samples waiting for
+// the next flush and the rate in samples per second the last batch was written at.
+func (b *Batcher) Buffered() (depth int, drainRate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending), b.rate
+}
+
+// Close stops the periodic flushes, writes the buffered samples and closes the sink if it
+// implements io.Closer. The Batcher must not be used afterwards.
+func (b *Batcher) Close() error {
+	if b.stop != nil {
+		close(b.stop)
+		<-b.done
+	}
+	err := b.Flush()
+	if c, ok := b.sink.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package sinks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+type batchSink struct {
+	batches [][]collector.Fields
+	err     error
+	closed  bool
+}
+
+func (s *batchSink) Emit(f collector.Fields) error { return s.EmitBatch([]collector.Fields{f}) }
+
+func (s *batchSink) EmitBatch(fs []collector.Fields) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, fs)
+	return nil
+}
+
+func (s *batchSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	s := &batchSink{}
+	b := Batch(s, 3, 0)
+	for i := 0; i < 7; i++ {
+		if err := b.Emit(collector.Fields{NumGoroutine: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.batches) != 2 || len(s.batches[1]) != 3 || s.batches[1][2].NumGoroutine != 5 {
+		t.Fatalf("expected two full batches, got %v", s.batches)
+	}
+	if depth, _ := b.Buffered(); depth != 1 {
+		t.Errorf("expected one buffered sample, got %d", depth)
+	}
+
+	s.err = errors.New("unreachable")
+	b.Emit(collector.Fields{})
+	if err := b.Emit(collector.Fields{}); err != s.err {
+		t.Errorf("expected the error of the flush, got %v", err)
+	}
+	s.err = nil
+	b.Emit(collector.Fields{})
+	if err := b.Close(); err != nil || !s.closed || len(s.batches) != 3 {
+		t.Errorf("expected Close to flush and close the sink, got %v and %d batches", err, len(s.batches))
+	}
+	if st := b.Stats(); st != (BatchStats{Flushes: 3, Flushed: 7, Dropped: 3}) {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestBatchInterval(t *testing.T) {
+	flushed := make(chan collector.Fields, 10)
+	b := Batch(collector.SinkFunc(func(f collector.Fields) error { flushed <- f; return nil }), 100, 10*time.Millisecond)
+	defer b.Close()
+	b.Emit(collector.Fields{NumGoroutine: 1})
+	select {
+	case f := <-flushed:
+		if f.NumGoroutine != 1 {
+			t.Errorf("unexpected sample %+v", f)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the sample to be flushed after the interval")
+	}
+}
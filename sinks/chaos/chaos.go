@@ -0,0 +1,136 @@
+// Package chaos injects failures into the telemetry path, for tests and staging
+// environments that need to verify how sinks behave when their backend misbehaves: that
+// retries recover from transient errors, that buffered samples survive a disconnect, and
+// that a slow backend does not stall collection. Sink wraps any collector.Sink, Transport
+// any HTTP sink that takes a Client, and Proxy any sink that connects over TCP.
+package chaos
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// ErrInjected is returned for the failures injected by Sink and Transport.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Faults describes what is injected, each operation being delayed and failed
+// independently.
+type Faults struct {
+	// Latency delays every operation, plus a random part of up to Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// ErrorRate is the probability with which an operation fails, from 0 to 1.
+	ErrorRate float64
+
+	// Rand is the source of randomness, such as rand.New(rand.NewSource(1)) for
+	// reproducible tests. Defaults to the global source of math/rand.
+	Rand *rand.Rand
+}
+
+// randMu guards the Rand of every Faults, which may be shared.
+var randMu sync.Mutex
+
+func (f *Faults) float64() float64 {
+	if f.Rand == nil {
+		return rand.Float64()
+	}
+	randMu.Lock()
+	defer randMu.Unlock()
+	return f.Rand.Float64()
+}
+
+// chance reports true with probability p.
+func (f *Faults) chance(p float64) bool {
+	return p > 0 && f.float64() < p
+}
+
+func (f *Faults) delay() {
+	d := f.Latency
+	if f.Jitter > 0 {
+		d += time.Duration(f.float64() * float64(f.Jitter))
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Sink wraps a collector.Sink, delaying and failing its emissions. Failed emissions are
+// not passed to the wrapped Sink.
+type Sink struct {
+	Faults
+	Sink collector.Sink
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// Wrap returns a Sink injecting faults into s.
+func Wrap(s collector.Sink, faults Faults) *Sink {
+	return &Sink{Faults: faults, Sink: s}
+}
+
+// Emit implements collector.Sink.
+func (s *Sink) Emit(f collector.Fields) error {
+	s.delay()
+	if s.chance(s.ErrorRate) {
+		return ErrInjected
+	}
+	return s.Sink.Emit(f)
+}
+
+// Transport wraps an http.RoundTripper, delaying and failing requests, for sinks that take
+// an *http.Client such as the influx sink.
+type Transport struct {
+	Faults
+
+	// StatusCode, when set, makes failed requests return a response with this status, such
+	// as 503 or 429, instead of failing like a network error with ErrInjected.
+	StatusCode int
+
+	// Base sends the requests that are not failed. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.delay()
+	if t.chance(t.ErrorRate) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if t.StatusCode == 0 {
+			return nil, ErrInjected
+		}
+		msg := ErrInjected.Error()
+		return &http.Response{
+			Status:        http.StatusText(t.StatusCode),
+			StatusCode:    t.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain"}},
+			Body:          io.NopCloser(strings.NewReader(msg)),
+			ContentLength: int64(len(msg)),
+			Request:       req,
+		}, nil
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// Client returns an *http.Client sending its requests through t.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
@@ -0,0 +1,130 @@
+package chaos
+
+import (
+	"bufio"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/graphite"
+	"github.com/tevjef/go-runtime-metrics/sinks/influx"
+)
+
+func TestSink(t *testing.T) {
+	emitted := 0
+	s := Wrap(collector.SinkFunc(func(collector.Fields) error { emitted++; return nil }), Faults{ErrorRate: 0.5, Rand: rand.New(rand.NewSource(1))})
+	failed := 0
+	for i := 0; i < 100; i++ {
+		if err := s.Emit(collector.Fields{}); errors.Is(err, ErrInjected) {
+			failed++
+		}
+	}
+	if failed+emitted != 100 || failed < 30 || failed > 70 {
+		t.Errorf("expected about half of the emissions to fail, got %d failed and %d emitted", failed, emitted)
+	}
+
+	s = Wrap(collector.SinkFunc(func(collector.Fields) error { return nil }), Faults{Latency: 20 * time.Millisecond})
+	if start := time.Now(); s.Emit(collector.Fields{}) != nil || time.Since(start) < 20*time.Millisecond {
+		t.Error("expected a delayed emission")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	writes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writes++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	tr := &Transport{Faults: Faults{ErrorRate: 0.5, Rand: rand.New(rand.NewSource(1))}, StatusCode: http.StatusServiceUnavailable}
+	s := influx.New(srv.URL)
+	s.Database, s.MaxRetries, s.RetryBackoff, s.Client = "db", 8, time.Millisecond, tr.Client()
+	for i := 0; i < 5; i++ {
+		if err := s.Emit(collector.Fields{NumGoroutine: 1}); err != nil {
+			t.Fatalf("expected the retries to recover, got %v", err)
+		}
+	}
+	if writes != 5 {
+		t.Errorf("expected 5 writes, got %d", writes)
+	}
+
+	tr.ErrorRate, tr.StatusCode = 1, 0
+	if err := s.Emit(collector.Fields{}); !errors.Is(err, ErrInjected) {
+		t.Errorf("expected an injected network error, got %v", err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 1000)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					lines <- sc.Text()
+				}
+			}()
+		}
+	}()
+
+	p, err := Listen("127.0.0.1:0", ln.Addr().String(), Faults{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	s := graphite.New(p.Addr())
+	defer s.Close()
+	if err := s.Emit(collector.Fields{NumGoroutine: 1}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, lines, "cpu.goroutines 1 ")
+
+	// The sink notices the dropped connection once writing fails, reconnects and sends the
+	// lines it buffered along with the later samples.
+	p.Disconnect()
+	timeout := time.After(5 * time.Second)
+	for n := int64(2); ; n++ {
+		s.Emit(collector.Fields{NumGoroutine: n})
+		select {
+		case l := <-lines:
+			if strings.HasPrefix(l, "cpu.goroutines ") && !strings.HasPrefix(l, "cpu.goroutines 1 ") {
+				return
+			}
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("expected the sink to reconnect through the proxy")
+		}
+	}
+}
+
+func waitFor(t *testing.T, lines <-chan string, prefix string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case l := <-lines:
+			if strings.HasPrefix(l, prefix) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expected a line starting with %q", prefix)
+		}
+	}
+}
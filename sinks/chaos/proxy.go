@@ -0,0 +1,157 @@
+package chaos
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Proxy forwards TCP connections to a backend, delaying the data and dropping connections,
+// for sinks that connect over TCP such as the graphite sink. Point the sink at Addr. The
+// ErrorRate of its Faults is the probability with which a connection is refused, closed as
+// soon as it is accepted.
+type Proxy struct {
+	Faults
+
+	// DisconnectRate is the probability with which an established connection is dropped
+	// before forwarding each chunk of data.
+	DisconnectRate float64
+
+	// Timeout bounds connecting to the backend. Defaults to 5 seconds.
+	Timeout time.Duration
+
+	target string
+	ln     net.Listener
+	wg     sync.WaitGroup
+
+	connMu sync.Mutex
+	conns  map[net.Conn]struct{}
+}
+
+// Listen returns a Proxy listening on addr, such as "127.0.0.1:0" for a free port, and
+// forwarding to the backend at target. The faults must not be changed once it runs.
+func Listen(addr, target string, faults Faults, disconnectRate float64) (*Proxy, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{Faults: faults, DisconnectRate: disconnectRate, target: target, ln: ln, conns: map[net.Conn]struct{}{}}
+	p.wg.Add(1)
+	go p.serve()
+	return p, nil
+}
+
+// Addr returns the address the Proxy listens on.
+func (p *Proxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+// Disconnect drops every established connection, as if the backend restarted.
+func (p *Proxy) Disconnect() {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+}
+
+// Close stops listening and drops every connection.
+func (p *Proxy) Close() error {
+	err := p.ln.Close()
+	p.Disconnect()
+	p.connMu.Lock()
+	p.conns = nil
+	p.connMu.Unlock()
+	p.wg.Wait()
+	return err
+}
+
+func (p *Proxy) serve() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		if p.chance(p.ErrorRate) {
+			conn.Close()
+			continue
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.forward(conn)
+		}()
+	}
+}
+
+func (p *Proxy) forward(client net.Conn) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	backend, err := net.DialTimeout("tcp", p.target, timeout)
+	if err != nil {
+		client.Close()
+		return
+	}
+	if !p.track(client, backend) {
+		return
+	}
+	defer p.untrack(client, backend)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.copy(client, backend)
+	}()
+	p.copy(backend, client)
+	<-done
+}
+
+// copy forwards the data read from src to dst, closing both once either side fails.
+func (p *Proxy) copy(dst, src net.Conn) {
+	defer dst.Close()
+	defer src.Close()
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if p.chance(p.DisconnectRate) {
+				return
+			}
+			p.delay()
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// track registers the connections so that Disconnect and Close can drop them, closing
+// them instead if the Proxy is already closed.
+func (p *Proxy) track(conns ...net.Conn) bool {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	if p.conns == nil {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+	for _, conn := range conns {
+		p.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (p *Proxy) untrack(conns ...net.Conn) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	for _, conn := range conns {
+		delete(p.conns, conn)
+	}
+}
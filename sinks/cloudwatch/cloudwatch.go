@@ -0,0 +1,174 @@
+// Package cloudwatch formats collector.Fields in the CloudWatch Embedded Metric Format,
+// structured log events that CloudWatch Logs turns into metrics, so that Lambda functions
+// and ECS tasks get runtime metrics into CloudWatch without running an agent:
+//
+//	{"_aws":{"CloudWatchMetrics":[{"Dimensions":[["instance"]],"Metrics":[{"Name":"cpu.goroutines","Unit":"Count"},...],"Namespace":"GoRuntime"}],"Timestamp":1714564800000},"cpu.goroutines":12,"instance":"a",...}
+//
+// Lambda and the awslogs driver of ECS forward stdout to CloudWatch Logs, so New(os.Stdout, ns)
+// is all they need. Elsewhere, NewLogs submits the events through a CloudWatch Logs client.
+package cloudwatch
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// MaxMetrics is the maximum number of metrics CloudWatch accepts in a single event.
+// Samples with more fields are split across several events.
+const MaxMetrics = 100
+
+// LogEvent is a log event passed to a Logs client.
+type LogEvent struct {
+	Message   string
+	Timestamp time.Time
+}
+
+// Logs is implemented by a CloudWatch Logs client bound to a log group and stream, typically
+// an adapter calling PutLogEvents of the AWS SDK.
+type Logs interface {
+	PutLogEvents(events []LogEvent) error
+}
+
+// Sink emits every sample as one or more EMF events.
+type Sink struct {
+	// Namespace is the CloudWatch namespace of the metrics.
+	Namespace string
+
+	// Dimensions are added to every event and, along with the tags of the sample, used as
+	// the dimensions of the metrics. The tags of the sample take precedence over them.
+	Dimensions map[string]string
+
+	mu  sync.Mutex
+	w   io.Writer
+	put func([]LogEvent) error
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// New returns a Sink writing events to w, one per line. Each line is written with a single
+// call to Write.
+func New(w io.Writer, namespace string) *Sink {
+	return &Sink{Namespace: namespace, w: w}
+}
+
+// NewLogs returns a Sink submitting the events of every sample to l in a single call.
+func NewLogs(l Logs, namespace string) *Sink {
+	return &Sink{Namespace: namespace, put: l.PutLogEvents}
+}
+
+type metric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type directive struct {
+	Namespace  string     `json:"Namespace"`
+	Dimensions [][]string `json:"Dimensions"`
+	Metrics    []metric   `json:"Metrics"`
+}
+
+type metadata struct {
+	Timestamp         int64       `json:"Timestamp"`
+	CloudWatchMetrics []directive `json:"CloudWatchMetrics"`
+}
+
+// Emit implements collector.Sink.
+func (s *Sink) Emit(f collector.Fields) error {
+	events, err := s.Events(f)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.put != nil {
+		return s.put(events)
+	}
+	for _, e := range events {
+		if _, err := io.WriteString(s.w, e.Message+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events formats f as EMF events of at most MaxMetrics metrics each.
+func (s *Sink) Events(f collector.Fields) ([]LogEvent, error) {
+	dims := make(map[string]string, len(s.Dimensions)+len(f.Tags))
+	for k, v := range s.Dimensions {
+		dims[k] = v
+	}
+	for k, v := range f.Tags {
+		dims[k] = v
+	}
+	dimNames := make([]string, 0, len(dims))
+	for k := range dims {
+		dimNames = append(dimNames, k)
+	}
+	sort.Strings(dimNames)
+
+	m := f.ToMap()
+	names := make([]string, 0, len(m))
+	for name := range m {
+		if _, ok := dims[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	t := f.Time()
+	var events []LogEvent
+	for len(names) > 0 {
+		n := len(names)
+		if n > MaxMetrics {
+			n = MaxMetrics
+		}
+		event := make(map[string]interface{}, n+len(dims)+1)
+		d := directive{Namespace: s.Namespace, Dimensions: [][]string{dimNames}, Metrics: make([]metric, n)}
+		for i, name := range names[:n] {
+			d.Metrics[i] = metric{Name: name, Unit: unit(name)}
+			event[name] = m[name]
+		}
+		for k, v := range dims {
+			event[k] = v
+		}
+		event["_aws"] = metadata{Timestamp: t.UnixNano() / int64(time.Millisecond), CloudWatchMetrics: []directive{d}}
+
+		b, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, LogEvent{Message: string(b), Timestamp: t})
+		names = names[n:]
+	}
+	return events, nil
+}
+
+// unit returns the CloudWatch unit of the field name. CloudWatch has no unit for
+// nanoseconds, so durations in nanoseconds are reported without one.
+func unit(name string) string {
+	info, ok := collector.DescribeField(name)
+	if !ok {
+		return "None"
+	}
+	switch info.Unit {
+	case "":
+		return "Count"
+	case "bytes":
+		return "Bytes"
+	case "bytes_per_second":
+		return "Bytes/Second"
+	case "per_second":
+		return "Count/Second"
+	case "seconds":
+		return "Seconds"
+	case "percent":
+		return "Percent"
+	}
+	return "None"
+}
@@ -0,0 +1,75 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+type event struct {
+	AWS struct {
+		Timestamp         int64
+		CloudWatchMetrics []directive
+	} `json:"_aws"`
+	Goroutines float64 `json:"cpu.goroutines"`
+	Instance   string  `json:"instance"`
+	Region     string  `json:"region"`
+}
+
+func TestSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := New(buf, "GoRuntime")
+	s.Dimensions = map[string]string{"region": "eu-west-1", "instance": "default"}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := collector.Fields{NumGoroutine: 12, Timestamp: at.UnixNano(), Tags: map[string]string{"instance": "a"}}
+	if err := s.Emit(f); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if exp := (len(f.ToMap()) + MaxMetrics - 1) / MaxMetrics; len(lines) != exp {
+		t.Fatalf("expected %d events, got %d", exp, len(lines))
+	}
+	var metrics int
+	for i, line := range lines {
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.AWS.Timestamp != at.UnixNano()/1e6 || e.Instance != "a" || e.Region != "eu-west-1" {
+			t.Errorf("unexpected event %s", line)
+		}
+		d := e.AWS.CloudWatchMetrics[0]
+		if d.Namespace != "GoRuntime" || strings.Join(d.Dimensions[0], ",") != "instance,region" || len(d.Metrics) > MaxMetrics {
+			t.Errorf("unexpected directive %+v", d)
+		}
+		if i == 0 && (e.Goroutines != 12 || d.Metrics[1] != metric{"cpu.goroutines", "Count"}) {
+			t.Errorf("unexpected first event %s", line)
+		}
+		metrics += len(d.Metrics)
+	}
+	if metrics != len(f.ToMap()) {
+		t.Errorf("expected %d metrics, got %d", len(f.ToMap()), metrics)
+	}
+}
+
+type logs [][]LogEvent
+
+func (l *logs) PutLogEvents(events []LogEvent) error {
+	*l = append(*l, events)
+	return nil
+}
+
+func TestNewLogs(t *testing.T) {
+	l := &logs{}
+	if err := NewLogs(l, "GoRuntime").Emit(collector.Fields{Extra: map[string]float64{"app.heap": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*l) != 1 || !strings.Contains((*l)[0][0].Message, `{"Name":"app.heap","Unit":"None"}`) {
+		t.Errorf("unexpected events %+v", *l)
+	}
+}
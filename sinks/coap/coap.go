@@ -0,0 +1,254 @@
+// Package coap reports runtime metrics over CoAP (RFC 7252) with CBOR payloads, for
+// devices behind constrained gateways.
+//
+// A Sink pushes every sample to a gateway with a non-confirmable POST. A Server instead
+// exposes the latest sample as a resource that gateways can GET or observe (RFC 7641), in
+// which case every sample is pushed to the registered observers as a notification.
+//
+// A sample is several kilobytes of CBOR, more than fits the 1152 bytes RFC 7252 bounds
+// messages to, so both transfer it in blocks of 1024 bytes (RFC 7959). The Sink posts all
+// the blocks of a sample with the Block1 option, and the Server sends the first block with
+// the Block2 option, leaving clients to GET the others.
+package coap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/serializer"
+)
+
+// Message types.
+const (
+	typeCON = 0
+	typeNON = 1
+	typeACK = 2
+	typeRST = 3
+)
+
+// Method and response codes.
+const (
+	codeGET        = 0x01
+	codePOST       = 0x02
+	codeContent    = 0x45
+	codeBadOption  = 0x82
+	codeNotFound   = 0x84
+	codeNotAllowed = 0x85
+)
+
+// Option numbers.
+const (
+	optETag          = 4
+	optObserve       = 6
+	optURIPath       = 11
+	optContentFormat = 12
+	optBlock2        = 23
+	optBlock1        = 27
+	optSize2         = 28
+	optSize1         = 60
+)
+
+// blockSZX is the size exponent of the blocks payloads are split into, 16<<6 = 1024 bytes.
+const blockSZX = 6
+
+// maxDatagram is the largest UDP payload, the size of the read buffers.
+const maxDatagram = 65535
+
+// contentFormatCBOR is the CoAP content format identifier of application/cbor.
+const contentFormatCBOR = 60
+
+var errMalformed = errors.New("coap: malformed message")
+
+type option struct {
+	num   uint16
+	value []byte
+}
+
+type message struct {
+	typ     byte
+	code    byte
+	id      uint16
+	token   []byte
+	options []option
+	payload []byte
+}
+
+func (m *message) marshal() []byte {
+	b := []byte{1<<6 | m.typ<<4 | byte(len(m.token)), m.code, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], m.id)
+	b = append(b, m.token...)
+
+	opts := append([]option(nil), m.options...)
+	sort.SliceStable(opts, func(i, j int) bool { return opts[i].num < opts[j].num })
+	prev := uint16(0)
+	for _, o := range opts {
+		delta, length := int(o.num-prev), len(o.value)
+		prev = o.num
+		head := len(b)
+		b = append(b, 0)
+		var d, l byte
+		d, b = optionNibble(b, delta)
+		l, b = optionNibble(b, length)
+		b[head] = d<<4 | l
+		b = append(b, o.value...)
+	}
+
+	if len(m.payload) > 0 {
+		b = append(b, 0xff)
+		b = append(b, m.payload...)
+	}
+	return b
+}
+
+// optionNibble returns the 4-bit representation of an option delta or length, appending
+// the extended bytes it needs to b.
+func optionNibble(b []byte, v int) (byte, []byte) {
+	switch {
+	case v < 13:
+		return byte(v), b
+	case v < 269:
+		return 13, append(b, byte(v-13))
+	}
+	return 14, binary.BigEndian.AppendUint16(b, uint16(v-269))
+}
+
+func parse(b []byte) (*message, error) {
+	if len(b) < 4 || b[0]>>6 != 1 {
+		return nil, errMalformed
+	}
+	tkl := int(b[0] & 0x0f)
+	if tkl > 8 || len(b) < 4+tkl {
+		return nil, errMalformed
+	}
+	m := &message{
+		typ:   b[0] >> 4 & 0x03,
+		code:  b[1],
+		id:    binary.BigEndian.Uint16(b[2:]),
+		token: append([]byte(nil), b[4:4+tkl]...),
+	}
+
+	b = b[4+tkl:]
+	num := 0
+	for len(b) > 0 {
+		if b[0] == 0xff {
+			m.payload = b[1:]
+			break
+		}
+		d, l := int(b[0]>>4), int(b[0]&0x0f)
+		b = b[1:]
+		var ok bool
+		if d, b, ok = extendNibble(d, b); !ok {
+			return nil, errMalformed
+		}
+		if l, b, ok = extendNibble(l, b); !ok || len(b) < l {
+			return nil, errMalformed
+		}
+		num += d
+		m.options = append(m.options, option{num: uint16(num), value: b[:l]})
+		b = b[l:]
+	}
+	return m, nil
+}
+
+func extendNibble(v int, b []byte) (int, []byte, bool) {
+	switch v {
+	case 13:
+		if len(b) < 1 {
+			return 0, nil, false
+		}
+		return int(b[0]) + 13, b[1:], true
+	case 14:
+		if len(b) < 2 {
+			return 0, nil, false
+		}
+		return int(binary.BigEndian.Uint16(b)) + 269, b[2:], true
+	case 15:
+		return 0, nil, false
+	}
+	return v, b, true
+}
+
+func (m *message) option(num uint16) ([]byte, bool) {
+	for _, o := range m.options {
+		if o.num == num {
+			return o.value, true
+		}
+	}
+	return nil, false
+}
+
+func (m *message) path() string {
+	var segments []string
+	for _, o := range m.options {
+		if o.num == optURIPath {
+			segments = append(segments, string(o.value))
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathOptions(path string) []option {
+	var opts []option
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment != "" {
+			opts = append(opts, option{num: optURIPath, value: []byte(segment)})
+		}
+	}
+	return opts
+}
+
+// uintOption encodes v as an option value of minimal length.
+func uintOption(num uint16, v uint32) option {
+	b := binary.BigEndian.AppendUint32(nil, v)
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return option{num: num, value: b}
+}
+
+func decodeUint(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// blockOption encodes the Block1 or Block2 option of the n-th block of size 16<<szx.
+func blockOption(num uint16, n uint32, more bool, szx uint32) option {
+	v := n<<4 | szx
+	if more {
+		v |= 1 << 3
+	}
+	return uintOption(num, v)
+}
+
+// block returns the n-th block of size 16<<szx of payload and whether others follow it,
+// or false if payload has no such block.
+func block(payload []byte, n, szx uint32) ([]byte, bool, bool) {
+	size := 16 << szx
+	start := int(n) * size
+	if start > len(payload) || start == len(payload) && n > 0 {
+		return nil, false, false
+	}
+	end := start + size
+	if end >= len(payload) {
+		return payload[start:], false, true
+	}
+	return payload[start:end], true, true
+}
+
+// etag identifies the sample of sequence number seq.
+func etag(seq uint32) option {
+	return option{num: optETag, value: binary.BigEndian.AppendUint32(nil, seq)}
+}
+
+func encode(f collector.Fields) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := serializer.CBOR{}.Encode(buf, f)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,193 @@
+package coap
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := &message{
+		typ:     typeCON,
+		code:    codeGET,
+		id:      0x1234,
+		token:   []byte{1, 2},
+		options: append(pathOptions("/a/long-enough-segment"), uintOption(optObserve, 0), option{num: 300, value: []byte("x")}),
+		payload: []byte("hi"),
+	}
+	got, err := parse(m.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.typ != m.typ || got.code != m.code || got.id != m.id || !bytes.Equal(got.token, m.token) || string(got.payload) != "hi" {
+		t.Errorf("unexpected message: %+v", got)
+	}
+	if p := got.path(); p != "a/long-enough-segment" {
+		t.Errorf("unexpected path %q", p)
+	}
+	if v, ok := got.option(300); !ok || string(v) != "x" {
+		t.Errorf("expected option 300, got %q", v)
+	}
+}
+
+func TestSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := Dial(conn.LocalAddr().String(), "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Emit(collector.Fields{NumGoroutine: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := read(t, conn)
+	if m.typ != typeNON || m.code != codePOST || m.path() != "metrics" || len(m.payload) == 0 {
+		t.Errorf("unexpected request: %+v", m)
+	}
+}
+
+func TestServerObserve(t *testing.T) {
+	srv, err := Listen("127.0.0.1:0", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	go srv.Serve()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req := &message{typ: typeCON, code: codeGET, id: 7, token: []byte{9}, options: append(pathOptions("metrics"), uintOption(optObserve, 0))}
+	conn.WriteTo(req.marshal(), srv.Addr())
+	if res := read(t, conn); res.typ != typeACK || res.id != 7 || res.code != codeContent {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+
+	srv.Emit(collector.Fields{NumGoroutine: 1})
+	n := read(t, conn)
+	if _, ok := n.option(optObserve); !ok || !bytes.Equal(n.token, []byte{9}) || len(n.payload) == 0 {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+}
+
+func TestSinkBlockwise(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	s, err := Dial(conn.LocalAddr().String(), "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	f := collector.New(nil, collector.WithDeltas(true), collector.WithPauses(true)).OneOff()
+	want, _ := encode(f)
+	if len(want) <= 1024 {
+		t.Fatalf("expected a default sample to take several blocks, got %d bytes", len(want))
+	}
+	if err := s.Emit(f); err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for n := uint32(0); ; n++ {
+		m := read(t, conn)
+		if len(m.marshal()) > 1152 {
+			t.Errorf("expected messages of at most 1152 bytes, got %d", len(m.marshal()))
+		}
+		v, ok := m.option(optBlock1)
+		if !ok || decodeUint(v)>>4 != n || decodeUint(v)&7 != blockSZX {
+			t.Fatalf("expected block %d, got %+v", n, m)
+		}
+		if size, ok := m.option(optSize1); n == 0 && (!ok || int(decodeUint(size)) != len(want)) {
+			t.Errorf("expected the size of the sample on the first block, got %v", size)
+		}
+		got = append(got, m.payload...)
+		if decodeUint(v)&8 == 0 {
+			break
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected the blocks to make up the sample, got %d of %d bytes", len(got), len(want))
+	}
+}
+
+func TestServerBlockwise(t *testing.T) {
+	srv, err := Listen("127.0.0.1:0", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	go srv.Serve()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req := &message{typ: typeCON, code: codeGET, id: 1, token: []byte{9}, options: append(pathOptions("metrics"), uintOption(optObserve, 0))}
+	conn.WriteTo(req.marshal(), srv.Addr())
+	read(t, conn)
+
+	f := collector.New(nil, collector.WithDeltas(true), collector.WithPauses(true)).OneOff()
+	want, _ := encode(f)
+	srv.Emit(f)
+	n := read(t, conn)
+	v, ok := n.option(optBlock2)
+	if !ok || decodeUint(v) != 1<<3|blockSZX || len(n.payload) != 1024 {
+		t.Fatalf("expected the first block in the notification, got %+v", n)
+	}
+	tag, _ := n.option(optETag)
+
+	got := n.payload
+	for num := uint32(1); ; num++ {
+		req := &message{typ: typeCON, code: codeGET, id: uint16(1 + num), options: append(pathOptions("metrics"), blockOption(optBlock2, num, false, blockSZX))}
+		conn.WriteTo(req.marshal(), srv.Addr())
+		res := read(t, conn)
+		v, ok := res.option(optBlock2)
+		if etag, _ := res.option(optETag); !ok || res.code != codeContent || !bytes.Equal(etag, tag) {
+			t.Fatalf("expected block %d of the same sample, got %+v", num, res)
+		}
+		got = append(got, res.payload...)
+		if decodeUint(v)&8 == 0 {
+			break
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected the blocks to make up the sample, got %d of %d bytes", len(got), len(want))
+	}
+
+	req = &message{typ: typeCON, code: codeGET, id: 99, options: append(pathOptions("metrics"), blockOption(optBlock2, 100, false, blockSZX))}
+	conn.WriteTo(req.marshal(), srv.Addr())
+	if res := read(t, conn); res.code != codeBadOption {
+		t.Errorf("expected a block past the end to be rejected, got %+v", res)
+	}
+}
+
+func read(t *testing.T, conn net.PacketConn) *message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, maxDatagram)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := parse(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,172 @@
+package coap
+
+import (
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// Server exposes the latest sample as an observable CoAP resource. Clients that GET the
+// resource with the Observe option set to 0 are notified of every sample passed to Emit
+// until they deregister or reject a notification with a reset message. Responses and
+// notifications carry the first block of the sample, along with the ETag of the sample,
+// and the other blocks are served to GET requests with the Block2 option.
+type Server struct {
+	conn net.PacketConn
+	path string
+
+	mu        sync.Mutex
+	latest    []byte
+	observers map[string]*observer
+	id        uint16
+	seq       uint32
+}
+
+var _ collector.Sink = (*Server)(nil)
+
+type observer struct {
+	addr   net.Addr
+	token  []byte
+	lastID uint16
+}
+
+// Listen returns a Server exposing samples at path on the UDP address addr. Serve must be
+// called to handle requests.
+func Listen(addr, path string) (*Server, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		conn:      conn,
+		path:      strings.Trim(path, "/"),
+		observers: make(map[string]*observer),
+		id:        uint16(rand.Uint32()),
+	}, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Serve handles requests until the server is closed.
+func (s *Server) Serve() error {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		m, err := parse(buf[:n])
+		if err != nil {
+			continue
+		}
+		s.handle(m, addr)
+	}
+}
+
+func (s *Server) handle(req *message, addr net.Addr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.typ == typeRST {
+		for key, o := range s.observers {
+			if o.addr.String() == addr.String() && o.lastID == req.id {
+				delete(s.observers, key)
+			}
+		}
+		return
+	}
+	if req.typ == typeACK || req.code == 0 {
+		return
+	}
+
+	res := &message{typ: typeNON, code: codeContent, id: s.nextID(), token: req.token}
+	if req.typ == typeCON {
+		res.typ, res.id = typeACK, req.id
+	}
+
+	switch {
+	case req.path() != s.path:
+		res.code = codeNotFound
+	case req.code != codeGET:
+		res.code = codeNotAllowed
+	default:
+		n, szx := uint32(0), uint32(blockSZX)
+		if v, ok := req.option(optBlock2); ok {
+			n, szx = decodeUint(v)>>4, decodeUint(v)&7
+			if szx > blockSZX {
+				// The requested size is larger than ours, which block numbers are in.
+				n, szx = n<<(szx-blockSZX), blockSZX
+			}
+		}
+		b, more, ok := block(s.latest, n, szx)
+		if !ok {
+			res.code = codeBadOption
+			break
+		}
+		key := addr.String() + "/" + string(req.token)
+		if v, ok := req.option(optObserve); ok && n == 0 {
+			if decodeUint(v) == 0 {
+				s.observers[key] = &observer{addr: addr, token: req.token, lastID: res.id}
+				res.options = append(res.options, uintOption(optObserve, s.seq))
+			} else {
+				delete(s.observers, key)
+			}
+		}
+		res.options = append(res.options, uintOption(optContentFormat, contentFormatCBOR), etag(s.seq))
+		if more || n > 0 {
+			res.options = append(res.options, blockOption(optBlock2, n, more, szx), uintOption(optSize2, uint32(len(s.latest))))
+		}
+		res.payload = b
+	}
+	s.conn.WriteTo(res.marshal(), addr)
+}
+
+// Emit stores f as the latest sample and notifies the observers.
+func (s *Server) Emit(f collector.Fields) error {
+	payload, err := encode(f)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = payload
+	s.seq = (s.seq + 1) & 0xffffff
+	first, more, _ := block(payload, 0, blockSZX)
+	var firstErr error
+	for _, o := range s.observers {
+		o.lastID = s.nextID()
+		n := &message{
+			typ:     typeNON,
+			code:    codeContent,
+			id:      o.lastID,
+			token:   o.token,
+			options: []option{uintOption(optObserve, s.seq), uintOption(optContentFormat, contentFormatCBOR), etag(s.seq)},
+			payload: first,
+		}
+		if more {
+			n.options = append(n.options, blockOption(optBlock2, 0, true, blockSZX), uintOption(optSize2, uint32(len(payload))))
+		}
+		if _, err := s.conn.WriteTo(n.marshal(), o.addr); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Server) nextID() uint16 {
+	s.id++
+	return s.id
+}
@@ -0,0 +1,80 @@
+package coap
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/dialer"
+)
+
+// Sink pushes every sample to a CoAP endpoint with non-confirmable POST requests, one per
+// block of the sample.
+type Sink struct {
+	// ResolveInterval is how often the hostname of the address is resolved again, so that
+	// the sink follows the endpoint to a new IP address without a restart. Defaults to one
+	// minute, negative values never resolve it again.
+	ResolveInterval time.Duration
+
+	conn *dialer.Conn
+	path []option
+
+	mu sync.Mutex
+	id uint16
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// Dial returns a Sink that posts samples to the resource at path on the CoAP endpoint at
+// addr, a host:port pair such as "localhost:5683" or "[::1]:5683".
+func Dial(addr, path string) (*Sink, error) {
+	conn, err := dialer.DialConn("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{conn: conn, path: pathOptions(path), id: uint16(rand.Uint32())}, nil
+}
+
+// Emit posts f to the endpoint.
+func (s *Sink) Emit(f collector.Fields) error {
+	payload, err := encode(f)
+	if err != nil {
+		return err
+	}
+
+	blocks := (len(payload) + 16<<blockSZX - 1) / (16 << blockSZX)
+	if blocks == 0 {
+		blocks = 1
+	}
+	s.mu.Lock()
+	id := s.id
+	s.id += uint16(blocks)
+	s.mu.Unlock()
+
+	for n := uint32(0); ; n++ {
+		b, more, _ := block(payload, n, blockSZX)
+		id++
+		m := &message{
+			typ:     typeNON,
+			code:    codePOST,
+			id:      id,
+			options: append(append([]option(nil), s.path...), uintOption(optContentFormat, contentFormatCBOR)),
+			payload: b,
+		}
+		if blocks > 1 {
+			m.options = append(m.options, blockOption(optBlock1, n, more, blockSZX))
+			if n == 0 {
+				m.options = append(m.options, uintOption(optSize1, uint32(len(payload))))
+			}
+		}
+		if err := s.conn.Send(m.marshal(), s.ResolveInterval); err != nil || !more {
+			return err
+		}
+	}
+}
+
+// Close closes the underlying connection.
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
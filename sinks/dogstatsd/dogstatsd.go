@@ -0,0 +1,165 @@
+// Package dogstatsd emits collector.Fields as DogStatsD gauges, carrying the tags of the
+// sample in Datadog's "|#name:value" extension, over UDP or a Unix domain socket.
+package dogstatsd
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/dialer"
+)
+
+// Default maximum packet sizes: below the common Ethernet MTU for UDP, and the default
+// buffer of the Datadog Agent for Unix domain sockets.
+const (
+	DefaultMaxPacketSize    = 1432
+	DefaultMaxPacketSizeUDS = 8192
+)
+
+// Sink emits every field of a sample as a DogStatsD gauge.
+type Sink struct {
+	// Prefix is prepended to every metric name, separated by a dot.
+	Prefix string
+
+	// Tags are added to every gauge. The tags of the sample take precedence over them.
+	Tags map[string]string
+
+	// MaxPacketSize is the maximum size of a packet in bytes. Defaults to
+	// DefaultMaxPacketSize over UDP and DefaultMaxPacketSizeUDS over a Unix socket.
+	MaxPacketSize int
+
+	// Deadline, when positive, bounds the time spent sending a sample. Gauges are sent in
+	// the order of Fields.Series, so the critical ones go out first.
+	Deadline time.Duration
+
+	// ResolveInterval is how often the hostname of the address is resolved again, so that
+	// the sink follows the agent to a new IP address without a restart. Defaults to one
+	// minute, negative values never resolve it again.
+	ResolveInterval time.Duration
+
+	conn    *dialer.Conn
+	maxSize int
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// Dial returns a Sink sending to the Datadog Agent at addr: either a host:port pair for
+// UDP, such as "localhost:8125" or "[::1]:8125", or, for a Unix domain datagram socket, a
+// path prefixed with "unix://", such as "unix:///var/run/datadog/dsd.socket".
+func Dial(addr string) (*Sink, error) {
+	network, maxSize := "udp", DefaultMaxPacketSize
+	if path := strings.TrimPrefix(addr, "unix://"); path != addr {
+		network, addr, maxSize = "unixgram", path, DefaultMaxPacketSizeUDS
+	}
+	conn, err := dialer.DialConn(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{conn: conn, maxSize: maxSize}, nil
+}
+
+// Emit sends f to the agent.
+func (s *Sink) Emit(f collector.Fields) error {
+	maxSize := s.MaxPacketSize
+	if maxSize <= 0 {
+		maxSize = s.maxSize
+	}
+	tags := s.tagSuffix(f.Tags)
+
+	var deadline time.Time
+	if s.Deadline > 0 {
+		deadline = time.Now().Add(s.Deadline)
+	}
+
+	packet := make([]byte, 0, maxSize)
+	flush := func() error {
+		if len(packet) == 0 {
+			return nil
+		}
+		err := s.conn.Send(packet, s.ResolveInterval)
+		packet = packet[:0]
+		return err
+	}
+
+	_, err := collector.SendSeries(f.Series(), deadline, func(series collector.Series) error {
+		line := s.appendGauge(nil, series, tags)
+		if len(packet) > 0 && len(packet)+1+len(line) > maxSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+		return nil
+	})
+	if ferr := flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// appendGauge appends the DogStatsD line of series to b. Unlike plain StatsD, DogStatsD
+// sets gauges to negative values rather than adjusting them.
+func (s *Sink) appendGauge(b []byte, series collector.Series, tags string) []byte {
+	if s.Prefix != "" {
+		b = append(b, s.Prefix...)
+		b = append(b, '.')
+	}
+	b = append(b, series.Name...)
+	b = append(b, ':')
+	switch v := series.Value.(type) {
+	case int64:
+		b = strconv.AppendInt(b, v, 10)
+	case float64:
+		b = strconv.AppendFloat(b, v, 'f', -1, 64)
+	}
+	b = append(b, "|g"...)
+	return append(b, tags...)
+}
+
+// tagSuffix formats the union of the sink's and the sample's tags as "|#name:value,..."
+// sorted by name.
+func (s *Sink) tagSuffix(sample map[string]string) string {
+	if len(s.Tags) == 0 && len(sample) == 0 {
+		return ""
+	}
+	tags := make(map[string]string, len(s.Tags)+len(sample))
+	for k, v := range s.Tags {
+		tags[k] = v
+	}
+	for k, v := range sample {
+		tags[k] = v
+	}
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("|#")
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(tagEscaper.Replace(k))
+		if v := tags[k]; v != "" {
+			b.WriteByte(':')
+			b.WriteString(tagEscaper.Replace(v))
+		}
+	}
+	return b.String()
+}
+
+// tagEscaper replaces the characters that delimit tags and lines.
+var tagEscaper = strings.NewReplacer(",", "_", "|", "_", "\n", "_", "#", "_")
+
+// Close closes the underlying connection.
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,63 @@
+package dogstatsd
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	path := filepath.Join(t.TempDir(), "dsd.socket")
+	uds, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skip("unix datagram sockets are not supported:", err)
+	}
+	defer uds.Close()
+
+	for _, tc := range []struct {
+		addr string
+		conn net.PacketConn
+	}{{udp.LocalAddr().String(), udp}, {"unix://" + path, uds}} {
+		s, err := Dial(tc.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Prefix = "app"
+		s.Tags = map[string]string{"env": "prod", "instance": "default"}
+		f := collector.Fields{NumGoroutine: 5, HeapGrowth: -10, Tags: map[string]string{"instance": "a,b"}}
+		if err := s.Emit(f); err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+
+		var lines []string
+		buf := make([]byte, 16<<10)
+		tc.conn.SetReadDeadline(time.Now().Add(time.Second))
+		for len(lines) < len(f.ToMap()) {
+			n, _, err := tc.conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+		joined := strings.Join(lines, "\n")
+		for _, exp := range []string{
+			"app.cpu.goroutines:5|g|#env:prod,instance:a_b\n",
+			"app.mem.heap.growth:-10|g|#env:prod,instance:a_b\n",
+		} {
+			if !strings.Contains(joined, exp) {
+				t.Errorf("%s: expected %q in:\n%s", tc.addr, exp, joined)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package email mails collector events over SMTP, rendering the subject and body from
+// templates and rate limiting repeated alerts.
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// DefaultSubject and DefaultBody are the templates used when a Sink does not configure
+// its own. Templates are executed with a Data.
+var (
+	DefaultSubject = template.Must(template.New("subject").Parse(
+		`[{{if .Resolved}}RESOLVED{{else}}FIRING{{end}}] {{with .Instance}}{{.}} {{end}}{{.Kind}}: {{.Message}}`))
+
+	DefaultBody = template.Must(template.New("body").Parse(`{{.Message}}
+
+Kind:      {{.Kind}}
+{{with .Instance}}Instance:  {{.}}
+{{end}}{{with .Field}}Field:     {{.}}
+{{end}}Value:     {{.Value}}
+Threshold: {{.Threshold}}
+Time:      {{.Time.Format "2006-01-02 15:04:05 MST"}}
+{{if .Suppressed}}
+{{.Suppressed}} similar event(s) were not mailed since the previous message.
+{{end}}`))
+)
+
+// Data is what the subject and body templates are executed with.
+type Data struct {
+	collector.Event
+
+	// Suppressed is the number of events of the same alert dropped by the rate limit
+	// since the previous message.
+	Suppressed int
+}
+
+// Sink mails every event it receives, unless an event of the same alert was mailed less
+// than MinInterval ago. Resolved events are always mailed.
+type Sink struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+
+	// Auth authenticates with the server, if set.
+	Auth smtp.Auth
+
+	From string
+	To   []string
+
+	// Subject and Body render the message. Default to DefaultSubject and DefaultBody.
+	Subject *template.Template
+	Body    *template.Template
+
+	// MinInterval is the minimum time between two messages about the same alert, which is
+	// identified by the Kind, Instance, Name and Field of its events. Defaults to 10
+	// minutes.
+	MinInterval time.Duration
+
+	mu         sync.Mutex
+	last       map[string]time.Time
+	suppressed map[string]int
+
+	// sendMail is replaced in tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+var _ collector.EventSink = (*Sink)(nil)
+
+// New returns a Sink mailing from one address to the others through the server at addr.
+func New(addr string, auth smtp.Auth, from string, to ...string) *Sink {
+	return &Sink{Addr: addr, Auth: auth, From: from, To: to}
+}
+
+// EmitEvent implements collector.EventSink.
+func (s *Sink) EmitEvent(e collector.Event) error {
+	d, ok := s.admit(e)
+	if !ok {
+		return nil
+	}
+
+	subjectTmpl, bodyTmpl := s.Subject, s.Body
+	if subjectTmpl == nil {
+		subjectTmpl = DefaultSubject
+	}
+	if bodyTmpl == nil {
+		bodyTmpl = DefaultBody
+	}
+	var subject, body bytes.Buffer
+	if err := subjectTmpl.Execute(&subject, d); err != nil {
+		return fmt.Errorf("email: subject: %w", err)
+	}
+	if err := bodyTmpl.Execute(&body, d); err != nil {
+		return fmt.Errorf("email: body: %w", err)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+
+	send := s.sendMail
+	if send == nil {
+		send = smtp.SendMail
+	}
+	if err := send(s.Addr, s.Auth, s.From, s.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return nil
+}
+
+// admit applies the rate limit to e, returning the template data if it is to be mailed.
+func (s *Sink) admit(e collector.Event) (Data, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		s.last = make(map[string]time.Time)
+		s.suppressed = make(map[string]int)
+	}
+
+	interval := s.MinInterval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	key := e.Kind + "\x00" + e.Instance + "\x00" + e.Name + "\x00" + e.Field
+	now := time.Now()
+	if last, ok := s.last[key]; ok && !e.Resolved && now.Sub(last) < interval {
+		s.suppressed[key]++
+		return Data{}, false
+	}
+
+	d := Data{Event: e, Suppressed: s.suppressed[key]}
+	s.last[key] = now
+	delete(s.suppressed, key)
+	return d, true
+}
@@ -0,0 +1,69 @@
+package email
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	var sent []string
+	s := New("localhost:25", nil, "alerts@example.com", "oncall@example.com")
+	s.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	e := collector.Event{Kind: "memory_limit", Instance: "api", Message: "almost out", Time: time.Now()}
+	for i := 0; i < 3; i++ {
+		if err := s.EmitEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected repeated events to be rate limited, got %d messages", len(sent))
+	}
+	if !strings.Contains(sent[0], "Subject: [FIRING] api memory_limit: almost out\r\n") {
+		t.Errorf("unexpected message:\n%s", sent[0])
+	}
+
+	e.Resolved = true
+	if err := s.EmitEvent(e); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || !strings.Contains(sent[1], "[RESOLVED]") || !strings.Contains(sent[1], "2 similar event(s)") {
+		t.Errorf("expected a resolve message mentioning the suppressed events, got:\n%s", sent[len(sent)-1])
+	}
+}
+
+func TestSinkAlerts(t *testing.T) {
+	var sent []string
+	s := New("localhost:25", nil, "alerts@example.com", "oncall@example.com")
+	s.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+	depth := 0.0
+	rule := collector.Rule{Field: "app.queue_depth", Kind: collector.Max, Limit: 10}
+	c := collector.New(nil, collector.WithAlert(&collector.Alert{Name: "queue", Rule: rule}),
+		collector.WithAlert(&collector.Alert{Name: "queue_page", Rule: rule}), collector.WithEventSink(s))
+	c.RegisterGauge("app.queue_depth", func() float64 { return depth })
+	for _, d := range []float64{20, 5, 20, 5} {
+		depth = d
+		c.OneOff()
+	}
+
+	// Each alert mails its first firing and both recoveries, the second firing being
+	// suppressed and counted on the recovery that follows.
+	if len(sent) != 6 {
+		t.Fatalf("expected 6 messages, got %d", len(sent))
+	}
+	for _, msg := range sent[4:] {
+		if !strings.Contains(msg, "[RESOLVED]") || !strings.Contains(msg, "1 similar event(s)") {
+			t.Errorf("expected the recovery to count the suppressed firing, got:\n%s", msg)
+		}
+	}
+}
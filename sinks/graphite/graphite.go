@@ -0,0 +1,216 @@
+// Package graphite writes collector.Fields to Graphite's Carbon daemon in the plaintext
+// protocol, one "path value timestamp" line per field over TCP. Lines that cannot be
+// written are buffered and sent once the connection is re-established.
+package graphite
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/dialer"
+)
+
+// DefaultBufferSize is the number of lines buffered while Carbon is unreachable when
+// BufferSize is not set.
+const DefaultBufferSize = 10000
+
+// Sink writes every field of a sample as a Graphite metric.
+type Sink struct {
+	// Prefix is prepended to every metric path, separated by a dot.
+	Prefix string
+
+	// Tags, when set, appends the sample's tags to every path in the Graphite 1.1 tag
+	// format, such as "mem.sys;instance=a".
+	Tags bool
+
+	// Timeout bounds connecting and writing. Defaults to 5 seconds.
+	Timeout time.Duration
+
+	// BufferSize is the maximum number of lines kept while Carbon is unreachable. The
+	// oldest lines are dropped once it is exceeded. Defaults to DefaultBufferSize.
+	BufferSize int
+
+	// ResolveInterval is how often the hostname of the address is resolved again. The
+	// connection is re-established once it no longer resolves to the connected IP address,
+	// so that the sink follows Carbon to a new host without a restart. Defaults to one
+	// minute, negative values never resolve it again.
+	ResolveInterval time.Duration
+
+	addr string
+
+	mu         sync.Mutex
+	conn       net.Conn
+	resolvedAt time.Time
+	pending    [][]byte
+	drainRate  float64
+}
+
+var _ collector.BufferedSink = (*Sink)(nil)
+
+// New returns a Sink writing to the Carbon plaintext listener at addr, a host:port pair
+// such as "localhost:2003" or "[::1]:2003". The connection is made on the first Emit, over
+// IPv6 or IPv4, whichever of the addresses of a dual-stack host answers first.
+func New(addr string) *Sink {
+	return &Sink{addr: addr}
+}
+
+// Emit implements collector.Sink. If Carbon cannot be reached the lines are buffered and
+// the error returned; they are sent along with those of a later sample.
+func (s *Sink) Emit(f collector.Fields) error {
+	ts := strconv.FormatInt(f.Time().Unix(), 10)
+	suffix := ""
+	if s.Tags {
+		suffix = tagSuffix(f.Tags)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, series := range f.Series() {
+		s.pending = append(s.pending, s.appendLine(nil, series, suffix, ts))
+	}
+	if size := s.bufferSize(); len(s.pending) > size {
+		s.pending = append(s.pending[:0], s.pending[len(s.pending)-size:]...)
+	}
+	return s.flush()
+}
+
+// Close closes the connection to Carbon. Buffered lines are discarded.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = nil
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Buffered implements collector.BufferedSink, returning the number of lines waiting for
+// Carbon and the rate in lines per second they were last written at.
+func (s *Sink) Buffered() (depth int, drainRate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending), s.drainRate
+}
+
+func (s *Sink) bufferSize() int {
+	if s.BufferSize > 0 {
+		return s.BufferSize
+	}
+	return DefaultBufferSize
+}
+
+func (s *Sink) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return 5 * time.Second
+}
+
+// flush writes the pending lines, reconnecting first if needed. On failure the connection
+// is dropped and the lines not written remain pending.
+func (s *Sink) flush() error {
+	if s.conn != nil && s.moved() {
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.conn == nil {
+		conn, err := dialer.Dial("tcp", s.addr, s.timeout())
+		if err != nil {
+			return err
+		}
+		s.conn, s.resolvedAt = conn, time.Now()
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+	var buf bytes.Buffer
+	start, lines := time.Now(), len(s.pending)
+	defer func() {
+		if written := lines - len(s.pending); written > 0 {
+			s.drainRate = float64(written) / time.Since(start).Seconds()
+		}
+	}()
+	for len(s.pending) > 0 {
+		// Write in chunks so a failure only requeues what was not sent.
+		n := 0
+		buf.Reset()
+		for n < len(s.pending) && buf.Len() < 64<<10 {
+			buf.Write(s.pending[n])
+			n++
+		}
+		if _, err := s.conn.Write(buf.Bytes()); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+		s.pending = s.pending[n:]
+	}
+	s.pending = nil
+	return nil
+}
+
+// moved reports whether Carbon moved to another address, resolving its hostname at most
+// once per ResolveInterval.
+func (s *Sink) moved() bool {
+	interval := s.ResolveInterval
+	if interval == 0 {
+		interval = dialer.DefaultResolveInterval
+	}
+	now := time.Now()
+	if interval < 0 || now.Sub(s.resolvedAt) < interval {
+		return false
+	}
+	s.resolvedAt = now
+	return dialer.Moved(s.conn, s.addr, s.timeout())
+}
+
+func (s *Sink) appendLine(b []byte, series collector.Series, suffix, ts string) []byte {
+	if s.Prefix != "" {
+		b = append(b, s.Prefix...)
+		b = append(b, '.')
+	}
+	b = append(b, series.Name...)
+	b = append(b, suffix...)
+	b = append(b, ' ')
+	switch v := series.Value.(type) {
+	case int64:
+		b = strconv.AppendInt(b, v, 10)
+	case float64:
+		b = strconv.AppendFloat(b, v, 'f', -1, 64)
+	}
+	b = append(b, ' ')
+	b = append(b, ts...)
+	return append(b, '\n')
+}
+
+// tagSuffix formats tags as ";name=value" pairs sorted by name. Characters Graphite does
+// not allow in tags are replaced with underscores.
+func tagSuffix(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, k := range names {
+		b.WriteByte(';')
+		b.WriteString(tagEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(tagEscaper.Replace(tags[k]))
+	}
+	return b.String()
+}
+
+var tagEscaper = strings.NewReplacer(";", "_", "!", "_", "^", "_", "=", "_", " ", "_", "~", "_")
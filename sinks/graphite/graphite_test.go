@@ -0,0 +1,78 @@
+package graphite
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := New(addr)
+	s.Prefix, s.Tags = "app", true
+	at := time.Unix(1700000000, 0)
+	f := collector.Fields{NumGoroutine: 7, GCCPUFraction: 0.5, Timestamp: at.UnixNano(), Tags: map[string]string{"instance": "a b"}}
+
+	// Carbon is down: the lines are buffered.
+	if err := s.Emit(f); err == nil {
+		t.Fatal("expected an error while Carbon is unreachable")
+	}
+	if depth, rate := s.Buffered(); depth == 0 || rate != 0 {
+		t.Errorf("expected buffered lines and no drain rate, got %d and %v", depth, rate)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip("cannot listen on the same address again:", err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 1000)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+
+	f.NumGoroutine = 8
+	if err := s.Emit(f); err != nil {
+		t.Fatal(err)
+	}
+	if depth, rate := s.Buffered(); depth != 0 || rate <= 0 {
+		t.Errorf("expected an empty buffer and a drain rate, got %d and %v", depth, rate)
+	}
+	s.Close()
+
+	var goroutines []string
+	timeout := time.After(5 * time.Second)
+	for len(goroutines) < 2 {
+		select {
+		case l := <-lines:
+			if strings.HasPrefix(l, "app.cpu.goroutines;") {
+				goroutines = append(goroutines, l)
+			}
+			if strings.HasPrefix(l, "app.mem.gc.cpu_fraction;") && l != "app.mem.gc.cpu_fraction;instance=a_b 0.5 1700000000" {
+				t.Errorf("unexpected line %q", l)
+			}
+		case <-timeout:
+			t.Fatalf("expected the buffered and the new sample, got %v", goroutines)
+		}
+	}
+	if goroutines[0] != "app.cpu.goroutines;instance=a_b 7 1700000000" || goroutines[1] != "app.cpu.goroutines;instance=a_b 8 1700000000" {
+		t.Errorf("unexpected lines %v", goroutines)
+	}
+}
@@ -0,0 +1,159 @@
+// Package influx writes collector.Fields as InfluxDB line protocol to the HTTP write
+// endpoint of InfluxDB 1.x or 2.x, batching points and retrying failed writes.
+package influx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/serializer"
+	"github.com/tevjef/go-runtime-metrics/sinks"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/httppost"
+)
+
+// Sink writes samples to an InfluxDB server.
+type Sink struct {
+	// Measurement is the measurement name of every point. Defaults to
+	// serializer.DefaultMeasurement.
+	Measurement string
+
+	// Tags are added to every point, along with the Tags of the sample, which take
+	// precedence.
+	Tags map[string]string
+
+	// Database and RetentionPolicy select where points are written on InfluxDB 1.x, and
+	// Username and Password authenticate the writes.
+	Database        string
+	RetentionPolicy string
+	Username        string
+	Password        string
+
+	// Org and Bucket select where points are written on InfluxDB 2.x, and Token
+	// authenticates the writes. Setting Bucket selects the 2.x write API.
+	Org    string
+	Bucket string
+	Token  string
+
+	// BatchSize is the number of points buffered before they are written. Defaults to 1,
+	// writing every sample as it is emitted.
+	BatchSize int
+
+	// MaxRetries is the number of times a failed write is retried before its points are
+	// dropped. Only network failures, 429 and 5xx responses are retried. Defaults to 0.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubling with each attempt.
+	// Defaults to one second.
+	RetryBackoff time.Duration
+
+	// Client sends the write requests. Defaults to a client timing out after 10 seconds.
+	Client *http.Client
+
+	addr    string
+	mu      sync.Mutex
+	batch   bytes.Buffer
+	pending int
+}
+
+var _ sinks.BatchSink = (*Sink)(nil)
+
+// New returns a Sink writing to the InfluxDB server at addr, such as
+// "http://localhost:8086".
+func New(addr string) *Sink {
+	return &Sink{addr: strings.TrimSuffix(addr, "/")}
+}
+
+// Emit implements collector.Sink. Points are timestamped with the time the sample was
+// taken, not when its batch is written.
+func (s *Sink) Emit(f collector.Fields) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enc := serializer.InfluxLine{Measurement: s.Measurement, Tags: s.Tags}
+	if err := enc.Encode(&s.batch, f); err != nil {
+		return err
+	}
+	s.pending++
+	if s.pending < s.BatchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+// EmitBatch implements sinks.BatchSink, writing the buffered points along with those of
+// fs in one request.
+func (s *Sink) EmitBatch(fs []collector.Fields) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enc := serializer.InfluxLine{Measurement: s.Measurement, Tags: s.Tags}
+	for _, f := range fs {
+		if err := enc.Encode(&s.batch, f); err != nil {
+			return err
+		}
+		s.pending++
+	}
+	return s.flush()
+}
+
+// Flush writes the buffered points.
+func (s *Sink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// Close writes the buffered points. The Sink must not be used afterwards.
+func (s *Sink) Close() error {
+	return s.Flush()
+}
+
+func (s *Sink) flush() error {
+	if s.pending == 0 {
+		return nil
+	}
+	body := s.batch.Bytes()
+	defer func() {
+		s.batch.Reset()
+		s.pending = 0
+	}()
+
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	if s.Bucket != "" {
+		if s.Token != "" {
+			header.Set("Authorization", "Token "+s.Token)
+		}
+	} else if s.Username != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(s.Username + ":" + s.Password))
+		header.Set("Authorization", "Basic "+auth)
+	}
+
+	p := httppost.Poster{MaxRetries: s.MaxRetries, RetryBackoff: s.RetryBackoff, Client: s.Client}
+	if err := p.Post(s.writeURL(), header, body); err != nil {
+		return fmt.Errorf("influx: write failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Sink) writeURL() string {
+	q := url.Values{"precision": {"ns"}}
+	if s.Bucket != "" {
+		q.Set("bucket", s.Bucket)
+		if s.Org != "" {
+			q.Set("org", s.Org)
+		}
+		return s.addr + "/api/v2/write?" + q.Encode()
+	}
+	q.Set("db", s.Database)
+	if s.RetentionPolicy != "" {
+		q.Set("rp", s.RetentionPolicy)
+	}
+	return s.addr + "/write?" + q.Encode()
+}
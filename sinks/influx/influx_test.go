@@ -0,0 +1,70 @@
+package influx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	var requests int
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/api/v2/write" || r.URL.Query().Get("bucket") != "metrics" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Token secret" {
+			t.Errorf("unexpected authorization %q", got)
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL)
+	s.Bucket, s.Org, s.Token = "metrics", "acme", "secret"
+	s.Tags = map[string]string{"host": "a b"}
+	s.BatchSize = 2
+	s.MaxRetries = 1
+	s.RetryBackoff = time.Millisecond
+
+	if err := s.Emit(collector.Fields{NumGoroutine: 3}); err != nil || requests != 0 {
+		t.Fatalf("expected the first point to be buffered, got %d requests: %v", requests, err)
+	}
+	if err := s.Emit(collector.Fields{NumGoroutine: 4, Tags: map[string]string{"instance": "api"}}); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || len(bodies) != 1 {
+		t.Fatalf("expected one retried write, got %d requests", requests)
+	}
+
+	lines := strings.Split(strings.TrimSpace(bodies[0]), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 points, got %q", bodies[0])
+	}
+	if !strings.HasPrefix(lines[0], `go_runtime_metrics,host=a\ b cpu.cgo_calls=0i,cpu.goroutines=3i,`) {
+		t.Errorf("unexpected point %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `go_runtime_metrics,host=a\ b,instance=api `) {
+		t.Errorf("expected the instance tag, got %q", lines[1])
+	}
+}
+
+func TestWriteURL(t *testing.T) {
+	s := New("http://localhost:8086/")
+	s.Database, s.RetentionPolicy = "telegraf", "autogen"
+	if got, exp := s.writeURL(), "http://localhost:8086/write?db=telegraf&precision=ns&rp=autogen"; got != exp {
+		t.Errorf("unexpected URL:\ngot: %s\nexp: %s", got, exp)
+	}
+}
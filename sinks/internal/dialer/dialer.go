@@ -0,0 +1,123 @@
+// Package dialer connects the sinks that write to TCP and UDP backends, and keeps their
+// long-lived connections pointed at the addresses the backend's hostname resolves to.
+//
+// Addresses are host:port pairs, including IPv6 literals such as "[::1]:8125". TCP
+// connections are dialed with Happy Eyeballs (RFC 6555), so a dual-stack hostname connects
+// over whichever of IPv6 and IPv4 answers first.
+package dialer
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultResolveInterval is how often hostnames are resolved again unless a sink is
+// configured otherwise.
+const DefaultResolveInterval = time.Minute
+
+// lookupHost resolves hostnames, replaced in tests.
+var lookupHost = net.DefaultResolver.LookupHost
+
+// Dial connects to addr on the named network within timeout, or with no timeout if it
+// is zero.
+func Dial(network, addr string, timeout time.Duration) (net.Conn, error) {
+	d := net.Dialer{Timeout: timeout}
+	return d.Dial(network, addr)
+}
+
+// Moved reports whether the hostname of addr no longer resolves to the remote address of
+// conn, meaning it should be dialed again. It reports false for IP literals, Unix sockets
+// and when the hostname cannot be resolved, keeping the current connection.
+func Moved(conn net.Conn, addr string, timeout time.Duration) bool {
+	_, moved := resolve(conn, addr, timeout)
+	return moved
+}
+
+// resolve returns the addresses the hostname of addr resolves to, and whether the remote
+// address of conn is not among them.
+func resolve(conn net.Conn, addr string, timeout time.Duration) ([]string, bool) {
+	if strings.HasPrefix(conn.RemoteAddr().Network(), "unix") {
+		return nil, false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(strings.SplitN(host, "%", 2)[0]) != nil {
+		return nil, false
+	}
+	remote, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return nil, false
+	}
+	remoteIP := net.ParseIP(strings.SplitN(remote, "%", 2)[0])
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	addrs, err := lookupHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return nil, false
+	}
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip != nil && ip.Equal(remoteIP) {
+			return addrs, false
+		}
+	}
+	return addrs, true
+}
+
+// Conn is a datagram connection, such as to a StatsD agent, that is dialed again when the
+// hostname of its address resolves elsewhere.
+type Conn struct {
+	network, addr string
+
+	mu         sync.Mutex
+	conn       net.Conn
+	resolvedAt time.Time
+}
+
+// DialConn returns a Conn to addr on the named network, such as "udp" or "unixgram".
+func DialConn(network, addr string) (*Conn, error) {
+	conn, err := Dial(network, addr, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{network: network, addr: addr, conn: conn, resolvedAt: time.Now()}, nil
+}
+
+// Send writes b as a single datagram. Once every resolveInterval, or DefaultResolveInterval
+// if it is zero, the hostname is resolved again first, and the Conn dialed again if it no
+// longer resolves to the current remote address. A negative resolveInterval never resolves
+// it again.
+func (c *Conn) Send(b []byte, resolveInterval time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if resolveInterval == 0 {
+		resolveInterval = DefaultResolveInterval
+	}
+	if now := time.Now(); resolveInterval > 0 && now.Sub(c.resolvedAt) >= resolveInterval {
+		// Resolve at most once per interval, even if redialing fails.
+		c.resolvedAt = now
+		if addrs, moved := resolve(c.conn, c.addr, time.Second); moved {
+			// Datagrams go to a single address, the first one, as net.Dial would pick.
+			_, port, _ := net.SplitHostPort(c.addr)
+			if conn, err := Dial(c.network, net.JoinHostPort(addrs[0], port), time.Second); err == nil {
+				c.conn.Close()
+				c.conn = conn
+			}
+		}
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.Close()
+}
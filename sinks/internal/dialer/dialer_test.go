@@ -0,0 +1,95 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConn(t *testing.T) {
+	var listeners []net.PacketConn
+	for _, addr := range []string{"127.0.0.1:0", "[::1]:0"} {
+		l, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			t.Skip("IPv6 loopback is not available:", err)
+		}
+		defer l.Close()
+		listeners = append(listeners, l)
+	}
+	_, port, _ := net.SplitHostPort(listeners[0].LocalAddr().String())
+	_, port6, _ := net.SplitHostPort(listeners[1].LocalAddr().String())
+	if port != port6 {
+		// Listen on the same port on both stacks, so that only the address changes.
+		listeners[1].Close()
+		l, err := net.ListenPacket("udp", "[::1]:"+port)
+		if err != nil {
+			t.Skip("cannot listen on the same port over IPv6:", err)
+		}
+		defer l.Close()
+		listeners[1] = l
+	}
+
+	resolved := "127.0.0.1"
+	lookupHost = func(ctx context.Context, host string) ([]string, error) { return []string{resolved}, nil }
+	defer func() { lookupHost = net.DefaultResolver.LookupHost }()
+
+	c, err := DialConn("udp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.addr = net.JoinHostPort("backend.example", port)
+	defer c.Close()
+
+	read := func(l net.PacketConn) string {
+		buf := make([]byte, 64)
+		l.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := l.ReadFrom(buf)
+		if err != nil {
+			return err.Error()
+		}
+		return string(buf[:n])
+	}
+
+	c.Send([]byte("a"), time.Hour)
+	if got := read(listeners[0]); got != "a" {
+		t.Fatalf("expected a datagram over IPv4, got %q", got)
+	}
+
+	resolved = "::1"
+	c.Send([]byte("b"), time.Hour)
+	if got := read(listeners[0]); got != "b" {
+		t.Fatalf("expected the address to be kept until the interval elapsed, got %q", got)
+	}
+	c.Send([]byte("c"), time.Nanosecond)
+	if got := read(listeners[1]); got != "c" {
+		t.Fatalf("expected a datagram over IPv6 once the hostname moved, got %q", got)
+	}
+}
+
+func TestMoved(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conn, err := Dial("tcp", l.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	lookupHost = func(ctx context.Context, host string) ([]string, error) { return []string{"::1", "127.0.0.1"}, nil }
+	defer func() { lookupHost = net.DefaultResolver.LookupHost }()
+	if Moved(conn, "backend.example:2003", 0) {
+		t.Error("expected a connection to one of the resolved addresses to be kept")
+	}
+	if Moved(conn, "10.0.0.1:2003", 0) {
+		t.Error("expected IP literals never to move")
+	}
+
+	lookupHost = func(ctx context.Context, host string) ([]string, error) { return []string{"10.0.0.2"}, nil }
+	if !Moved(conn, "backend.example:2003", 0) {
+		t.Error("expected the connection to have moved")
+	}
+}
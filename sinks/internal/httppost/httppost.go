@@ -0,0 +1,76 @@
+// Package httppost sends the HTTP requests of the sinks that write to HTTP endpoints,
+// retrying the failures that are likely to be transient.
+package httppost
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds each request of a Poster without a Client, so that an endpoint
+// that stops answering does not hold up the Collector emitting to it.
+const DefaultTimeout = 10 * time.Second
+
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// Poster posts request bodies to an endpoint.
+type Poster struct {
+	// MaxRetries is the number of times a failed request is retried. Only network
+	// failures, 429 and 5xx responses are retried.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubling with each attempt.
+	// Defaults to one second.
+	RetryBackoff time.Duration
+
+	// Client sends the requests. Defaults to a client timing out after DefaultTimeout.
+	Client *http.Client
+}
+
+// Post sends body to url with the given headers, retrying as configured.
+func (p Poster) Post(url string, header http.Header, body []byte) error {
+	backoff := p.RetryBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := p.post(url, header, body)
+		if err == nil || !retry || attempt >= p.MaxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends a single request and reports whether a failure may be retried.
+func (p Poster) post(url string, header http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	client := p.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
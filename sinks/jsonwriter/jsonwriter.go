@@ -0,0 +1,73 @@
+// Package jsonwriter writes collector.Fields as newline delimited JSON to an io.Writer
+// such as stdout, a log file or a pipe, for log-based metrics pipelines:
+//
+//	{"time":"2024-05-01T12:00:00.000000001Z","tags":{"instance":"a"},"fields":{"cpu.goroutines":12,...}}
+//
+// Lines carry the ID of their sample as an "id" member when the Collector sets IDFunc.
+package jsonwriter
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// Sink writes every sample as a single line of JSON.
+type Sink struct {
+	// TimeFormat is the layout of the "time" member. Defaults to time.RFC3339Nano.
+	TimeFormat string
+
+	// Uint64 writes the fields read from runtime.MemStats with full uint64 precision, as
+	// given by collector.Fields.ToMapU64.
+	Uint64 bool
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// New returns a Sink writing to w. Each line is written with a single call to Write, so
+// lines are not interleaved with those of other writers on an O_APPEND file or a pipe.
+func New(w io.Writer) *Sink {
+	return &Sink{w: w}
+}
+
+type line struct {
+	ID     string                 `json:"id,omitempty"`
+	Time   string                 `json:"time"`
+	Tags   map[string]string      `json:"tags,omitempty"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+func fields(f *collector.Fields, u64 bool) map[string]interface{} {
+	if u64 {
+		return f.ToMapU64()
+	}
+	return f.ToMap()
+}
+
+// Emit implements collector.Sink.
+func (s *Sink) Emit(f collector.Fields) error {
+	layout := s.TimeFormat
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	b, err := json.Marshal(line{
+		ID:     f.ID,
+		Time:   f.Time().UTC().Format(layout),
+		Tags:   f.Tags,
+		Fields: fields(&f, s.Uint64),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,38 @@
+package jsonwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := New(buf)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 1, time.UTC)
+	s.Emit(collector.Fields{NumGoroutine: 12, Timestamp: at.UnixNano(), Tags: map[string]string{"instance": "a"}})
+	s.Emit(collector.Fields{NumGoroutine: 13, Timestamp: at.UnixNano()})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	var got struct {
+		Time   string
+		Tags   map[string]string
+		Fields map[string]float64
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Time != "2024-05-01T12:00:00.000000001Z" || got.Tags["instance"] != "a" || got.Fields["cpu.goroutines"] != 12 {
+		t.Errorf("unexpected line %s", lines[0])
+	}
+	if strings.Contains(lines[1], `"tags"`) {
+		t.Errorf("expected no tags on an untagged sample, got %s", lines[1])
+	}
+}
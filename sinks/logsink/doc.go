@@ -0,0 +1,9 @@
+// Package logsink logs collector.Fields as structured log records, for small services that
+// want their runtime metrics in their logs rather than in a metrics system. Sink logs
+// through log/slog, and ZapSink through a zap SugaredLogger:
+//
+//	c := collector.New(nil, collector.WithSink(logsink.New(slog.Default())))
+//
+// Every field is an attribute named after the field, and the tags of the sample are
+// grouped under "tags". The package requires Go 1.21 or later.
+package logsink
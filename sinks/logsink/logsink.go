@@ -0,0 +1,136 @@
+//go:build go1.21
+
+package logsink
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// DefaultMessage is the message of the records unless Message is set.
+const DefaultMessage = "runtime metrics"
+
+// Sink logs every sample as a single slog record.
+type Sink struct {
+	// Level is the level of the records. Defaults to slog.LevelInfo.
+	Level slog.Level
+
+	// Message is the message of the records. Defaults to DefaultMessage.
+	Message string
+
+	l *slog.Logger
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// New returns a Sink logging to l, or to slog.Default() if l is nil.
+func New(l *slog.Logger) *Sink {
+	return &Sink{l: l}
+}
+
+// Emit implements collector.Sink. The record is timestamped with the time of the sample,
+// and not built at all if the handler does not log records of Level.
+func (s *Sink) Emit(f collector.Fields) error {
+	l := s.l
+	if l == nil {
+		l = slog.Default()
+	}
+	ctx := context.Background()
+	h := l.Handler()
+	if !h.Enabled(ctx, s.Level) {
+		return nil
+	}
+
+	r := slog.NewRecord(f.Time(), s.Level, message(s.Message), 0)
+	m := f.ToMap()
+	for _, name := range sortedNames(m) {
+		r.AddAttrs(slog.Any(name, m[name]))
+	}
+	if len(f.Tags) > 0 {
+		tags := make([]interface{}, 0, len(f.Tags))
+		for _, k := range sortedTags(f.Tags) {
+			tags = append(tags, slog.String(k, f.Tags[k]))
+		}
+		r.AddAttrs(slog.Group("tags", tags...))
+	}
+	return h.Handle(ctx, r)
+}
+
+// SugaredLogger is implemented by *zap.SugaredLogger, so that ZapSink does not depend on zap.
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// ZapSink logs every sample as a single zap entry.
+type ZapSink struct {
+	// Level is the level of the entries, mapped to the closest zap level at or below it.
+	// Defaults to slog.LevelInfo.
+	Level slog.Level
+
+	// Message is the message of the entries. Defaults to DefaultMessage.
+	Message string
+
+	l SugaredLogger
+}
+
+var _ collector.Sink = (*ZapSink)(nil)
+
+// NewZap returns a ZapSink logging to l, such as zap.L().Sugar().
+func NewZap(l SugaredLogger) *ZapSink {
+	return &ZapSink{l: l}
+}
+
+// Emit implements collector.Sink. The tags of the sample are logged as a map.
+func (s *ZapSink) Emit(f collector.Fields) error {
+	m := f.ToMap()
+	kvs := make([]interface{}, 0, 2*len(m)+2)
+	for _, name := range sortedNames(m) {
+		kvs = append(kvs, name, m[name])
+	}
+	if len(f.Tags) > 0 {
+		kvs = append(kvs, "tags", f.Tags)
+	}
+
+	log := s.l.Infow
+	switch {
+	case s.Level >= slog.LevelError:
+		log = s.l.Errorw
+	case s.Level >= slog.LevelWarn:
+		log = s.l.Warnw
+	case s.Level < slog.LevelInfo:
+		log = s.l.Debugw
+	}
+	log(message(s.Message), kvs...)
+	return nil
+}
+
+func message(msg string) string {
+	if msg == "" {
+		return DefaultMessage
+	}
+	return msg
+}
+
+func sortedNames(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedTags(tags map[string]string) []string {
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
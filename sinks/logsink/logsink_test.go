@@ -0,0 +1,62 @@
+//go:build go1.21
+
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := New(slog.New(slog.NewJSONHandler(buf, nil)))
+	s.Level, s.Message = slog.LevelWarn, "stats"
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.Emit(collector.Fields{NumGoroutine: 12, Timestamp: at.UnixNano(), Tags: map[string]string{"instance": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["time"] != "2024-05-01T12:00:00Z" || got["level"] != "WARN" || got["msg"] != "stats" ||
+		got["cpu.goroutines"] != float64(12) || got["tags"].(map[string]interface{})["instance"] != "a" {
+		t.Errorf("unexpected record %s", buf.String())
+	}
+
+	buf.Reset()
+	s.Level = slog.LevelDebug
+	s.Emit(collector.Fields{})
+	if buf.Len() != 0 {
+		t.Errorf("expected no record below the handler's level, got %s", buf.String())
+	}
+}
+
+type zapLogger []string
+
+func (z *zapLogger) log(level, msg string, kvs []interface{}) {
+	*z = append(*z, fmt.Sprint(level, " ", msg, " ", kvs[:4]))
+}
+func (z *zapLogger) Debugw(msg string, kvs ...interface{}) { z.log("debug", msg, kvs) }
+func (z *zapLogger) Infow(msg string, kvs ...interface{})  { z.log("info", msg, kvs) }
+func (z *zapLogger) Warnw(msg string, kvs ...interface{})  { z.log("warn", msg, kvs) }
+func (z *zapLogger) Errorw(msg string, kvs ...interface{}) { z.log("error", msg, kvs) }
+
+func TestZapSink(t *testing.T) {
+	z := &zapLogger{}
+	s := NewZap(z)
+	s.Emit(collector.Fields{NumGoroutine: 12})
+	s.Level = slog.LevelError
+	s.Emit(collector.Fields{})
+
+	if len(*z) != 2 || (*z)[0] != "info runtime metrics [cpu.cgo_calls 0 cpu.goroutines 12]" || (*z)[1][:5] != "error" {
+		t.Errorf("unexpected entries %q", *z)
+	}
+}
@@ -0,0 +1,130 @@
+// Package pagerduty raises and resolves PagerDuty incidents from collector events through
+// the Events API v2.
+package pagerduty
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/httppost"
+)
+
+// DefaultURL is the endpoint of the Events API v2.
+const DefaultURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Sink triggers an alert for every firing event and resolves it with the matching
+// resolved event. Both share a dedup key, so repeated firing events update the open
+// alert rather than opening new ones.
+type Sink struct {
+	// RoutingKey is the integration key of the PagerDuty service.
+	RoutingKey string
+
+	// URL is the Events API endpoint. Defaults to DefaultURL.
+	URL string
+
+	// Source identifies the affected system. Defaults to the hostname.
+	Source string
+
+	// Severity is one of "critical", "error", "warning" or "info". Defaults to "error".
+	Severity string
+
+	// MaxRetries is the number of times a failed request is retried. Defaults to 0.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubling with each attempt.
+	// Defaults to one second.
+	RetryBackoff time.Duration
+
+	// Client sends the requests. Defaults to a client timing out after 10 seconds.
+	Client *http.Client
+}
+
+var _ collector.EventSink = (*Sink)(nil)
+
+// New returns a Sink sending to the service with the given integration key.
+func New(routingKey string) *Sink {
+	return &Sink{RoutingKey: routingKey}
+}
+
+type payload struct {
+	Summary       string           `json:"summary"`
+	Source        string           `json:"source"`
+	Severity      string           `json:"severity"`
+	Timestamp     string           `json:"timestamp,omitempty"`
+	Component     string           `json:"component,omitempty"`
+	Class         string           `json:"class,omitempty"`
+	CustomDetails *collector.Event `json:"custom_details,omitempty"`
+}
+
+type event struct {
+	RoutingKey  string   `json:"routing_key"`
+	EventAction string   `json:"event_action"`
+	DedupKey    string   `json:"dedup_key"`
+	Payload     *payload `json:"payload,omitempty"`
+}
+
+// EmitEvent implements collector.EventSink.
+func (s *Sink) EmitEvent(e collector.Event) error {
+	ev := event{RoutingKey: s.RoutingKey, EventAction: "resolve", DedupKey: DedupKey(e)}
+	if !e.Resolved {
+		ev.EventAction = "trigger"
+		ev.Payload = &payload{
+			Summary:       e.Message,
+			Source:        s.source(),
+			Severity:      s.Severity,
+			Component:     e.Instance,
+			Class:         e.Kind,
+			CustomDetails: &e,
+		}
+		if ev.Payload.Severity == "" {
+			ev.Payload.Severity = "error"
+		}
+		if !e.Time.IsZero() {
+			ev.Payload.Timestamp = e.Time.UTC().Format(time.RFC3339)
+		}
+	}
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	url := s.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	p := httppost.Poster{MaxRetries: s.MaxRetries, RetryBackoff: s.RetryBackoff, Client: s.Client}
+	if err := p.Post(url, http.Header{"Content-Type": {"application/json"}}, body); err != nil {
+		return fmt.Errorf("pagerduty: enqueue failed: %w", err)
+	}
+	return nil
+}
+
+// DedupKey returns the key shared by the firing and resolved events of the same alert,
+// made of their Kind, Instance, Name and Field.
+func DedupKey(e collector.Event) string {
+	key := "go-runtime-metrics/" + e.Kind
+	if e.Instance != "" {
+		key += "/" + e.Instance
+	}
+	if e.Name != "" {
+		key += "/" + e.Name
+	}
+	if e.Field != "" {
+		key += "/" + e.Field
+	}
+	return key
+}
+
+func (s *Sink) source() string {
+	if s.Source != "" {
+		return s.Source
+	}
+	if hn, err := os.Hostname(); err == nil {
+		return hn
+	}
+	return "unknown"
+}
@@ -0,0 +1,72 @@
+package pagerduty
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	var got []event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev event
+		json.NewDecoder(r.Body).Decode(&ev)
+		got = append(got, ev)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s := New("key")
+	s.URL = srv.URL
+	s.Source = "host-1"
+	e := collector.Event{Kind: "memory_limit", Instance: "api", Message: "almost out"}
+	if err := s.EmitEvent(e); err != nil {
+		t.Fatal(err)
+	}
+	e.Resolved = true
+	if err := s.EmitEvent(e); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(got))
+	}
+	trigger, resolve := got[0], got[1]
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "key" || trigger.Payload == nil ||
+		trigger.Payload.Summary != "almost out" || trigger.Payload.Severity != "error" || trigger.Payload.Source != "host-1" {
+		t.Errorf("unexpected trigger %+v", trigger)
+	}
+	if resolve.EventAction != "resolve" || resolve.Payload != nil || resolve.DedupKey != trigger.DedupKey {
+		t.Errorf("expected a resolve with the trigger's dedup key, got %+v", resolve)
+	}
+}
+
+func TestDedupKeyOfAlerts(t *testing.T) {
+	var events []collector.Event
+	depth := 20.0
+	rule := collector.Rule{Field: "app.queue_depth", Kind: collector.Max, Limit: 10}
+	c := collector.New(nil, collector.WithAlert(&collector.Alert{Name: "queue", Rule: rule}),
+		collector.WithAlert(&collector.Alert{Name: "queue_page", Rule: rule}),
+		collector.WithEventSink(collector.EventSinkFunc(func(e collector.Event) error {
+			events = append(events, e)
+			return nil
+		})))
+	c.RegisterGauge("app.queue_depth", func() float64 { return depth })
+	c.OneOff()
+	depth = 5
+	c.OneOff()
+
+	if len(events) != 4 {
+		t.Fatalf("expected two triggers and two resolves, got %+v", events)
+	}
+	if DedupKey(events[0]) != DedupKey(events[2]) || DedupKey(events[1]) != DedupKey(events[3]) {
+		t.Errorf("expected the resolves to share the key of their trigger, got %q, %q, %q and %q",
+			DedupKey(events[0]), DedupKey(events[1]), DedupKey(events[2]), DedupKey(events[3]))
+	}
+	if DedupKey(events[0]) == DedupKey(events[1]) {
+		t.Errorf("expected alerts on the same field to have distinct keys, got %q", DedupKey(events[0]))
+	}
+}
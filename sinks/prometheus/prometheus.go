@@ -0,0 +1,101 @@
+// Package prometheus exposes collector.Fields in the Prometheus text exposition format,
+// without depending on the Prometheus client library. Fields are exposed with the help
+// text and type given by collector.Describe, counters with the conventional _total suffix.
+//
+// Either push samples into an Exporter from a Collector's FieldsFunc and mount it on a
+// mux, or serve Handler to collect a fresh sample on every scrape:
+//
+//	http.Handle("/metrics", prometheus.Handler(collector.New(nil), "go"))
+package prometheus
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/schema"
+	"github.com/tevjef/go-runtime-metrics/serializer"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter serves the latest sample passed to Emit.
+type Exporter struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+
+	mu     sync.RWMutex
+	latest *collector.Fields
+}
+
+var _ collector.Sink = (*Exporter)(nil)
+
+// New returns an Exporter using the given metric namespace.
+func New(namespace string) *Exporter {
+	return &Exporter{Namespace: namespace}
+}
+
+// Emit stores f as the sample served on the next scrape.
+func (e *Exporter) Emit(f collector.Fields) error {
+	e.mu.Lock()
+	e.latest = &f
+	e.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP implements http.Handler.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	latest := e.latest
+	e.mu.RUnlock()
+
+	if latest == nil {
+		http.Error(w, "no sample collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	write(w, e.Namespace, *latest)
+}
+
+// Handler returns an http.Handler that collects a fresh sample from c on every scrape.
+func Handler(c *collector.Collector, namespace string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, namespace, c.OneOff())
+	})
+}
+
+func write(w http.ResponseWriter, namespace string, f collector.Fields) {
+	w.Header().Set("Content-Type", contentType)
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	labels := serializer.Labels(f.Tags)
+	m := f.ToMap()
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, field := range names {
+		info, ok := collector.DescribeField(field)
+		if !ok {
+			info.Type = collector.Gauge
+		}
+		name := schema.PrometheusName(namespace, info)
+		if info.Help != "" {
+			bw.WriteString("# HELP " + name + " " + info.Help + "\n")
+		}
+		bw.WriteString("# TYPE " + name + " " + string(info.Type) + "\n")
+		bw.WriteString(name + labels + " ")
+		switch v := m[field].(type) {
+		case int64:
+			bw.WriteString(strconv.FormatInt(v, 10))
+		case float64:
+			bw.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		bw.WriteByte('\n')
+	}
+}
@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestExporter(t *testing.T) {
+	e := New("go")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the first sample, got %d", rec.Code)
+	}
+
+	e.Emit(collector.Fields{NumGoroutine: 4, NumGC: 2, GCCPUFraction: 0.25})
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, exp := range []string{
+		"# TYPE go_cpu_goroutines gauge\ngo_cpu_goroutines 4\n",
+		"# TYPE go_mem_gc_count_total counter\ngo_mem_gc_count_total 2\n",
+		"go_mem_gc_cpu_fraction 0.25\n",
+	} {
+		if !strings.Contains(body, exp) {
+			t.Errorf("expected output to contain %q", exp)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler(collector.New(nil), "").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.Contains(rec.Body.String(), "\ncpu_goroutines ") {
+		t.Errorf("expected a fresh sample, got:\n%s", rec.Body.String())
+	}
+}
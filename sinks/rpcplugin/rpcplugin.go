@@ -0,0 +1,160 @@
+// Package rpcplugin runs custom sinks and field providers as separate executables, so
+// they can pull in whatever dependencies they need without the host binary linking them.
+//
+// A plugin is a program that calls Serve with its implementation. The host starts it with
+// Launch, adds it to a Collector with Register and talks to it with net/rpc over the
+// plugin's standard input and output, which means plugins must log to standard error.
+//
+//	package main
+//
+//	import "github.com/tevjef/go-runtime-metrics/sinks/rpcplugin"
+//
+//	func main() {
+//	    rpcplugin.Serve(&mySink{})
+//	}
+package rpcplugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+	"os/exec"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// Plugin is implemented by sink plugins.
+type Plugin interface {
+	Emit(collector.Fields) error
+}
+
+// Provider is optionally implemented by plugins that contribute additional fields.
+type Provider interface {
+	Collect() (map[string]float64, error)
+}
+
+// ErrNotProvider is returned by Sink.Collect when the plugin does not implement Provider.
+var ErrNotProvider = errors.New("rpcplugin: plugin does not provide fields")
+
+// Empty is the argument and reply of RPC methods that carry no data.
+type Empty struct{}
+
+// Service is the RPC contract between host and plugin. It is exported for net/rpc and not
+// meant to be used directly.
+type Service struct {
+	p Plugin
+}
+
+// Emit passes f to the plugin.
+func (s *Service) Emit(f collector.Fields, _ *Empty) error {
+	return s.p.Emit(f)
+}
+
+// Collect gathers the fields provided by the plugin.
+func (s *Service) Collect(_ Empty, reply *map[string]float64) error {
+	p, ok := s.p.(Provider)
+	if !ok {
+		return ErrNotProvider
+	}
+	m, err := p.Collect()
+	*reply = m
+	return err
+}
+
+// Serve serves p to the host over standard input and output. It returns once the host
+// closes the connection.
+func Serve(p Plugin) {
+	srv := rpc.NewServer()
+	srv.RegisterName("Plugin", &Service{p: p})
+	srv.ServeConn(stdio{os.Stdin, os.Stdout})
+}
+
+// Sink is the host side of a running plugin.
+type Sink struct {
+	cmd    *exec.Cmd
+	client *rpc.Client
+
+	// registered is the Collector the provided fields were registered with, under gauges.
+	registered *collector.Collector
+	gauges     string
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// Launch starts the plugin executable at path and returns a Sink forwarding samples to it.
+func Launch(path string, args ...string) (*Sink, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &Sink{cmd: cmd, client: rpc.NewClient(stdio{stdout, stdin})}, nil
+}
+
+// Emit passes f to the plugin.
+func (s *Sink) Emit(f collector.Fields) error {
+	return s.client.Call("Plugin.Emit", f, &Empty{})
+}
+
+// Collect returns the fields provided by the plugin, or ErrNotProvider.
+func (s *Sink) Collect() (map[string]float64, error) {
+	var m map[string]float64
+	err := s.client.Call("Plugin.Collect", Empty{}, &m)
+	if err != nil && err.Error() == ErrNotProvider.Error() {
+		err = ErrNotProvider
+	}
+	return m, err
+}
+
+// Register adds s to the sinks of c and, if the plugin implements Provider, the fields it
+// provides to every sample c collects. The plugin is asked for them during the
+// collection, so it sees the samples up to the previous one.
+func (s *Sink) Register(c *collector.Collector) error {
+	_, err := s.Collect()
+	switch err {
+	case nil:
+		s.registered, s.gauges = c, fmt.Sprintf("rpcplugin %s %d", s.cmd.Path, s.cmd.Process.Pid)
+		c.RegisterGauges(s.gauges, s.Collect)
+	case ErrNotProvider:
+	default:
+		return err
+	}
+	c.AddSink(s)
+	return nil
+}
+
+// Close disconnects from the plugin and waits for it to exit. The fields it provided are
+// no longer collected.
+func (s *Sink) Close() error {
+	if s.registered != nil {
+		s.registered.UnregisterGauges(s.gauges)
+	}
+	err := s.client.Close()
+	if werr := s.cmd.Wait(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+type stdio struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (s stdio) Close() error {
+	err := s.WriteCloser.Close()
+	if rerr := s.ReadCloser.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package rpcplugin
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+// The test binary doubles as the plugin when started by the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("RPCPLUGIN_TEST") == "1" {
+		Serve(&testPlugin{})
+		return
+	}
+	os.Exit(m.Run())
+}
+
+type testPlugin struct {
+	last   int64
+	fields int
+}
+
+func (p *testPlugin) Emit(f collector.Fields) error {
+	if f.NumGoroutine < 0 {
+		return errors.New("negative goroutines")
+	}
+	p.last, p.fields = f.NumGoroutine, len(f.ToMap())
+	return nil
+}
+
+func (p *testPlugin) Collect() (map[string]float64, error) {
+	return map[string]float64{"plugin.last": float64(p.last), "plugin.fields": float64(p.fields)}, nil
+}
+
+func TestPlugin(t *testing.T) {
+	os.Setenv("RPCPLUGIN_TEST", "1")
+	s, err := Launch(os.Args[0])
+	os.Unsetenv("RPCPLUGIN_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Emit(collector.Fields{NumGoroutine: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Emit(collector.Fields{NumGoroutine: -1}); err == nil {
+		t.Error("expected the plugin error to be returned")
+	}
+
+	m, err := s.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := m["plugin.last"]; v != 7 {
+		t.Errorf("expected plugin.last=7, got %v", v)
+	}
+
+	// The plugin gets the fields the Collector emits, not every field it collected.
+	f := collector.New(nil, collector.WithFields("cpu.goroutines")).OneOff()
+	if err := s.Emit(f); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := s.Collect(); m["plugin.fields"] != 1 {
+		t.Errorf("expected the plugin to get the one allowed field, got %v", m["plugin.fields"])
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	os.Setenv("RPCPLUGIN_TEST", "1")
+	s, err := Launch(os.Args[0])
+	os.Unsetenv("RPCPLUGIN_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	c := collector.New(nil, collector.WithErrorFunc(func(err error) { errs = append(errs, err) }))
+	if err := s.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	first := c.OneOff()
+	second := c.OneOff()
+	if v, ok := second.Extra["plugin.last"]; !ok || v != float64(first.NumGoroutine) {
+		t.Errorf("expected the plugin to provide the goroutines of the previous sample %d, got %v", first.NumGoroutine, second.Extra)
+	}
+	if _, ok := second.ToMap()["plugin.fields"]; !ok {
+		t.Errorf("expected the provided fields in the sample, got %v", second.ToMap())
+	}
+
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	if f := c.OneOff(); f.Extra["plugin.last"] != 0 {
+		t.Errorf("expected the fields to be gone once the plugin is closed, got %v", f.Extra)
+	}
+}
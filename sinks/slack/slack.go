@@ -0,0 +1,100 @@
+// Package slack posts collector events to a Slack channel through an incoming webhook.
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/httppost"
+)
+
+// Sink posts every event it receives as a Slack message.
+type Sink struct {
+	// WebhookURL is the incoming webhook the messages are posted to.
+	WebhookURL string
+
+	// MaxRetries is the number of times a failed post is retried. Defaults to 0.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubling with each attempt.
+	// Defaults to one second.
+	RetryBackoff time.Duration
+
+	// Client sends the requests. Defaults to a client timing out after 10 seconds.
+	Client *http.Client
+}
+
+var _ collector.EventSink = (*Sink)(nil)
+
+// New returns a Sink posting to the incoming webhook at webhookURL.
+func New(webhookURL string) *Sink {
+	return &Sink{WebhookURL: webhookURL}
+}
+
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type block struct {
+	Type     string `json:"type"`
+	Text     *text  `json:"text,omitempty"`
+	Elements []text `json:"elements,omitempty"`
+}
+
+type message struct {
+	// Text is shown in notifications and by clients that do not render blocks.
+	Text   string  `json:"text"`
+	Blocks []block `json:"blocks"`
+}
+
+// EmitEvent implements collector.EventSink.
+func (s *Sink) EmitEvent(e collector.Event) error {
+	body, err := json.Marshal(format(e))
+	if err != nil {
+		return err
+	}
+	p := httppost.Poster{MaxRetries: s.MaxRetries, RetryBackoff: s.RetryBackoff, Client: s.Client}
+	header := http.Header{"Content-Type": {"application/json"}}
+	if err := p.Post(s.WebhookURL, header, body); err != nil {
+		return fmt.Errorf("slack: post failed: %w", err)
+	}
+	return nil
+}
+
+// format renders e as a message with a headline and a context line of the measurement.
+func format(e collector.Event) message {
+	status := ":rotating_light: *Firing*"
+	if e.Resolved {
+		status = ":white_check_mark: *Resolved*"
+	}
+	subject := e.Kind
+	if e.Instance != "" {
+		subject = e.Instance + " " + subject
+	}
+	headline := fmt.Sprintf("%s `%s`: %s", status, subject, e.Message)
+
+	context := []text{{Type: "mrkdwn", Text: "<!date^" + strconv.FormatInt(e.Time.Unix(), 10) +
+		"^{date_short_pretty} {time_secs}|" + e.Time.UTC().Format(time.RFC3339) + ">"}}
+	if e.Field != "" {
+		context = append(context, text{Type: "mrkdwn", Text: "`" + e.Field + "`"})
+	}
+	if e.Value != 0 || e.Threshold != 0 {
+		context = append(context, text{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("value *%v*, threshold *%v*", e.Value, e.Threshold),
+		})
+	}
+
+	return message{
+		Text: headline,
+		Blocks: []block{
+			{Type: "section", Text: &text{Type: "mrkdwn", Text: headline}},
+			{Type: "context", Elements: context},
+		},
+	}
+}
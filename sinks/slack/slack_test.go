@@ -0,0 +1,39 @@
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	var got message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	e := collector.Event{Kind: "memory_limit", Instance: "api", Message: "almost out", Time: time.Unix(0, 0), Value: 95, Threshold: 100}
+	if err := New(srv.URL).EmitEvent(e); err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != ":rotating_light: *Firing* `api memory_limit`: almost out" {
+		t.Errorf("unexpected text %q", got.Text)
+	}
+	if len(got.Blocks) != 2 || len(got.Blocks[1].Elements) != 2 || !strings.Contains(got.Blocks[1].Elements[1].Text, "*95*") {
+		t.Errorf("unexpected blocks %+v", got.Blocks)
+	}
+
+	e.Resolved = true
+	if err := New(srv.URL).EmitEvent(e); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.Text, ":white_check_mark: *Resolved*") {
+		t.Errorf("expected a resolve message, got %q", got.Text)
+	}
+}
@@ -0,0 +1,324 @@
+// Package spool buffers the samples a sink fails to write in a file on disk, and replays
+// them in order once the sink writes again, so that a short outage of the backend, or a
+// restart of the process during one, does not leave a gap in the data:
+//
+//	s, err := spool.New(influx.New("http://influx:8086"), "/var/lib/app/metrics.spool")
+//
+// The file is append-only, each sample framed with its length and checksum, and is
+// truncated once it has been replayed. A process stopping during a replay replays it again
+// from the start, sending some samples twice, unless the Sink is created with NewAcked,
+// which resumes after the last sample the sink accepted.
+package spool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks"
+)
+
+// DefaultMaxBytes is the size the spool file may grow to when MaxBytes is not set.
+const DefaultMaxBytes = 64 << 20
+
+// DefaultReplayBatch is the number of samples replayed at once to a sinks.BatchSink when
+// ReplayBatch is not set.
+const DefaultReplayBatch = 100
+
+// headerSize is the size of the length and checksum preceding every record.
+const headerSize = 8
+
+// ackSize is the size of the acknowledgment file: an offset and its checksum.
+const ackSize = 12
+
+// Sink writes to a collector.Sink, spooling the samples it fails to write.
+type Sink struct {
+	// MaxBytes is the size the spool file may grow to. Once reached, the samples that fail
+	// to be written are dropped, the spooled ones being kept for replay. Defaults to
+	// DefaultMaxBytes.
+	MaxBytes int64
+
+	// ReplayBatch is the number of samples replayed with a single call to EmitBatch when
+	// the sink is a sinks.BatchSink. Defaults to DefaultReplayBatch.
+	ReplayBatch int
+
+	sink collector.Sink
+
+	mu      sync.Mutex
+	file    *os.File
+	ack     *os.File // nil unless created with NewAcked
+	size    int64    // bytes in the file
+	offset  int64    // bytes of the file replayed
+	pending int      // records not replayed
+	dropped int64
+	rate    float64
+}
+
+var _ collector.BufferedSink = (*Sink)(nil)
+
+// New returns a Sink writing to s and spooling to the file at path. Samples a previous
+// process left in the file are replayed first, and a record it was writing when it
+// stopped is discarded.
+func New(s collector.Sink, path string) (*Sink, error) {
+	return open(s, path, false)
+}
+
+// NewAcked is like New, but commits the offset of the replayed samples to the file at
+// path+".ack" as soon as the sink accepts them, so that a process stopping during a replay
+// does not send them again: backends adding up counters, or receiving large batches, get
+// each sample once unless the process stops between the sink accepting it and the offset
+// being written. The offset is not synced to disk, which only protects against the
+// process stopping, not the host.
+func NewAcked(s collector.Sink, path string) (*Sink, error) {
+	return open(s, path, true)
+}
+
+func open(s collector.Sink, path string, acked bool) (*Sink, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	sp := &Sink{sink: s, file: f}
+	if acked {
+		if sp.ack, err = os.OpenFile(path+".ack", os.O_RDWR|os.O_CREATE, 0o644); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := sp.recover(); err != nil {
+		sp.closeFiles()
+		return nil, fmt.Errorf("spool: reading %s: %w", path, err)
+	}
+	return sp, nil
+}
+
+// recover counts the records of the file, truncating it after the last intact one, and
+// skips those acknowledged.
+func (s *Sink) recover() error {
+	acked := s.readAck()
+	for {
+		if s.size == acked {
+			s.offset, s.pending = acked, 0
+		}
+		_, n, err := s.read(s.size)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, errCorrupt) {
+				break
+			}
+			return err
+		}
+		s.size += n
+		s.pending++
+	}
+	if s.offset > 0 && s.pending == 0 {
+		// Every record was acknowledged but the process stopped before truncating.
+		return s.reset()
+	}
+	return s.file.Truncate(s.size)
+}
+
+// readAck returns the acknowledged offset, or 0 if there is none or it is corrupt. An
+// offset that is not at the start of a record is ignored by recover.
+func (s *Sink) readAck() int64 {
+	if s.ack == nil {
+		return 0
+	}
+	var b [ackSize]byte
+	if _, err := s.ack.ReadAt(b[:], 0); err != nil {
+		return 0
+	}
+	if crc32.ChecksumIEEE(b[:8]) != binary.BigEndian.Uint32(b[8:]) {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b[:8]))
+}
+
+// commit acknowledges the records before the replay offset.
+func (s *Sink) commit() error {
+	if s.ack == nil {
+		return nil
+	}
+	var b [ackSize]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(s.offset))
+	binary.BigEndian.PutUint32(b[8:], crc32.ChecksumIEEE(b[:8]))
+	_, err := s.ack.WriteAt(b[:], 0)
+	return err
+}
+
+var errCorrupt = errors.New("spool: corrupt record")
+
+// read decodes the record at off, returning it along with its size.
+func (s *Sink) read(off int64) (collector.Fields, int64, error) {
+	var header [headerSize]byte
+	if _, err := s.file.ReadAt(header[:], off); err != nil {
+		if errors.Is(err, io.EOF) && off > 0 {
+			// A partial header is the end of a record that was being written.
+			return collector.Fields{}, 0, errCorrupt
+		}
+		return collector.Fields{}, 0, err
+	}
+	n := binary.BigEndian.Uint32(header[:4])
+	body := make([]byte, n)
+	if _, err := s.file.ReadAt(body, off+headerSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return collector.Fields{}, 0, errCorrupt
+		}
+		return collector.Fields{}, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:]) {
+		return collector.Fields{}, 0, errCorrupt
+	}
+	var f collector.Fields
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&f); err != nil {
+		return collector.Fields{}, 0, errCorrupt
+	}
+	return f, headerSize + int64(n), nil
+}
+
+// Emit implements collector.Sink. Spooled samples are replayed before f, and f is spooled
+// if it cannot be written, or if spooled samples remain. In both cases the error of the
+// sink is returned.
+func (s *Sink) Emit(f collector.Fields) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.replay(); err != nil {
+		return s.spool(f, err)
+	}
+	if err := s.sink.Emit(f); err != nil {
+		return s.spool(f, err)
+	}
+	return nil
+}
+
+// replay writes the spooled samples in order, stopping at the first failure.
+func (s *Sink) replay() error {
+	if s.pending == 0 {
+		return nil
+	}
+	start, replayed := time.Now(), 0
+	defer func() {
+		if d := time.Since(start).Seconds(); replayed > 0 && d > 0 {
+			s.rate = float64(replayed) / d
+		}
+	}()
+	bs, _ := s.sink.(sinks.BatchSink)
+	batchSize := s.ReplayBatch
+	if bs == nil || batchSize <= 0 {
+		batchSize = DefaultReplayBatch
+	}
+	var batch []collector.Fields
+	for s.offset < s.size {
+		batch = batch[:0]
+		end := s.offset
+		for end < s.size && (len(batch) == 0 || bs != nil && len(batch) < batchSize) {
+			f, n, err := s.read(end)
+			if err != nil {
+				return err
+			}
+			batch = append(batch, f)
+			end += n
+		}
+		var err error
+		if bs != nil {
+			err = bs.EmitBatch(batch)
+		} else {
+			err = s.sink.Emit(batch[0])
+		}
+		if err != nil {
+			return err
+		}
+		s.offset = end
+		s.pending -= len(batch)
+		replayed += len(batch)
+		if err := s.commit(); err != nil {
+			return fmt.Errorf("spool: acknowledging: %w", err)
+		}
+	}
+	return s.reset()
+}
+
+// reset empties the file once every record was replayed. The acknowledged offset is
+// cleared after the file, so that an offset past the end is never taken for the start of
+// a record.
+func (s *Sink) reset() error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	s.size, s.offset, s.pending = 0, 0, 0
+	return s.commit()
+}
+
+// spool appends f to the file, or drops it if the file is full, and returns cause.
+func (s *Sink) spool(f collector.Fields, cause error) error {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(&f); err != nil {
+		return fmt.Errorf("spool: encoding sample: %w (after %v)", err, cause)
+	}
+	maxBytes := s.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if s.size+headerSize+int64(body.Len()) > maxBytes {
+		s.dropped++
+		return fmt.Errorf("spool: full, sample dropped: %w", cause)
+	}
+	record := make([]byte, headerSize, headerSize+body.Len())
+	binary.BigEndian.PutUint32(record[:4], uint32(body.Len()))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(body.Bytes()))
+	record = append(record, body.Bytes()...)
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		s.dropped++
+		return fmt.Errorf("spool: %v, sample dropped: %w", err, cause)
+	}
+	s.size += int64(len(record))
+	s.pending++
+	return cause
+}
+
+// Buffered implements collector.BufferedSink, returning the number of spooled samples and
+// the rate in samples per second they were last replayed at.
+func (s *Sink) Buffered() (depth int, drainRate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending, s.rate
+}
+
+// Dropped returns the number of samples dropped because the spool file was full or could
+// not be written.
+func (s *Sink) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close closes the spool file, keeping the samples not replayed yet for the next process,
+// and closes the sink if it implements io.Closer.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.closeFiles()
+	if c, ok := s.sink.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *Sink) closeFiles() error {
+	err := s.file.Close()
+	if s.ack != nil {
+		if aerr := s.ack.Close(); err == nil {
+			err = aerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+type flakySink struct {
+	samples []collector.Fields
+	err     error
+}
+
+func (s *flakySink) Emit(f collector.Fields) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.samples = append(s.samples, f)
+	return nil
+}
+
+func TestSpool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.spool")
+	down := &flakySink{err: errors.New("unreachable")}
+	s, err := New(down, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		f := collector.Fields{NumGoroutine: int64(i), Instance: "a"}
+		f.Extra = map[string]float64{"app.requests": float64(i)}
+		if err := s.Emit(f); err != down.err {
+			t.Fatalf("expected the error of the sink, got %v", err)
+		}
+	}
+	if depth, _ := s.Buffered(); depth != 3 {
+		t.Fatalf("expected three spooled samples, got %d", depth)
+	}
+	s.Close()
+
+	// A torn record at the end of the file, left by a process that stopped while
+	// writing it, is discarded.
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.Write([]byte{0, 0, 1})
+	file.Close()
+
+	up := &flakySink{}
+	s, err = New(up, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if depth, _ := s.Buffered(); depth != 3 {
+		t.Fatalf("expected the spooled samples to be kept across processes, got %d", depth)
+	}
+	if err := s.Emit(collector.Fields{NumGoroutine: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if len(up.samples) != 4 {
+		t.Fatalf("expected the spooled samples to be replayed, got %d samples", len(up.samples))
+	}
+	for i, f := range up.samples {
+		if f.NumGoroutine != int64(i) {
+			t.Errorf("expected sample %d in order, got %d", i, f.NumGoroutine)
+		}
+	}
+	if f := up.samples[2]; f.Instance != "a" || f.Extra["app.requests"] != 2 {
+		t.Errorf("expected the spooled sample to be kept whole, got %+v", f)
+	}
+	if fi, _ := os.Stat(path); fi.Size() != 0 {
+		t.Errorf("expected the file to be truncated once replayed, got %d bytes", fi.Size())
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	down := &flakySink{err: errors.New("unreachable")}
+	s, err := New(down, filepath.Join(t.TempDir(), "metrics.spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.MaxBytes = 1
+	if err := s.Emit(collector.Fields{}); !errors.Is(err, down.err) {
+		t.Fatalf("expected the error of the sink, got %v", err)
+	}
+	if depth, _ := s.Buffered(); depth != 0 || s.Dropped() != 1 {
+		t.Errorf("expected the sample to be dropped, got %d spooled and %d dropped", depth, s.Dropped())
+	}
+}
+
+// batchSink accepts accept samples before failing.
+type batchSink struct {
+	flakySink
+	accept  int
+	batches [][]collector.Fields
+}
+
+func (s *batchSink) Emit(f collector.Fields) error {
+	if s.accept == 0 {
+		return errors.New("unreachable")
+	}
+	s.accept--
+	return s.flakySink.Emit(f)
+}
+
+func (s *batchSink) EmitBatch(fs []collector.Fields) error {
+	if s.accept < len(fs) {
+		return errors.New("unreachable")
+	}
+	s.accept -= len(fs)
+	s.batches = append(s.batches, fs)
+	s.samples = append(s.samples, fs...)
+	return nil
+}
+
+func TestSpoolAcked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.spool")
+	s, err := NewAcked(&flakySink{err: errors.New("unreachable")}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		s.Emit(collector.Fields{NumGoroutine: int64(i)})
+	}
+	s.Close()
+
+	// The sink accepts the first spooled sample, then fails again.
+	partial := &flakySink{}
+	s, err = NewAcked(sinkFunc(func(f collector.Fields) error {
+		if len(partial.samples) == 1 {
+			return errors.New("unreachable")
+		}
+		return partial.Emit(f)
+	}), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Emit(collector.Fields{NumGoroutine: 3})
+	s.Close()
+	if len(partial.samples) != 1 || partial.samples[0].NumGoroutine != 0 {
+		t.Fatalf("expected the first sample to be replayed, got %v", partial.samples)
+	}
+
+	up := &batchSink{accept: 100}
+	s, err = NewAcked(up, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if depth, _ := s.Buffered(); depth != 3 {
+		t.Fatalf("expected the acknowledged sample to be skipped, got %d spooled", depth)
+	}
+	s.ReplayBatch = 2
+	if err := s.Emit(collector.Fields{NumGoroutine: 4}); err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range up.samples {
+		if f.NumGoroutine != int64(i+1) {
+			t.Fatalf("expected the samples after the acknowledged one, in order, got %v", up.samples)
+		}
+	}
+	if len(up.samples) != 4 || len(up.batches) != 2 || len(up.batches[0]) != 2 {
+		t.Errorf("expected the spooled samples to be replayed in batches of 2, got %v", up.batches)
+	}
+	if ack, _ := os.ReadFile(path + ".ack"); len(ack) != ackSize || string(ack[:8]) != "\x00\x00\x00\x00\x00\x00\x00\x00" {
+		t.Errorf("expected the acknowledged offset to be cleared with the file, got %q", ack)
+	}
+}
+
+type sinkFunc func(collector.Fields) error
+
+func (fn sinkFunc) Emit(f collector.Fields) error { return fn(f) }
@@ -0,0 +1,141 @@
+// Package statsd emits collector.Fields as StatsD gauges over UDP, batching as many
+// gauges per packet as fit under the configured packet size.
+package statsd
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/dialer"
+)
+
+// DefaultMaxPacketSize keeps packets below the common Ethernet MTU once IP and UDP headers
+// are added.
+const DefaultMaxPacketSize = 1432
+
+// Sink emits every field of a sample as a StatsD gauge.
+type Sink struct {
+	// Prefix is prepended to every metric name, separated by a dot.
+	Prefix string
+
+	// SampleRate is the probability with which each gauge is sent, annotated on the wire so
+	// the receiving agent can compensate. Values outside of (0, 1) send every gauge.
+	SampleRate float64
+
+	// MaxPacketSize is the maximum size of a packet in bytes. Defaults to
+	// DefaultMaxPacketSize.
+	MaxPacketSize int
+
+	// Deadline, when positive, bounds the time spent sending a sample. Gauges are sent in
+	// the order of Fields.Series, so the critical ones go out first and filler gauges are
+	// the ones dropped.
+	Deadline time.Duration
+
+	// ResolveInterval is how often the hostname of the address is resolved again, so that
+	// the sink follows the agent to a new IP address without a restart. Defaults to one
+	// minute, negative values never resolve it again.
+	ResolveInterval time.Duration
+
+	conn *dialer.Conn
+}
+
+var _ collector.Sink = (*Sink)(nil)
+
+// Dial returns a Sink sending to the StatsD agent at addr, a host:port pair such as
+// "localhost:8125" or "[::1]:8125".
+func Dial(addr string) (*Sink, error) {
+	conn, err := dialer.DialConn("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{conn: conn}, nil
+}
+
+// Emit sends f to the agent.
+func (s *Sink) Emit(f collector.Fields) error {
+	maxSize := s.MaxPacketSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPacketSize
+	}
+	sampled := s.SampleRate > 0 && s.SampleRate < 1
+
+	var deadline time.Time
+	if s.Deadline > 0 {
+		deadline = time.Now().Add(s.Deadline)
+	}
+
+	packet := make([]byte, 0, maxSize)
+	flush := func() error {
+		if len(packet) == 0 {
+			return nil
+		}
+		err := s.conn.Send(packet, s.ResolveInterval)
+		packet = packet[:0]
+		return err
+	}
+
+	_, err := collector.SendSeries(f.Series(), deadline, func(series collector.Series) error {
+		if sampled && rand.Float64() >= s.SampleRate {
+			return nil
+		}
+		line := s.appendGauge(nil, series)
+		if len(packet) > 0 && len(packet)+1+len(line) > maxSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+		return nil
+	})
+	if ferr := flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// appendGauge appends the StatsD lines setting the gauge of series to b. A leading sign
+// would make the agent adjust the gauge rather than set it, so negative values are sent
+// as a reset to zero followed by a decrement.
+func (s *Sink) appendGauge(b []byte, series collector.Series) []byte {
+	name := series.Name
+	if s.Prefix != "" {
+		name = s.Prefix + "." + name
+	}
+
+	var value string
+	negative := false
+	switch v := series.Value.(type) {
+	case int64:
+		value, negative = strconv.FormatInt(v, 10), v < 0
+	case float64:
+		value, negative = strconv.FormatFloat(v, 'f', -1, 64), v < 0
+	}
+
+	if negative {
+		b = s.appendLine(b, name, "0")
+		b = append(b, '\n')
+	}
+	return s.appendLine(b, name, value)
+}
+
+func (s *Sink) appendLine(b []byte, name, value string) []byte {
+	b = append(b, name...)
+	b = append(b, ':')
+	b = append(b, value...)
+	b = append(b, "|g"...)
+	if s.SampleRate > 0 && s.SampleRate < 1 {
+		b = append(b, "|@"...)
+		b = strconv.AppendFloat(b, s.SampleRate, 'f', -1, 64)
+	}
+	return b
+}
+
+// Close closes the underlying connection.
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,52 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := Dial(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Prefix = "app"
+	s.MaxPacketSize = 512
+
+	if err := s.Emit(collector.Fields{NumGoroutine: 5, HeapGrowth: -10}); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(lines) < len((&collector.Fields{}).ToMap())+1 {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > s.MaxPacketSize {
+			t.Errorf("packet of %d bytes exceeds the maximum size", n)
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+
+	if lines[2] != "app.cpu.goroutines:5|g" {
+		t.Errorf("expected critical gauges first, got %q", lines[:3])
+	}
+	joined := strings.Join(lines, "\n")
+	if !strings.Contains(joined, "app.mem.heap.growth:0|g\napp.mem.heap.growth:-10|g") {
+		t.Errorf("expected a negative gauge to be reset first")
+	}
+}
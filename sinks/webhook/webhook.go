@@ -0,0 +1,89 @@
+// Package webhook posts collector events as JSON to an HTTP endpoint, signing each request
+// so the receiver can verify it came from a holder of the shared secret.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+	"github.com/tevjef/go-runtime-metrics/sinks/internal/httppost"
+)
+
+// SignatureHeader carries the signature of the request body, in the form
+// "sha256=<hex HMAC-SHA256>".
+const SignatureHeader = "X-Signature-256"
+
+// EventHeader carries the Kind of the posted event.
+const EventHeader = "X-Event-Kind"
+
+// Sink posts every event it receives to URL.
+type Sink struct {
+	URL string
+
+	// Secret is the key the request body is signed with. Requests are not signed if it is
+	// empty.
+	Secret []byte
+
+	// Header holds extra headers added to every request, such as authorization.
+	Header http.Header
+
+	// MaxRetries is the number of times a failed request is retried. Only network
+	// failures, 429 and 5xx responses are retried. Defaults to 0.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, doubling with each attempt.
+	// Defaults to one second.
+	RetryBackoff time.Duration
+
+	// Client sends the requests. Defaults to a client timing out after 10 seconds.
+	Client *http.Client
+}
+
+var _ collector.EventSink = (*Sink)(nil)
+
+// New returns a Sink posting to url, signing requests with secret.
+func New(url string, secret []byte) *Sink {
+	return &Sink{URL: url, Secret: secret}
+}
+
+// EmitEvent implements collector.EventSink.
+func (s *Sink) EmitEvent(e collector.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	for k, v := range s.Header {
+		header[k] = v
+	}
+	header.Set(EventHeader, e.Kind)
+	if len(s.Secret) > 0 {
+		header.Set(SignatureHeader, Sign(s.Secret, body))
+	}
+
+	p := httppost.Poster{MaxRetries: s.MaxRetries, RetryBackoff: s.RetryBackoff, Client: s.Client}
+	if err := p.Post(s.URL, header, body); err != nil {
+		return fmt.Errorf("webhook: post failed: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the value of SignatureHeader for body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid SignatureHeader for body, comparing in
+// constant time.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
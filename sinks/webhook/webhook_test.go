@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tevjef/go-runtime-metrics/collector"
+)
+
+func TestSink(t *testing.T) {
+	secret := []byte("s3cret")
+	var requests int
+	var got collector.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("invalid signature %q", r.Header.Get(SignatureHeader))
+		}
+		if kind := r.Header.Get(EventHeader); kind != "memory_limit" {
+			t.Errorf("unexpected event kind %q", kind)
+		}
+		if r.Header.Get("Authorization") != "Bearer x" {
+			t.Error("expected the configured header")
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	s := New(srv.URL, secret)
+	s.Header = http.Header{"Authorization": {"Bearer x"}}
+	s.MaxRetries = 1
+	s.RetryBackoff = time.Millisecond
+
+	if err := s.EmitEvent(collector.Event{Kind: "memory_limit", Message: "high", Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || got.Message != "high" {
+		t.Errorf("expected a retried delivery, got %d requests and %+v", requests, got)
+	}
+
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"kind":"x"}`)
+	sig := Sign([]byte("a"), body)
+	if !Verify([]byte("a"), body, sig) || Verify([]byte("b"), body, sig) {
+		t.Error("signature verification is broken")
+	}
+}